@@ -1,6 +1,8 @@
 package auth
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"time"
 
@@ -12,20 +14,27 @@ import (
 )
 
 type Claims struct {
-	UserID   uint   `json:"user_id"`
-	Email    string `json:"email"`
-	FullName string `json:"full_name"`
+	UserID       uint   `json:"user_id"`
+	Email        string `json:"email"`
+	FullName     string `json:"full_name"`
+	TokenVersion int    `json:"ver"` // User.TokenVersion when issued; bumped to revoke every token
 	jwt.RegisteredClaims
 }
 
 func GenerateToken(user *models.User, cfg *config.Config) (string, error) {
-	expirationTime := time.Now().Add(time.Duration(cfg.JWT.ExpiryHours) * time.Hour)
+	expirationTime := time.Now().Add(cfg.JWT.AccessTTL)
+	jti, err := newTokenID()
+	if err != nil {
+		return "", err
+	}
 	
 	claims := &Claims{
-		UserID:   user.ID,
-		Email:    user.Email,
-		FullName: user.FullName,
+		UserID:       user.ID,
+		Email:        user.Email,
+		FullName:     user.FullName,
+		TokenVersion: user.TokenVersion,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
@@ -53,9 +62,23 @@ func ValidateToken(tokenString string, cfg *config.Config) (*Claims, error) {
 		return nil, errors.New("invalid token")
 	}
 
+	// Tokens without an ID cannot be revoked
+	if claims.ID == "" {
+		return nil, errors.New("token has no jti")
+	}
+
 	return claims, nil
 }
 
+// newTokenID returns a random jti for an access token.
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
 func HashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	return string(bytes), err
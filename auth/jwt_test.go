@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"maths-solution-backend/config"
+	"maths-solution-backend/models"
+)
+
+func TestAccessTokenLifetime(t *testing.T) {
+	user := &models.User{ID: 7, Email: "a@example.com", TokenVersion: 2}
+	tests := []struct {
+		name    string
+		ttl     time.Duration
+		wantErr bool
+	}{
+		{"default", 15 * time.Minute, false},
+		{"short", time.Minute, false},
+		{"expired", -time.Minute, true},
+	}
+	for _, tt := range tests {
+		cfg := &config.Config{JWT: config.JWTConfig{Secret: "test-secret", AccessTTL: tt.ttl}}
+		token, err := GenerateToken(user, cfg)
+		if err != nil {
+			t.Fatalf("%s: GenerateToken: %v", tt.name, err)
+		}
+		claims, err := ValidateToken(token, cfg)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: ValidateToken accepted the token", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: ValidateToken: %v", tt.name, err)
+			continue
+		}
+		// Both claims are rounded to whole seconds
+		if lifetime := claims.ExpiresAt.Sub(claims.IssuedAt.Time); (lifetime - tt.ttl).Abs() > time.Second {
+			t.Errorf("%s: lifetime %s, want %s", tt.name, lifetime, tt.ttl)
+		}
+		if claims.UserID != user.ID || claims.TokenVersion != user.TokenVersion {
+			t.Errorf("%s: claims %+v do not match the user", tt.name, claims)
+		}
+	}
+}
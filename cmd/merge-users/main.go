@@ -0,0 +1,117 @@
+// Command merge-users finds users sharing an email address, ignoring case,
+// and merges each group into its oldest account: solutions, daily usage,
+// jobs and practice sets move to that account, which counts as verified if
+// any of them was, and the others are soft-deleted with every token they
+// were issued revoked. Without -apply it only reports what it would merge.
+// Run it before migrating a database that predates unique emails.
+//
+//	go run ./cmd/merge-users [-apply]
+package main
+
+import (
+	"flag"
+	"log"
+	"time"
+
+	"maths-solution-backend/config"
+	"maths-solution-backend/database"
+	"maths-solution-backend/models"
+
+	"gorm.io/gorm"
+)
+
+func main() {
+	apply := flag.Bool("apply", false, "merge the duplicates instead of only listing them")
+	flag.Parse()
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("Failed to load configuration:", err)
+	}
+	if err := database.Connect(cfg); err != nil {
+		log.Fatal(err)
+	}
+	defer database.Close()
+
+	emails, err := database.DuplicateEmails(database.DB)
+	if err != nil {
+		log.Fatal("Failed to find duplicate emails:", err)
+	}
+	if len(emails) == 0 {
+		log.Println("No duplicate emails")
+		return
+	}
+
+	for _, email := range emails {
+		var users []models.User
+		if err := database.DB.Where("lower(email) = ?", email).Order("created_at, id").Find(&users).Error; err != nil {
+			log.Fatalf("Failed to load users for %s: %v", email, err)
+		}
+		keep, merged := users[0], users[1:]
+		ids := make([]uint, len(merged))
+		for i, u := range merged {
+			ids[i] = u.ID
+		}
+
+		if !*apply {
+			log.Printf("%s: would merge users %v into user %d", email, ids, keep.ID)
+			continue
+		}
+		if err := database.DB.Transaction(func(tx *gorm.DB) error {
+			return mergeUsers(tx, keep.ID, ids)
+		}); err != nil {
+			log.Fatalf("Failed to merge users %v into user %d: %v", ids, keep.ID, err)
+		}
+		log.Printf("%s: merged users %v into user %d", email, ids, keep.ID)
+	}
+
+	if !*apply {
+		log.Printf("%d duplicate emails found; run again with -apply to merge them", len(emails))
+	}
+}
+
+// mergeUsers moves everything owned by the users in ids to keep, adding up
+// their daily usage and keeping the earliest email verification, then
+// revokes their tokens and soft-deletes them.
+func mergeUsers(tx *gorm.DB, keep uint, ids []uint) error {
+	for _, model := range []interface{}{&models.Solution{}, &models.SolveJob{}, &models.PracticeSet{}} {
+		if err := tx.Model(model).Where("user_id IN ?", ids).Update("user_id", keep).Error; err != nil {
+			return err
+		}
+	}
+
+	// One usage row per user and day: add the merged counts to the kept user's
+	err := tx.Exec(`INSERT INTO usage_limits (user_id, date, count, created_at, updated_at)
+		SELECT ?, date, SUM(count), MIN(created_at), NOW() FROM usage_limits WHERE user_id IN ? GROUP BY date
+		ON CONFLICT (user_id, date) DO UPDATE SET count = usage_limits.count + EXCLUDED.count, updated_at = NOW()`,
+		keep, ids).Error
+	if err != nil {
+		return err
+	}
+	if err := tx.Where("user_id IN ?", ids).Delete(&models.UsageLimit{}).Error; err != nil {
+		return err
+	}
+
+	// The address is the same, so a verification of any account counts
+	earliest := gorm.Expr("(SELECT MIN(email_verified_at) FROM users WHERE id IN ?)", ids)
+	if err := tx.Model(&models.User{}).Where("id = ? AND email_verified_at IS NULL", keep).Update("email_verified_at", earliest).Error; err != nil {
+		return err
+	}
+
+	// Every token of the merged accounts stops working: a new token version
+	// rejects their access tokens even if a user is restored, and their
+	// sessions, password reset and verification links are revoked
+	now := time.Now()
+	if err := tx.Model(&models.User{}).Where("id IN ?", ids).Update("token_version", gorm.Expr("token_version + 1")).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.RefreshToken{}).Where("user_id IN ? AND revoked_at IS NULL", ids).Update("revoked_at", now).Error; err != nil {
+		return err
+	}
+	for _, model := range []interface{}{&models.PasswordResetToken{}, &models.EmailVerificationToken{}} {
+		if err := tx.Model(model).Where("user_id IN ? AND used_at IS NULL", ids).Update("used_at", now).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Where("id IN ?", ids).Delete(&models.User{}).Error
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// TestMergeUsersStatements checks the statements of merging users 2 and 3
+// into user 1 without a database: gorm's dry run builds them but runs none.
+func TestMergeUsersStatements(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=127.0.0.1 port=1"}), &gorm.Config{
+		DryRun:                 true,
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var statements []string
+	record := func(tx *gorm.DB) {
+		statements = append(statements, tx.Dialector.Explain(tx.Statement.SQL.String(), tx.Statement.Vars...))
+	}
+	for _, err := range []error{
+		db.Callback().Update().After("gorm:update").Register("test:record", record),
+		db.Callback().Delete().After("gorm:delete").Register("test:record", record),
+		db.Callback().Raw().After("gorm:raw").Register("test:record", record),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := mergeUsers(db, 1, []uint{2, 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each statement, in order, with fragments it must contain
+	tests := []struct {
+		name      string
+		fragments []string
+	}{
+		{"move solutions", []string{`UPDATE "solutions" SET "user_id"=1`, "WHERE user_id IN (2,3)"}},
+		{"move jobs", []string{`UPDATE "solve_jobs" SET "user_id"=1`, "WHERE user_id IN (2,3)"}},
+		{"move practice sets", []string{`UPDATE "practice_sets" SET "user_id"=1`, "WHERE user_id IN (2,3)"}},
+		{"add up usage", []string{"INSERT INTO usage_limits", "SELECT 1, date, SUM(count)", "WHERE user_id IN (2,3) GROUP BY date", "count = usage_limits.count + EXCLUDED.count"}},
+		{"drop merged usage", []string{`DELETE FROM "usage_limits" WHERE user_id IN (2,3)`}},
+		{"keep verification", []string{`"email_verified_at"=(SELECT MIN(email_verified_at) FROM users WHERE id IN (2,3))`, "id = 1 AND email_verified_at IS NULL"}},
+		{"bump token version", []string{`UPDATE "users" SET "token_version"=token_version + 1`, "WHERE id IN (2,3)"}},
+		{"revoke refresh tokens", []string{`UPDATE "refresh_tokens" SET "revoked_at"=`, "WHERE user_id IN (2,3) AND revoked_at IS NULL"}},
+		{"revoke reset links", []string{`UPDATE "password_reset_tokens" SET "used_at"=`, "WHERE user_id IN (2,3) AND used_at IS NULL"}},
+		{"revoke verification links", []string{`UPDATE "email_verification_tokens" SET "used_at"=`, "WHERE user_id IN (2,3) AND used_at IS NULL"}},
+		{"soft-delete merged users", []string{`UPDATE "users" SET "deleted_at"=`, "WHERE id IN (2,3)"}},
+	}
+	if len(statements) != len(tests) {
+		t.Fatalf("%d statements, want %d:\n%s", len(statements), len(tests), strings.Join(statements, "\n"))
+	}
+	for i, tt := range tests {
+		for _, fragment := range tt.fragments {
+			if !strings.Contains(statements[i], fragment) {
+				t.Errorf("%s: statement %q lacks %q", tt.name, statements[i], fragment)
+			}
+		}
+	}
+}
@@ -26,7 +26,8 @@ func Connect(cfg *config.Config) error {
 	}
 
 	DB, err = gorm.Open(postgres.Open(cfg.Database.URL), &gorm.Config{
-		Logger: gormLogger,
+		Logger:         gormLogger,
+		TranslateError: true, // Report unique violations as gorm.ErrDuplicatedKey
 	})
 	if err != nil {
 		return fmt.Errorf("failed to connect to database: %w", err)
@@ -41,15 +42,51 @@ func Migrate() error {
 		return fmt.Errorf("database connection not initialized")
 	}
 
-	err := DB.AutoMigrate(&models.User{}, &models.Solution{}, &models.UsageLimit{})
+	// The unique email index cannot be built while accounts share an address
+	duplicates, err := DuplicateEmails(DB)
+	if err != nil {
+		return fmt.Errorf("failed to check for duplicate emails: %w", err)
+	}
+	if len(duplicates) > 0 {
+		return fmt.Errorf("%d email addresses belong to several users; merge them with `go run ./cmd/merge-users -apply` and migrate again", len(duplicates))
+	}
+
+	// Accounts created before email verification existed keep their full limit
+	grandfather := DB.Migrator().HasTable(&models.User{}) && !DB.Migrator().HasColumn(&models.User{}, "EmailVerifiedAt")
+
+	err = DB.AutoMigrate(&models.User{}, &models.Solution{}, &models.UsageLimit{}, &models.CachedSolution{}, &models.SolveJob{}, &models.PracticeSet{}, &models.PracticeProblem{}, &models.RefreshToken{}, &models.RevokedToken{}, &models.PasswordResetToken{}, &models.EmailVerificationToken{})
 	if err != nil {
 		return fmt.Errorf("failed to migrate database: %w", err)
 	}
 
+	if grandfather {
+		res := DB.Model(&models.User{}).Where("email_verified_at IS NULL").Update("email_verified_at", gorm.Expr("created_at"))
+		if res.Error != nil {
+			return fmt.Errorf("failed to mark existing users verified: %w", res.Error)
+		}
+		log.Printf("Marked %d existing users as verified", res.RowsAffected)
+	}
+
 	log.Println("Database migration completed successfully")
 	return nil
 }
 
+// DuplicateEmails lists the addresses, lowercased, shared by several live
+// users. Emails are compared ignoring case.
+func DuplicateEmails(db *gorm.DB) ([]string, error) {
+	var emails []string
+	if !db.Migrator().HasTable(&models.User{}) {
+		return emails, nil
+	}
+	err := db.Model(&models.User{}).
+		Select("lower(email)").
+		Group("lower(email)").
+		Having("count(*) > 1").
+		Order("lower(email)").
+		Scan(&emails).Error
+	return emails, err
+}
+
 func Close() error {
 	if DB == nil {
 		return nil
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"net/http"
+
+	"maths-solution-backend/mathengine"
+	"maths-solution-backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CompareAnswers decides whether two answers are equivalent, symbolically
+// where possible and numerically otherwise, and explains why. It runs
+// locally and does not count against the daily limit.
+func (h *MathHandler) CompareAnswers(c *gin.Context) {
+	var req models.CompareAnswersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var tolerance float64
+	if req.Tolerance != nil {
+		tolerance = *req.Tolerance
+	}
+	comparison, err := mathengine.CompareAnswers(req.Expected, req.Answer, tolerance)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Cannot read " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, comparison)
+}
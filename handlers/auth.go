@@ -1,22 +1,39 @@
 package handlers
 
 import (
+	"errors"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"maths-solution-backend/auth"
 	"maths-solution-backend/config"
 	"maths-solution-backend/database"
 	"maths-solution-backend/models"
+	"maths-solution-backend/services"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type AuthHandler struct {
-	config *config.Config
+	config         *config.Config
+	refreshTokens  *services.RefreshTokenService
+	revocations    *services.TokenRevocations
+	passwordResets *services.PasswordResetService
+	verifications  *services.EmailVerificationService
 }
 
-func NewAuthHandler(cfg *config.Config) *AuthHandler {
-	return &AuthHandler{config: cfg}
+func NewAuthHandler(cfg *config.Config, revocations *services.TokenRevocations, mailer services.Mailer) *AuthHandler {
+	return &AuthHandler{
+		config:         cfg,
+		refreshTokens:  services.NewRefreshTokenService(database.DB, cfg.JWT.RefreshTTL),
+		revocations:    revocations,
+		passwordResets: services.NewPasswordResetService(database.DB, mailer, cfg.Mail),
+		verifications:  services.NewEmailVerificationService(database.DB, mailer, cfg.Mail),
+	}
 }
 
 func (h *AuthHandler) Register(c *gin.Context) {
@@ -26,7 +43,17 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	// Allow duplicate emails (no pre-check); newest registration will coexist.
+	// Emails are unique ignoring case; the unique index catches races
+	email := strings.TrimSpace(req.Email)
+	var existing int64
+	if err := database.DB.Model(&models.User{}).Where("lower(email) = lower(?)", email).Count(&existing).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email already registered"})
+		return
+	}
 
 	// Hash password
 	hashedPassword, err := auth.HashPassword(req.Password)
@@ -37,27 +64,32 @@ func (h *AuthHandler) Register(c *gin.Context) {
 
 	// Create user
 	user := models.User{
-		Email:    req.Email,
+		Email:    email,
 		FullName: req.FullName,
 		Password: hashedPassword,
 	}
 
 	if err := database.DB.Create(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Email already registered"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 		return
 	}
 
-	// Generate token
-	token, err := auth.GenerateToken(&user, h.config)
+	// The account works before the address is verified, with a reduced limit
+	if err := h.verifications.Send(&user); err != nil {
+		log.Printf("[warn] Failed to start email verification for user %d: %v", user.ID, err)
+	}
+
+	// Generate tokens, starting a new refresh token family
+	refreshToken, err := h.refreshTokens.Issue(user.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
-
-	c.JSON(http.StatusCreated, models.AuthResponse{
-		Token: token,
-		User:  user,
-	})
+	h.respondWithTokens(c, http.StatusCreated, &user, refreshToken)
 }
 
 func (h *AuthHandler) Login(c *gin.Context) {
@@ -69,7 +101,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 
 	// Find user without emitting ErrRecordNotFound logs
 	var user models.User
-	res := database.DB.Where("email = ?", req.Email).Limit(1).Find(&user)
+	res := database.DB.Where("lower(email) = lower(?)", strings.TrimSpace(req.Email)).Limit(1).Find(&user)
 	if res.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 		return
@@ -85,15 +117,214 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	// Generate token
-	token, err := auth.GenerateToken(&user, h.config)
+	// Generate tokens, starting a new refresh token family
+	refreshToken, err := h.refreshTokens.Issue(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	h.respondWithTokens(c, http.StatusOK, &user, refreshToken)
+}
+
+// Refresh exchanges a refresh token for a new access token and a new refresh
+// token. Each refresh token works once; replaying one revokes every token
+// rotated from the same login.
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req models.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, refreshToken, err := h.refreshTokens.Rotate(req.RefreshToken)
+	if errors.Is(err, services.ErrRefreshTokenReused) {
+		log.Printf("[warn] Reused refresh token presented from %s; its token family was revoked", c.ClientIP())
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token already used; please log in again"})
+		return
+	}
+	if errors.Is(err, services.ErrRefreshTokenInvalid) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		return
+	}
+
+	var user models.User
+	res := database.DB.Where("id = ?", userID).Limit(1).Find(&user)
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+	h.respondWithTokens(c, http.StatusOK, &user, refreshToken)
+}
+
+// Logout revokes the access token of the request and, when one is given, the
+// refresh token of the same session along with every token rotated from it.
+func (h *AuthHandler) Logout(c *gin.Context) {
+	var req models.LogoutRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	token := c.MustGet("access_token").(services.AccessToken)
+	if err := h.revocations.Revoke(c.Request.Context(), token); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke token"})
+		return
+	}
+	if req.RefreshToken != "" {
+		if err := h.refreshTokens.Revoke(token.UserID, req.RefreshToken); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke refresh token"})
+			return
+		}
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// LogoutAll revokes every access and refresh token of the user, signing out
+// all of their sessions.
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	token := c.MustGet("access_token").(services.AccessToken)
+	if err := h.revocations.RevokeUser(c.Request.Context(), token.UserID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke tokens"})
+		return
+	}
+	if err := h.refreshTokens.RevokeUser(token.UserID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke refresh tokens"})
+		return
+	}
+	log.Printf("[info] All sessions of user %d revoked", token.UserID)
+	c.Status(http.StatusNoContent)
+}
+
+// VerifyEmail marks the user's address verified with the token from the
+// link emailed on registration.
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing token"})
+		return
+	}
+
+	user, err := h.verifications.Verify(token)
+	if errors.Is(err, services.ErrVerificationTokenInvalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification link"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+	c.JSON(http.StatusOK, models.VerifyEmailResponse{
+		Email:           user.Email,
+		EmailVerifiedAt: *user.EmailVerifiedAt,
+	})
+}
+
+// ResendVerification emails the user a new verification link, at most once
+// per EMAIL_VERIFICATION_RESEND_SECONDS.
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	err := h.verifications.Resend(userID.(uint))
+	var throttled *services.ResendThrottledError
+	switch {
+	case errors.As(err, &throttled):
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Verification email sent recently; try again later"})
+	case errors.Is(err, services.ErrEmailAlreadyVerified):
+		c.JSON(http.StatusConflict, gin.H{"error": "Email already verified"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+	default:
+		c.Status(http.StatusAccepted)
+	}
+}
+
+// ForgotPassword emails a password reset link when the address is
+// registered. It answers the same either way, so it cannot be used to find
+// out which addresses have accounts; only clients asking too often are
+// told to slow down.
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req models.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.passwordResets.Request(req.Email, c.ClientIP())
+	var throttled *services.ResendThrottledError
+	switch {
+	case errors.As(err, &throttled):
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many password reset requests; try again later"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start password reset"})
+	default:
+		c.Status(http.StatusAccepted)
+	}
+}
+
+// ResetPassword sets a new password with a token from ForgotPassword and
+// signs out every session of the account.
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hashedPassword, err := auth.HashPassword(req.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+	userID, err := h.passwordResets.Reset(req.Token, hashedPassword)
+	if errors.Is(err, services.ErrResetTokenInvalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	// Whoever knew the old password loses access
+	if err := h.revocations.RevokeUser(c.Request.Context(), userID); err != nil {
+		log.Printf("[warn] Failed to revoke access tokens of user %d after password reset: %v", userID, err)
+	}
+	if err := h.refreshTokens.RevokeUser(userID); err != nil {
+		log.Printf("[warn] Failed to revoke refresh tokens of user %d after password reset: %v", userID, err)
+	}
+	log.Printf("[info] Password of user %d reset", userID)
+	c.Status(http.StatusNoContent)
+}
+
+// respondWithTokens issues an access token for user and writes it with
+// refreshToken.
+func (h *AuthHandler) respondWithTokens(c *gin.Context, status int, user *models.User, refreshToken string) {
+	token, err := auth.GenerateToken(user, h.config)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	c.JSON(http.StatusOK, models.AuthResponse{
-		Token: token,
-		User:  user,
+	c.JSON(status, models.AuthResponse{
+		Token:        token,
+		ExpiresIn:    int(h.config.JWT.AccessTTL.Seconds()),
+		RefreshToken: refreshToken,
+		User:         *user,
 	})
 }
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"maths-solution-backend/models"
+	"maths-solution-backend/normalizer"
+	"maths-solution-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SolveBatch solves a worksheet of expressions with bounded concurrency and
+// reports each item in request order. Quota for every valid item is reserved
+// up front in one step: mode "reject" refuses the batch with 429 unless all
+// of it fits, "partial" solves as many leading items as the quota allows and
+// skips the rest. Items that fail, or are not charged, are refunded.
+func (h *MathHandler) SolveBatch(c *gin.Context) {
+	var req models.SolveBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Mode == "" {
+		req.Mode = models.BatchModeReject
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userIDUint, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	solver, err := h.solvers.Get(req.Solver)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Malformed items fail on their own and take no quota
+	results := make([]models.SolveBatchItem, len(req.Expressions))
+	var jobs []*solveJob
+	var indexes []int
+	for i, raw := range req.Expressions {
+		results[i].Index = i
+		expression, err := normalizer.Normalize(raw, req.InputFormat)
+		if err != nil {
+			results[i].Status = models.BatchItemFailed
+			results[i].Error = "Invalid expression: " + err.Error()
+			results[i].StatusCode = http.StatusUnprocessableEntity
+			continue
+		}
+		jobs = append(jobs, &solveJob{
+			userID:     userIDUint,
+			expression: expression,
+			solver:     solver,
+			precision:  req.Precision,
+			mode:       models.SolveModeFull,
+			prepaid:    true,
+		})
+		indexes = append(indexes, i)
+	}
+
+	reservation, usage, err := h.usageService.ReserveUsage(userIDUint, len(jobs), req.Mode == models.BatchModePartial)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check usage limit"})
+		return
+	}
+	if reservation.Granted < len(jobs) && req.Mode == models.BatchModeReject {
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error":     "Daily usage limit exceeded",
+			"requested": len(jobs),
+			"usage":     usage,
+		})
+		return
+	}
+	for _, i := range indexes[reservation.Granted:] {
+		results[i].Status = models.BatchItemSkipped
+		results[i].Error = "Daily usage limit exceeded"
+		results[i].StatusCode = http.StatusTooManyRequests
+	}
+	jobs, indexes = jobs[:reservation.Granted], indexes[:reservation.Granted]
+
+	ctx := c.Request.Context()
+	concurrency := h.config.Solver.BatchConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	refund := 0
+	for n, job := range jobs {
+		wg.Add(1)
+		go func(item *models.SolveBatchItem, job *solveJob) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			charged := h.solveBatchItem(ctx, item, job)
+			if !charged {
+				mu.Lock()
+				refund++
+				mu.Unlock()
+			}
+		}(&results[indexes[n]], job)
+	}
+	wg.Wait()
+
+	if refund > 0 {
+		if err := h.usageService.ReleaseUsage(reservation, refund); err == nil {
+			usage.Count -= refund
+			usage.Exceeded = usage.Count >= usage.Limit
+		}
+	}
+	if ctx.Err() != nil {
+		c.AbortWithStatus(statusClientClosedRequest)
+		return
+	}
+
+	response := models.SolveBatchResponse{Mode: req.Mode, Results: results, Usage: usage}
+	for _, item := range results {
+		if item.Status == models.BatchItemSolved {
+			response.Solved++
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// solveBatchItem solves one reserved item into item and reports whether it
+// should stay charged.
+func (h *MathHandler) solveBatchItem(ctx context.Context, item *models.SolveBatchItem, job *solveJob) bool {
+	if ctx.Err() != nil {
+		item.Status = models.BatchItemSkipped
+		item.Error = ctx.Err().Error()
+		return false
+	}
+
+	result, err := job.solver.Solve(ctx, services.SolveRequest{Expression: job.expression, Precision: job.precision})
+	if err != nil {
+		item.Status = models.BatchItemFailed
+		item.StatusCode, item.Error = solveErrorStatus(job.solver, err)
+		return false
+	}
+
+	response, err := h.finishSolve(ctx, job, result)
+	if err != nil {
+		item.Status = models.BatchItemFailed
+		item.Error = "Failed to process solution"
+		item.StatusCode = http.StatusInternalServerError
+		return false
+	}
+	item.Status = models.BatchItemSolved
+	item.Result = &response
+	return h.chargeable(result)
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"maths-solution-backend/config"
+	"maths-solution-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestSolveBatchRejected covers batches refused before any quota is reserved.
+func TestSolveBatchRejected(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	solvers := services.NewSolverRegistry("local")
+	solvers.Register(services.NewLocalSolver())
+	h := &MathHandler{config: &config.Config{}, solvers: solvers}
+
+	tooMany := `"1"` + strings.Repeat(`, "1"`, 50)
+	tests := []struct {
+		name   string
+		body   string
+		userID interface{}
+		status int
+	}{
+		{"no expressions", `{"expressions": []}`, uint(1), http.StatusBadRequest},
+		{"more than 50", `{"expressions": [` + tooMany + `]}`, uint(1), http.StatusBadRequest},
+		{"unknown mode", `{"expressions": ["1"], "mode": "all"}`, uint(1), http.StatusBadRequest},
+		{"unknown format", `{"expressions": ["1"], "input_format": "tex"}`, uint(1), http.StatusBadRequest},
+		{"precision out of range", `{"expressions": ["1"], "precision": 101}`, uint(1), http.StatusBadRequest},
+		{"not authenticated", `{"expressions": ["1"]}`, nil, http.StatusUnauthorized},
+		{"unknown solver", `{"expressions": ["1"], "solver": "wolfram"}`, uint(1), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/api/solve-batch", strings.NewReader(tt.body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		if tt.userID != nil {
+			c.Set("user_id", tt.userID)
+		}
+		h.SolveBatch(c)
+		if w.Code != tt.status {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, w.Code, tt.status, w.Body.String())
+		}
+	}
+}
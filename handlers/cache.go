@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"net/http"
+
+	"maths-solution-backend/models"
+	"maths-solution-backend/normalizer"
+
+	"github.com/gin-gonic/gin"
+)
+
+// InvalidateCache drops every cached solution of an expression, whatever the
+// solver or options it was solved with. The cache is shared by all users, so
+// the route is for admins only.
+func (h *MathHandler) InvalidateCache(c *gin.Context) {
+	var req models.InvalidateCacheRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cache := h.solvers.Cache()
+	if cache == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Solution cache is disabled"})
+		return
+	}
+
+	expression, err := normalizer.Normalize(req.Expression, req.InputFormat)
+	if err != nil {
+		respondInvalidExpression(c, err)
+		return
+	}
+
+	removed := cache.InvalidateExpression(c.Request.Context(), expression)
+	c.JSON(http.StatusOK, gin.H{"expression": expression, "removed": removed})
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"maths-solution-backend/mathengine"
+	"maths-solution-backend/models"
+	"maths-solution-backend/normalizer"
+	"maths-solution-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CheckWork checks a student's own worked steps for a problem and reports the
+// first step that does not follow from the one before. The selected solver
+// supplies the reference answer and the hints; the check itself is exact
+// where possible and numeric otherwise. It counts against the daily limit
+// like a solve.
+func (h *MathHandler) CheckWork(c *gin.Context) {
+	var req models.CheckWorkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	job, ok := h.prepareSolve(c, models.SolveMathRequest{
+		Expression:  req.Problem,
+		InputFormat: req.InputFormat,
+		Solver:      req.Solver,
+	})
+	if !ok {
+		return
+	}
+
+	// The reference solution is optional: steps can still be checked
+	// against the problem when the solver fails
+	ctx := c.Request.Context()
+	var reference string
+	var referenceSteps []models.SolutionStep
+	result, err := job.solver.Solve(ctx, services.SolveRequest{Expression: job.expression})
+	if ctx.Err() != nil {
+		abortCancelled(c, job)
+		return
+	}
+	if err != nil {
+		log.Printf("[warn] No reference solution for %q: %v", job.expression, err)
+	} else {
+		reference = mathengine.FormatFinal(result.Final, 0)
+		referenceSteps = result.Steps
+	}
+
+	format := req.InputFormat
+	if format == normalizer.FormatASCII {
+		format = ""
+	}
+	check, err := mathengine.CheckWork(job.expression, reference, req.Steps, format)
+	if err != nil {
+		respondInvalidExpression(c, err)
+		return
+	}
+
+	_, _ = h.usageService.IncrementUsage(job.userID)
+
+	response := models.CheckWorkResponse{
+		Problem:  job.expression,
+		Valid:    check.FirstInvalid < 0,
+		Complete: check.Complete,
+		Steps:    check.Steps,
+	}
+	if check.FirstInvalid >= 0 {
+		response.FirstInvalidStep = &check.FirstInvalid
+		response.Hints = mathengine.Hints(job.expression, referenceSteps)
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"maths-solution-backend/mathengine"
+	"maths-solution-backend/models"
+	"maths-solution-backend/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type JobHandler struct {
+	math  *MathHandler
+	queue *services.JobQueue // nil without a database
+}
+
+func NewJobHandler(math *MathHandler, queue *services.JobQueue) *JobHandler {
+	return &JobHandler{math: math, queue: queue}
+}
+
+// SubmitJob validates a solve like SolveMath, reserves one solve of the
+// user's quota, then queues it and returns 202 with the job to poll. The
+// reservation is refunded if the job fails, so queued jobs cannot overrun
+// the daily limit.
+func (h *JobHandler) SubmitJob(c *gin.Context) {
+	var req models.SolveJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if h.queue == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Job queue unavailable"})
+		return
+	}
+	if req.WebhookURL != "" {
+		if !h.queue.WebhooksEnabled() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Webhooks are not enabled on this server"})
+			return
+		}
+		if err := h.queue.CheckWebhookURL(req.WebhookURL); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	job, ok := h.math.prepareSolve(c, req.SolveMathRequest)
+	if !ok {
+		return
+	}
+
+	reservation, usage, err := h.math.usageService.ReserveUsage(job.userID, 1, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check usage limit"})
+		return
+	}
+	if reservation.Granted == 0 {
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error": "Daily usage limit exceeded",
+			"usage": usage,
+		})
+		return
+	}
+
+	solveJob := &models.SolveJob{
+		UserID:     job.userID,
+		Expression: job.expression,
+		Solver:     job.solver.Name(),
+		Precision:  job.precision,
+		Mode:       job.mode,
+		WebhookURL: req.WebhookURL,
+		UsageDate:  reservation.Date,
+	}
+	if err := h.queue.Enqueue(c.Request.Context(), solveJob); err != nil {
+		_ = h.math.usageService.ReleaseUsage(reservation, 1)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue job"})
+		return
+	}
+
+	c.Header("Location", fmt.Sprintf("/api/jobs/%d", solveJob.ID))
+	c.JSON(http.StatusAccepted, services.JobResponse(solveJob))
+}
+
+// GetJob reports the status of one of the user's jobs, with the solution once
+// it has succeeded.
+func (h *JobHandler) GetJob(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userIDUint, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	if h.queue == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Job queue unavailable"})
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+		return
+	}
+
+	job, err := h.queue.Get(c.Request.Context(), userIDUint, uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch job"})
+		return
+	}
+	c.JSON(http.StatusOK, services.JobResponse(job))
+}
+
+// RunJob is the JobProcessor of the job queue: it solves a claimed job and
+// saves the result like SolveMath. Jobs submitted with a reservation are
+// already paid for, and refunded here when the result is not chargeable;
+// older jobs are charged on success. Unavailable solvers are worth
+// retrying; unsupported problems are not.
+func (h *MathHandler) RunJob(ctx context.Context, job *models.SolveJob) (*models.SolveMathResponse, error) {
+	solver, err := h.solvers.Get(job.Solver)
+	if err != nil {
+		return nil, err
+	}
+	sj := &solveJob{
+		userID:     job.UserID,
+		expression: job.Expression,
+		solver:     solver,
+		precision:  job.Precision,
+		mode:       job.Mode,
+		prepaid:    job.UsageDate != "",
+	}
+	if sj.mode == "" {
+		sj.mode = models.SolveModeFull
+	}
+
+	result, err := solver.Solve(ctx, services.SolveRequest{Expression: sj.expression, Precision: sj.precision})
+	if err != nil {
+		if errors.Is(err, mathengine.ErrUnsupported) || errors.Is(err, mathengine.ErrUndefined) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: solver %s unavailable: %v", services.ErrJobRetryable, solver.Name(), err)
+	}
+
+	response, err := h.finishSolve(ctx, sj, result)
+	if err != nil {
+		return nil, err
+	}
+	if sj.prepaid && !h.chargeable(result) {
+		reservation := &services.UsageReservation{UserID: job.UserID, Date: job.UsageDate, Granted: 1}
+		_ = h.usageService.ReleaseUsage(reservation, 1)
+	}
+	return &response, nil
+}
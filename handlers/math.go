@@ -1,13 +1,19 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 	"strconv"
 
 	"maths-solution-backend/config"
 	"maths-solution-backend/database"
+	"maths-solution-backend/mathengine"
 	"maths-solution-backend/models"
+	"maths-solution-backend/normalizer"
+	"maths-solution-backend/parser"
 	"maths-solution-backend/services"
 
 	"github.com/gin-gonic/gin"
@@ -15,15 +21,17 @@ import (
 
 type MathHandler struct {
 	config       *config.Config
-	aiService    *services.AIService
+	solvers      *services.SolverRegistry
+	engine       *mathengine.Engine
 	usageService *services.UsageService
 }
 
-func NewMathHandler(cfg *config.Config) *MathHandler {
+func NewMathHandler(cfg *config.Config, solvers *services.SolverRegistry) *MathHandler {
 	return &MathHandler{
 		config:       cfg,
-		aiService:    services.NewAIService(cfg),
-		usageService: services.NewUsageService(database.DB),
+		solvers:      solvers,
+		engine:       mathengine.NewEngine(),
+		usageService: services.NewUsageService(database.DB, cfg.Usage),
 	}
 }
 
@@ -34,24 +42,77 @@ func (h *MathHandler) SolveMath(c *gin.Context) {
 		return
 	}
 
+	job, ok := h.prepareSolve(c, req)
+	if !ok {
+		return
+	}
+
+	// Call solver; the request context is cancelled when the client disconnects
+	ctx := c.Request.Context()
+	result, err := job.solver.Solve(ctx, services.SolveRequest{Expression: job.expression, Precision: job.precision})
+	if ctx.Err() != nil {
+		abortCancelled(c, job)
+		return
+	}
+	if err != nil {
+		respondSolveError(c, job.solver, err)
+		return
+	}
+
+	response, err := h.finishSolve(ctx, job, result)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process solution"})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// solveJob is a validated solve request that passed the usage check.
+type solveJob struct {
+	userID     uint
+	expression string
+	solver     services.Solver
+	precision  int
+	mode       string // full, hints or next_step
+	prepaid    bool   // Usage already reserved, as for batch items and jobs
+}
+
+// prepareSolve authenticates, normalizes and checks quota for req. It writes
+// the error response and reports false when the solve cannot go ahead.
+func (h *MathHandler) prepareSolve(c *gin.Context, req models.SolveMathRequest) (*solveJob, bool) {
 	// Get user ID from context (set by auth middleware)
 	userID, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
+		return nil, false
 	}
 
 	userIDUint, ok := userID.(uint)
 	if !ok {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID"})
-		return
+		return nil, false
+	}
+
+	// Normalize to canonical ASCII; malformed input is rejected before it
+	// reaches a solver or spends quota
+	expression, err := normalizer.Normalize(req.Expression, req.InputFormat)
+	if err != nil {
+		respondInvalidExpression(c, err)
+		return nil, false
+	}
+
+	// Resolve the solver before spending any quota
+	solver, err := h.solvers.Get(req.Solver)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
 	}
 
 	// Check usage limit before processing
 	usage, err := h.usageService.CheckUsageLimit(userIDUint)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check usage limit"})
-		return
+		return nil, false
 	}
 
 	if usage.Exceeded {
@@ -59,39 +120,129 @@ func (h *MathHandler) SolveMath(c *gin.Context) {
 			"error": "Daily usage limit exceeded",
 			"usage": usage,
 		})
-		return
+		return nil, false
 	}
 
-	// Call AI service
-	aiResp, err := h.aiService.SolveMath(req.Expression)
-	if err != nil {
-		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "AI service unavailable: " + err.Error()})
-		return
+	mode := req.Mode
+	if mode == "" {
+		mode = models.SolveModeFull
 	}
 
-	// Convert steps to JSON
-	stepsJSON, err := json.Marshal(aiResp.Steps)
+	return &solveJob{
+		userID:     userIDUint,
+		expression: expression,
+		solver:     solver,
+		precision:  req.Precision,
+		mode:       mode,
+	}, true
+}
+
+// statusClientClosedRequest is the non-standard status logged for requests
+// the client abandoned before a response was written.
+const statusClientClosedRequest = 499
+
+// abortCancelled ends a solve whose client went away. Nothing is saved and no
+// quota is charged, since the user never received an answer.
+func abortCancelled(c *gin.Context, job *solveJob) {
+	logCancelled(c, job)
+	c.AbortWithStatus(statusClientClosedRequest)
+}
+
+func logCancelled(c *gin.Context, job *solveJob) {
+	log.Printf("[info] Solve of %q cancelled by user %d: %v", job.expression, job.userID, c.Request.Context().Err())
+}
+
+// solveErrorStatus maps a solver error to the HTTP status and message reported.
+func solveErrorStatus(solver services.Solver, err error) (int, string) {
+	if errors.Is(err, mathengine.ErrUnsupported) || errors.Is(err, mathengine.ErrUndefined) {
+		return http.StatusUnprocessableEntity, err.Error()
+	}
+	return http.StatusServiceUnavailable, "Solver " + solver.Name() + " unavailable: " + err.Error()
+}
+
+func respondSolveError(c *gin.Context, solver services.Solver, err error) {
+	status, message := solveErrorStatus(solver, err)
+	c.JSON(status, gin.H{"error": message})
+}
+
+// finishSolve verifies a successful result, saves it and counts it against
+// the user's daily limit. Cache hits are counted only when CACHE_HITS_COUNT_USAGE
+// is set, and a refuted answer is dropped from the cache.
+func (h *MathHandler) finishSolve(ctx context.Context, job *solveJob, result *services.SolveResult) (models.SolveMathResponse, error) {
+	// Round float noise such as 0.30000000000000004 from any solver
+	result.Final = mathengine.FormatFinal(result.Final, job.precision)
+
+	// Check the final answer independently of the solver that produced it
+	verification := mathengine.Verify(job.expression, result.Final)
+	if verification.Status == models.VerificationRefuted {
+		if cache := h.solvers.Cache(); cache != nil {
+			cache.Invalidate(ctx, services.CacheKey(job.solver.Name(), services.SolveRequest{Expression: job.expression, Precision: job.precision}))
+		}
+	}
+
+	// Convert steps and verification to JSON
+	stepsJSON, err := json.Marshal(result.Steps)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process solution"})
-		return
+		return models.SolveMathResponse{}, err
+	}
+	verificationJSON, err := json.Marshal(verification)
+	if err != nil {
+		return models.SolveMathResponse{}, err
 	}
 
-	// Save to database (best-effort). If it fails in dev, still return the AI result.
-	_ = database.DB.Create(&models.Solution{
-		UserID:      userIDUint,
-		Expression:  req.Expression,
-		StepsJSON:   string(stepsJSON),
-		FinalAnswer: aiResp.Final,
-	}).Error
+	revealed := revealedAtStart(job.mode, len(result.Steps))
+
+	// Save to database (best-effort). If it fails in dev, still return the solver result.
+	solution := models.Solution{
+		UserID:             job.userID,
+		Expression:         job.expression,
+		StepsJSON:          string(stepsJSON),
+		FinalAnswer:        result.Final,
+		VerificationStatus: verification.Status,
+		VerificationJSON:   string(verificationJSON),
+		Mode:               job.mode,
+		RevealedSteps:      revealed,
+	}
+	_ = database.DB.Create(&solution).Error
 
 	// Increment usage count after successful solve
-	_, _ = h.usageService.IncrementUsage(userIDUint)
+	if !job.prepaid && h.chargeable(result) {
+		_, _ = h.usageService.IncrementUsage(job.userID)
+	}
 
-	// Return response
-	c.JSON(http.StatusOK, models.SolveMathResponse{
-		Steps: aiResp.Steps,
-		Final: aiResp.Final,
-	})
+	response := models.SolveMathResponse{
+		SolutionID:    solution.ID,
+		Expression:    job.expression,
+		Mode:          job.mode,
+		Steps:         result.Steps[:revealed],
+		RevealedSteps: revealed,
+		TotalSteps:    len(result.Steps),
+		Solver:        result.Solver,
+		Cached:        result.Cached,
+	}
+	if job.mode == models.SolveModeHints {
+		response.Hints = mathengine.Hints(job.expression, result.Steps)
+	}
+	if answerAtStart(job.mode, len(result.Steps)) {
+		response.Final = result.Final
+		response.Verification = verification
+	}
+	return response, nil
+}
+
+// chargeable reports whether result counts against the daily limit.
+func (h *MathHandler) chargeable(result *services.SolveResult) bool {
+	return !result.Cached || h.config.Cache.CountHits
+}
+
+// respondInvalidExpression reports a 422 with the parse position when known.
+func respondInvalidExpression(c *gin.Context, err error) {
+	var parseErr *parser.Error
+	if errors.As(err, &parseErr) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid expression: " + err.Error(), "details": parseErr})
+		return
+	}
+	c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid expression: " + err.Error()})
 }
 
 func (h *MathHandler) GetHistory(c *gin.Context) {
@@ -133,6 +284,10 @@ func (h *MathHandler) GetHistory(c *gin.Context) {
 		return
 	}
 
+	for i := range solutions {
+		redactUnrevealed(&solutions[i])
+	}
+
 	c.JSON(http.StatusOK, models.HistoryResponse{
 		Solutions: solutions,
 		Total:     total,
@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"maths-solution-backend/database"
+	"maths-solution-backend/mathengine"
+	"maths-solution-backend/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CreatePractice generates practice problems on a topic or like one of the
+// user's saved solutions and stores them with their reference answers,
+// which stay hidden until each problem is graded.
+func (h *MathHandler) CreatePractice(c *gin.Context) {
+	var req models.PracticeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if (req.Topic == "") == (req.SolutionID == 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Provide either topic or solution_id"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userIDUint, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	if database.DB == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Practice requires the database"})
+		return
+	}
+
+	opts := mathengine.PracticeOptions{Difficulty: req.Difficulty, Count: req.Count, Nice: true}
+	if opts.Difficulty == "" {
+		opts.Difficulty = mathengine.DifficultyMedium
+	}
+	if opts.Count == 0 {
+		opts.Count = 5
+	}
+	if req.NiceAnswers != nil {
+		opts.Nice = *req.NiceAnswers
+	}
+
+	set := models.PracticeSet{UserID: userIDUint, Topic: req.Topic, Difficulty: opts.Difficulty}
+	var problems []mathengine.PracticeProblem
+	var err error
+	if req.SolutionID != 0 {
+		var solution models.Solution
+		if err := database.DB.Where("id = ? AND user_id = ?", req.SolutionID, userIDUint).First(&solution).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Solution not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch solution"})
+			return
+		}
+		set.SourceSolutionID = &solution.ID
+		set.Topic = mathengine.DetectTopic(solution.Expression)
+		problems, err = h.engine.PracticeLike(solution.Expression, opts)
+	} else {
+		problems, err = h.engine.GeneratePractice(req.Topic, opts)
+	}
+	if errors.Is(err, mathengine.ErrUnsupported) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	for i, p := range problems {
+		set.Problems = append(set.Problems, models.PracticeProblem{
+			Position:        i + 1,
+			Problem:         p.Problem,
+			ReferenceAnswer: p.Answer,
+		})
+	}
+	if err := database.DB.Create(&set).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save practice problems"})
+		return
+	}
+	c.JSON(http.StatusCreated, practiceSetResponse(&set))
+}
+
+// GetPractice returns one of the user's practice sets with its grading so far.
+func (h *MathHandler) GetPractice(c *gin.Context) {
+	set, ok := loadPracticeSet(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, practiceSetResponse(set))
+}
+
+// GradePractice grades submitted answers to problems of a practice set. An
+// answer is correct when it checks out against the problem independently of
+// the stored reference and is in the form the problem asks for. Each problem
+// is graded once: the reference answer is revealed with its grade, so
+// problems already graded are refused with 409.
+func (h *MathHandler) GradePractice(c *gin.Context) {
+	var req models.GradePracticeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	set, ok := loadPracticeSet(c)
+	if !ok {
+		return
+	}
+
+	byID := map[uint]*models.PracticeProblem{}
+	for i := range set.Problems {
+		byID[set.Problems[i].ID] = &set.Problems[i]
+	}
+
+	// Check every answer before grading any, so a refused request changes nothing
+	submitted := map[uint]bool{}
+	for _, answer := range req.Answers {
+		id := strconv.FormatUint(uint64(answer.ProblemID), 10)
+		problem, found := byID[answer.ProblemID]
+		if !found {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Problem " + id + " is not in this practice set"})
+			return
+		}
+		if submitted[answer.ProblemID] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Problem " + id + " is answered more than once"})
+			return
+		}
+		if problem.GradedAt != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Problem " + id + " has already been graded"})
+			return
+		}
+		submitted[answer.ProblemID] = true
+	}
+
+	response := models.GradePracticeResponse{PracticeSetID: set.ID}
+	now := time.Now()
+	for _, answer := range req.Answers {
+		problem := byID[answer.ProblemID]
+		correct, verification := mathengine.GradeAnswer(problem.Problem, problem.ReferenceAnswer, answer.Answer)
+		problem.SubmittedAnswer = answer.Answer
+		problem.Correct = &correct
+		problem.Attempts++
+		problem.GradedAt = &now
+		// Only the first of concurrent requests grades the problem
+		res := database.DB.Model(problem).Where("graded_at IS NULL").Updates(map[string]interface{}{
+			"submitted_answer": problem.SubmittedAnswer,
+			"correct":          correct,
+			"attempts":         problem.Attempts,
+			"graded_at":        now,
+		})
+		if res.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save grade"})
+			return
+		}
+		if res.RowsAffected == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Problem " + strconv.FormatUint(uint64(problem.ID), 10) + " has already been graded"})
+			return
+		}
+
+		if correct {
+			response.Correct++
+		}
+		response.Results = append(response.Results, models.PracticeGrade{
+			ProblemID:       problem.ID,
+			Problem:         problem.Problem,
+			Answer:          answer.Answer,
+			Correct:         correct,
+			ReferenceAnswer: problem.ReferenceAnswer,
+			Verification:    verification,
+		})
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// loadPracticeSet fetches the user's practice set named by the :id parameter
+// with its problems, writing the error response when it cannot.
+func loadPracticeSet(c *gin.Context) (*models.PracticeSet, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, false
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid practice set ID"})
+		return nil, false
+	}
+	if database.DB == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Practice requires the database"})
+		return nil, false
+	}
+
+	var set models.PracticeSet
+	err = database.DB.Preload("Problems", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).
+		Where("id = ? AND user_id = ?", id, userID).
+		First(&set).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Practice set not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch practice set"})
+		return nil, false
+	}
+	return &set, true
+}
+
+func practiceSetResponse(set *models.PracticeSet) models.PracticeSetResponse {
+	response := models.PracticeSetResponse{
+		ID:               set.ID,
+		Topic:            set.Topic,
+		Difficulty:       set.Difficulty,
+		SourceSolutionID: set.SourceSolutionID,
+		Problems:         []models.PracticeProblemResponse{},
+	}
+	for _, p := range set.Problems {
+		problem := models.PracticeProblemResponse{
+			ID:       p.ID,
+			Position: p.Position,
+			Problem:  p.Problem,
+			Correct:  p.Correct,
+			Attempts: p.Attempts,
+		}
+		if p.GradedAt != nil {
+			problem.Answer = p.ReferenceAnswer
+		}
+		response.Problems = append(response.Problems, problem)
+	}
+	return response
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"maths-solution-backend/database"
+	"maths-solution-backend/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// revealedAtStart is how many of total steps a solve in mode shows at once.
+func revealedAtStart(mode string, total int) int {
+	switch mode {
+	case models.SolveModeHints:
+		return 0
+	case models.SolveModeNextStep:
+		return min(1, total)
+	}
+	return total
+}
+
+// answerAtStart reports whether a solve in mode returns its final answer and
+// verification at once. Hints mode never does, even without steps to hint:
+// the answer then comes from the first reveal.
+func answerAtStart(mode string, total int) bool {
+	return mode != models.SolveModeHints && revealedAtStart(mode, total) == total
+}
+
+// redactUnrevealed strips the steps, answer and verification a hint-mode
+// solution has not revealed yet, so history cannot give them away.
+func redactUnrevealed(solution *models.Solution) {
+	if solution.Mode == "" || solution.Mode == models.SolveModeFull {
+		return
+	}
+	var steps []models.SolutionStep
+	if err := json.Unmarshal([]byte(solution.StepsJSON), &steps); err != nil {
+		return
+	}
+	if solution.RevealedSteps >= len(steps) && (len(steps) > 0 || solution.Mode != models.SolveModeHints) {
+		return
+	}
+	stepsJSON, _ := json.Marshal(steps[:solution.RevealedSteps])
+	solution.StepsJSON = string(stepsJSON)
+	solution.FinalAnswer = ""
+	solution.VerificationStatus = ""
+	solution.VerificationJSON = ""
+}
+
+// RevealStep shows the next hidden step of one of the user's solutions and
+// records it on the Solution. The final answer comes with the last step;
+// further calls just repeat the complete solution.
+func (h *MathHandler) RevealStep(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid solution ID"})
+		return
+	}
+
+	var solution models.Solution
+	var steps []models.SolutionStep
+	var revealed *models.SolutionStep
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// Lock the row so concurrent reveals each get a different step
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND user_id = ?", id, userID).
+			First(&solution).Error; err != nil {
+			return err
+		}
+		if err := json.Unmarshal([]byte(solution.StepsJSON), &steps); err != nil {
+			return err
+		}
+		if solution.Mode == "" || solution.Mode == models.SolveModeFull || solution.RevealedSteps >= len(steps) {
+			solution.RevealedSteps = len(steps)
+			return nil
+		}
+		revealed = &steps[solution.RevealedSteps]
+		solution.RevealedSteps++
+		return tx.Model(&solution).Update("revealed_steps", solution.RevealedSteps).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Solution not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reveal step"})
+		return
+	}
+
+	mode := solution.Mode
+	if mode == "" {
+		mode = models.SolveModeFull
+	}
+	response := models.RevealStepResponse{
+		SolutionID:    solution.ID,
+		Mode:          mode,
+		Step:          revealed,
+		Steps:         steps[:solution.RevealedSteps],
+		RevealedSteps: solution.RevealedSteps,
+		TotalSteps:    len(steps),
+		Done:          solution.RevealedSteps == len(steps),
+	}
+	if response.Done {
+		response.Final = solution.FinalAnswer
+		if solution.VerificationJSON != "" {
+			var verification models.Verification
+			if err := json.Unmarshal([]byte(solution.VerificationJSON), &verification); err == nil {
+				response.Verification = &verification
+			}
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
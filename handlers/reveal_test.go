@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"testing"
+
+	"maths-solution-backend/models"
+)
+
+func TestRevealedAtStart(t *testing.T) {
+	tests := []struct {
+		mode  string
+		total int
+		want  int
+	}{
+		{models.SolveModeFull, 4, 4},
+		{"", 4, 4},
+		{models.SolveModeHints, 4, 0},
+		{models.SolveModeNextStep, 4, 1},
+		{models.SolveModeNextStep, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := revealedAtStart(tt.mode, tt.total); got != tt.want {
+			t.Errorf("revealedAtStart(%q, %d) = %d, want %d", tt.mode, tt.total, got, tt.want)
+		}
+	}
+}
+
+func TestAnswerAtStart(t *testing.T) {
+	tests := []struct {
+		mode  string
+		total int
+		want  bool
+	}{
+		{models.SolveModeFull, 4, true},
+		{models.SolveModeFull, 0, true},
+		{models.SolveModeHints, 4, false},
+		{models.SolveModeHints, 0, false},
+		{models.SolveModeNextStep, 4, false},
+		{models.SolveModeNextStep, 1, true},
+		{models.SolveModeNextStep, 0, true},
+	}
+	for _, tt := range tests {
+		if got := answerAtStart(tt.mode, tt.total); got != tt.want {
+			t.Errorf("answerAtStart(%q, %d) = %v, want %v", tt.mode, tt.total, got, tt.want)
+		}
+	}
+}
+
+func TestRedactUnrevealed(t *testing.T) {
+	const steps = `[{"index":1,"latex":"a"},{"index":2,"latex":"b"}]`
+	tests := []struct {
+		name     string
+		mode     string
+		revealed int
+		stored   string // Steps saved, when not steps
+		steps    string
+		redacted bool
+	}{
+		{"full", models.SolveModeFull, 0, "", steps, false},
+		{"older row", "", 0, "", steps, false},
+		{"hints", models.SolveModeHints, 0, "", `[]`, true},
+		{"hints without steps", models.SolveModeHints, 0, `[]`, `[]`, true},
+		{"next step", models.SolveModeNextStep, 1, "", `[{"index":1,"latex":"a"}]`, true},
+		{"all revealed", models.SolveModeNextStep, 2, "", steps, false},
+	}
+	for _, tt := range tests {
+		stored := steps
+		if tt.stored != "" {
+			stored = tt.stored
+		}
+		solution := models.Solution{
+			StepsJSON:          stored,
+			FinalAnswer:        "2",
+			VerificationStatus: "verified",
+			VerificationJSON:   "{}",
+			Mode:               tt.mode,
+			RevealedSteps:      tt.revealed,
+		}
+		redactUnrevealed(&solution)
+		if solution.StepsJSON != tt.steps {
+			t.Errorf("%s: steps = %s, want %s", tt.name, solution.StepsJSON, tt.steps)
+		}
+		if redacted := solution.FinalAnswer == "" && solution.VerificationStatus == "" && solution.VerificationJSON == ""; redacted != tt.redacted {
+			t.Errorf("%s: answer redacted = %v, want %v", tt.name, redacted, tt.redacted)
+		}
+	}
+}
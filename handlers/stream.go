@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http"
+
+	"maths-solution-backend/models"
+	"maths-solution-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SolveMathStream solves like SolveMath but sends each step as a Server-Sent
+// Event ("step") as soon as the solver produces it, then a "final" event with
+// the complete response or an "error" event. Problems found before solving
+// starts are reported as ordinary JSON errors.
+func (h *MathHandler) SolveMathStream(c *gin.Context) {
+	var req models.SolveMathRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Streaming shows every step as it comes, which only full mode allows
+	if req.Mode != "" && req.Mode != models.SolveModeFull {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Streaming supports only mode full"})
+		return
+	}
+
+	job, ok := h.prepareSolve(c, req)
+	if !ok {
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // Keep proxies from buffering the stream
+	c.Status(http.StatusOK)
+
+	send := func(event string, data interface{}) {
+		c.SSEvent(event, data)
+		c.Writer.Flush()
+	}
+
+	ctx := c.Request.Context()
+	result, err := services.SolveStream(ctx, job.solver, services.SolveRequest{Expression: job.expression, Precision: job.precision}, func(step models.SolutionStep) {
+		send("step", step)
+	})
+	if ctx.Err() != nil {
+		// The stream is already open, so there is no status left to set
+		logCancelled(c, job)
+		return
+	}
+	if err != nil {
+		status, message := solveErrorStatus(job.solver, err)
+		send("error", gin.H{"error": message, "status": status})
+		return
+	}
+
+	// Persist and count once, with the complete solution
+	response, err := h.finishSolve(ctx, job, result)
+	if err != nil {
+		send("error", gin.H{"error": "Failed to process solution", "status": http.StatusInternalServerError})
+		return
+	}
+	send("final", response)
+}
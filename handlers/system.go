@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"strings"
+
+	"maths-solution-backend/database"
+	"maths-solution-backend/mathengine"
+	"maths-solution-backend/models"
+	"maths-solution-backend/normalizer"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SolveSystem solves a system of linear equations, or analyses a matrix,
+// with exact Gaussian elimination. It is stored and counted like SolveMath.
+func (h *MathHandler) SolveSystem(c *gin.Context) {
+	var req models.SolveSystemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if (len(req.Equations) == 0) == (len(req.Matrix) == 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Provide either equations or matrix"})
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	userIDUint, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	// Normalize the input before spending any quota
+	var expression string
+	var solve func() (*mathengine.SystemResult, error)
+	if len(req.Equations) > 0 {
+		equations := make([]string, len(req.Equations))
+		for i, eq := range req.Equations {
+			normalized, err := normalizer.Normalize(eq, req.InputFormat)
+			if err != nil {
+				respondInvalidExpression(c, err)
+				return
+			}
+			equations[i] = normalized
+		}
+		expression = strings.Join(equations, "; ")
+		solve = func() (*mathengine.SystemResult, error) { return h.engine.SolveSystem(equations) }
+	} else {
+		rows := make([][]*big.Rat, len(req.Matrix))
+		for i, row := range req.Matrix {
+			for _, entry := range row {
+				// JSON numbers such as 2.5E-3 are exact decimals; only strings
+				// are written in the input format
+				if entry.Number {
+					value, err := mathengine.ParseEntry(entry.Text)
+					if err != nil {
+						c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid matrix: entry " + entry.Text + " is not a rational number"})
+						return
+					}
+					rows[i] = append(rows[i], value)
+					continue
+				}
+				normalized, err := normalizer.Normalize(entry.Text, req.InputFormat)
+				if err != nil {
+					respondInvalidExpression(c, err)
+					return
+				}
+				value, err := mathengine.ParseEntry(normalized)
+				if err != nil {
+					respondInvalidExpression(c, err)
+					return
+				}
+				rows[i] = append(rows[i], value)
+			}
+		}
+		matrix, err := mathengine.NewMatrix(rows)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid matrix: " + err.Error()})
+			return
+		}
+		expression = matrix.String()
+		solve = func() (*mathengine.SystemResult, error) { return h.engine.AnalyzeMatrix(matrix) }
+	}
+
+	// Check usage limit before processing
+	usage, err := h.usageService.CheckUsageLimit(userIDUint)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check usage limit"})
+		return
+	}
+
+	if usage.Exceeded {
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error": "Daily usage limit exceeded",
+			"usage": usage,
+		})
+		return
+	}
+
+	result, err := solve()
+	if errors.Is(err, mathengine.ErrUnsupported) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		respondInvalidExpression(c, err)
+		return
+	}
+
+	stepsJSON, err := json.Marshal(result.Steps)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process solution"})
+		return
+	}
+
+	// Save to database (best-effort)
+	_ = database.DB.Create(&models.Solution{
+		UserID:      userIDUint,
+		Expression:  expression,
+		StepsJSON:   string(stepsJSON),
+		FinalAnswer: result.Final,
+	}).Error
+
+	// Increment usage count after successful solve
+	_, _ = h.usageService.IncrementUsage(userIDUint)
+
+	response := models.SolveSystemResponse{
+		Expression: expression,
+		Variables:  result.Variables,
+		Steps:      result.Steps,
+		Final:      result.Final,
+		Rank:       result.Rank,
+	}
+	if result.Determinant != nil {
+		response.Determinant = result.Determinant.RatString()
+	}
+	if result.Inverse != nil {
+		response.Inverse = result.Inverse.Strings()
+	}
+	for _, v := range result.Eigenvalues {
+		response.Eigenvalues = append(response.Eigenvalues, v.String())
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 	"os/signal"
@@ -28,8 +29,9 @@ func main() {
 		}
 	}
 
-	// Setup routes
-	router := routes.SetupRoutes(cfg)
+	// Setup routes and background workers
+	ctx, stopWorkers := context.WithCancel(context.Background())
+	router, waitWorkers := routes.SetupRoutes(ctx, cfg)
 
 	// Start server
 	log.Printf("Starting server on port %s", cfg.Server.Port)
@@ -47,4 +49,8 @@ func main() {
 	<-quit
 
 	log.Println("Shutting down server...")
+
+	// Let the job workers put interrupted jobs back on the queue
+	stopWorkers()
+	waitWorkers()
 }
@@ -0,0 +1,168 @@
+package mathengine
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"maths-solution-backend/normalizer"
+)
+
+// Answer is a final answer as written by a solver or a student: a set of
+// values for a variable, or one of the special outcomes.
+type Answer struct {
+	Var        string
+	Values     []Expr
+	Decimals   []int // Fractional digits written for each value, -1 when exact
+	NoSolution bool
+	AllReals   bool
+}
+
+var (
+	answerWrappers = strings.NewReplacer(
+		"$", "", `\(`, "", `\)`, "", `\[`, "", `\]`, "", `\displaystyle`, "",
+		`\approx`, "=", "≈", "=", `\quad`, ";", `\qquad`, ";", `\lor`, ";", `\vee`, ";",
+		`\text{ or }`, ";", `\text{or}`, ";", `\text{ and }`, ";", `\text{and}`, ";",
+		`\pm`, "±", "+-", "±",
+	)
+	answerWords  = regexp.MustCompile(`(?i)\s+(or|and)\s+`)
+	setNotation  = regexp.MustCompile(`^\s*([a-zA-Z](?:_\{?\w+\}?)?)\s*(?:\\in|∈)\s*`)
+	boxed        = regexp.MustCompile(`\\boxed\{(.*)\}`)
+	subscriptTag = regexp.MustCompile(`_\{?\w+\}?$`)
+	decimalPart  = regexp.MustCompile(`\d\.(\d+)(?:[eE]([+-]?\d+))?`)
+)
+
+var noSolutionPhrases = []string{"no solution", "no real solution", "no real root", `\emptyset`, `\varnothing`, "∅", "{}", `\{\}`}
+
+var allRealsPhrases = []string{"all real", "infinitely many", "any real", `\mathbb{r}`, "ℝ", "identity"}
+
+// ParseAnswer reads answers such as "x = 2 or x = -3", "x_1 = 1, x_2 = 2",
+// "x = 1 \pm \sqrt{2}", "x \in \{2, 3\}", "\frac{1}{2}" or "no real solution".
+func ParseAnswer(text string) (*Answer, error) {
+	s := strings.TrimSpace(text)
+	if m := boxed.FindStringSubmatch(s); m != nil {
+		s = m[1]
+	}
+	s = answerWrappers.Replace(s)
+	s = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "."))
+
+	lower := strings.ToLower(s)
+	for _, phrase := range noSolutionPhrases {
+		if strings.Contains(lower, phrase) {
+			return &Answer{NoSolution: true}, nil
+		}
+	}
+	for _, phrase := range allRealsPhrases {
+		if strings.Contains(lower, phrase) {
+			return &Answer{AllReals: true}, nil
+		}
+	}
+
+	answer := &Answer{}
+	if m := setNotation.FindStringSubmatch(s); m != nil {
+		answer.Var = subscriptTag.ReplaceAllString(m[1], "")
+		s = s[len(m[0]):]
+	}
+	s = strings.TrimSpace(s)
+	for _, pair := range [][2]string{{`\{`, `\}`}, {"{", "}"}, {`\left\{`, `\right\}`}} {
+		if strings.HasPrefix(s, pair[0]) && strings.HasSuffix(s, pair[1]) {
+			s = s[len(pair[0]) : len(s)-len(pair[1])]
+			break
+		}
+	}
+
+	s = answerWords.ReplaceAllString(s, ";")
+	for _, part := range splitTopLevel(s) {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if i := strings.LastIndex(part, "="); i >= 0 {
+			name := strings.TrimSpace(part[:i])
+			if j := strings.LastIndex(name, "="); j >= 0 {
+				name = strings.TrimSpace(name[:j])
+			}
+			name = subscriptTag.ReplaceAllString(name, "")
+			if answer.Var == "" {
+				answer.Var = name
+			}
+			part = strings.TrimSpace(part[i+1:])
+		}
+
+		for _, variant := range expandPlusMinus(part) {
+			value, err := parseAnswerValue(variant)
+			if err != nil {
+				return nil, fmt.Errorf("cannot read answer %q: %w", part, err)
+			}
+			answer.Values = append(answer.Values, value)
+			answer.Decimals = append(answer.Decimals, decimalsIn(variant))
+		}
+	}
+	if len(answer.Values) == 0 {
+		return nil, fmt.Errorf("no value found in answer %q", text)
+	}
+	return answer, nil
+}
+
+// splitTopLevel splits on ";" and on commas outside brackets, so that
+// "log(8, 2), 3" yields two parts.
+func splitTopLevel(s string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, r := range s {
+		switch r {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case ',', ';':
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func expandPlusMinus(s string) []string {
+	if !strings.Contains(s, "±") {
+		return []string{s}
+	}
+	return []string{strings.Replace(s, "±", "+", 1), strings.Replace(s, "±", "-", 1)}
+}
+
+// parseAnswerValue accepts ASCII first and falls back to LaTeX.
+func parseAnswerValue(s string) (Expr, error) {
+	e, err := Parse(s)
+	if err == nil {
+		return e, nil
+	}
+	ascii, latexErr := normalizer.ToASCII(s, normalizer.FormatLatex)
+	if latexErr != nil {
+		return nil, err
+	}
+	return Parse(ascii)
+}
+
+// decimalsIn returns the number of fractional digits of the most precise
+// decimal literal in s, or -1 when s has none. An exponent moves the point:
+// 2.5e-3 has four, and 2.5e3 none.
+func decimalsIn(s string) int {
+	most := -1
+	for _, m := range decimalPart.FindAllStringSubmatch(s, -1) {
+		decimals := len(m[1])
+		if m[2] != "" {
+			exponent, err := strconv.Atoi(m[2])
+			if err != nil {
+				continue
+			}
+			decimals = max(0, decimals-exponent)
+		}
+		if decimals > most {
+			most = decimals
+		}
+	}
+	return most
+}
@@ -0,0 +1,494 @@
+package mathengine
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"maths-solution-backend/models"
+	"maths-solution-backend/normalizer"
+	"maths-solution-backend/parser"
+)
+
+// WorkCheck is the outcome of checking a student's worked steps.
+type WorkCheck struct {
+	Steps        []models.StepCheck
+	FirstInvalid int  // Index of the first invalid step, -1 when there is none
+	Complete     bool // No invalid step, and the last one is a correct answer in final form
+}
+
+// workKind is how steps of a problem are compared: as solution sets of
+// equations, or as expressions equal to the problem (for derivatives, to
+// its derivative; for integrals, as antiderivatives of the integrand).
+type workKind int
+
+const (
+	workExpression workKind = iota
+	workDerivative
+	workIntegral
+	workEquation
+)
+
+// workProblem is the problem the steps start from.
+type workProblem struct {
+	kind     workKind
+	variable string
+	value    Expr         // Expression kinds: what every step must equal
+	set      *solutionSet // Equations: what every step must have as solutions
+}
+
+// solutionSet is the solutions of an equation step: every real number, or
+// the listed roots (none means no solution).
+type solutionSet struct {
+	all      bool
+	roots    []Expr
+	decimals []int // Fractional digits each root was written with, -1 when exact
+}
+
+// CheckWork checks each transition of a student's steps, starting from
+// problem (canonical ASCII), and stops at the first one that is not
+// equivalent. Steps are read as ASCII or LaTeX whatever format says; format
+// only decides which is tried first. Steps that cannot be read or compared
+// are reported unverifiable and skipped over. reference is the answer of a
+// solver, used to judge whether the last step is in final form; it may be
+// empty.
+func CheckWork(problem, reference string, steps []string, format string) (*WorkCheck, error) {
+	p, err := readProblem(problem)
+	if err != nil {
+		return nil, err
+	}
+
+	check := &WorkCheck{FirstInvalid: -1}
+	prevValue, prevSet := p.value, p.set
+	prevLabel := "the problem"
+	last := ""
+	for i, raw := range steps {
+		result := models.StepCheck{Index: i, Input: raw}
+		if check.FirstInvalid >= 0 {
+			result.Status = models.StepUnchecked
+			check.Steps = append(check.Steps, result)
+			continue
+		}
+
+		var ok bool
+		if p.kind == workEquation {
+			var set *solutionSet
+			set, result.Canonical, err = readEquationStep(raw, format, p.variable)
+			if err == nil {
+				ok = compareSets(&result, prevSet, set, prevLabel, p.variable)
+				prevSet = set
+			}
+		} else {
+			var value Expr
+			value, result.Canonical, err = readExpressionStep(raw, format, p)
+			if err == nil {
+				ok = compareValues(&result, prevValue, value, prevLabel, p.kind, decimalsIn(raw))
+				prevValue = value
+			}
+		}
+		if err != nil {
+			result.Status = models.StepUnverifiable
+			result.Explanation = "Could not read this step: " + err.Error()
+			check.Steps = append(check.Steps, result)
+			continue
+		}
+		if !ok && result.Status == models.StepInvalid {
+			check.FirstInvalid = i
+		}
+		check.Steps = append(check.Steps, result)
+		prevLabel = fmt.Sprintf("step %d", i+1)
+		last = raw
+	}
+
+	if check.FirstInvalid < 0 && last != "" {
+		check.Complete = isFinalAnswer(problem, p, last, reference, format)
+	}
+	return check, nil
+}
+
+func readProblem(problem string) (*workProblem, error) {
+	node, err := parser.Parse(problem)
+	if err != nil {
+		return nil, err
+	}
+	command, variable, body := splitCommand(node)
+
+	if rel, ok := body.(*parser.Relation); ok || command == "solve" {
+		var left, right Expr
+		if ok {
+			if rel.Op != "=" {
+				return nil, fmt.Errorf("%w: inequalities", ErrUnsupported)
+			}
+			if left, err = FromNode(rel.Left); err != nil {
+				return nil, err
+			}
+			if right, err = FromNode(rel.Right); err != nil {
+				return nil, err
+			}
+		} else {
+			if left, err = FromNode(body); err != nil {
+				return nil, err
+			}
+			right = NewInt(0)
+		}
+		if variable == "" {
+			vars := FreeSymbols(Sub(left, right))
+			if len(vars) != 1 {
+				return nil, fmt.Errorf("%w: equations in %d variables", ErrUnsupported, len(vars))
+			}
+			variable = vars[0]
+		}
+		set, err := equationSet(left, right, variable)
+		if err != nil {
+			return nil, err
+		}
+		return &workProblem{kind: workEquation, variable: variable, set: set}, nil
+	}
+
+	e, err := FromNode(body)
+	if err != nil {
+		return nil, err
+	}
+	switch command {
+	case "diff", "derivative", "differentiate":
+		if variable, err = unknownOf(e, variable, command); err != nil {
+			return nil, err
+		}
+		derivative, err := Diff(e, variable)
+		if err != nil {
+			return nil, err
+		}
+		return &workProblem{kind: workDerivative, variable: variable, value: derivative}, nil
+	case "integrate", "integral", "antiderivative":
+		if variable, err = unknownOf(e, variable, command); err != nil {
+			return nil, err
+		}
+		return &workProblem{kind: workIntegral, variable: variable, value: e}, nil
+	}
+	return &workProblem{kind: workExpression, value: e}, nil
+}
+
+// readStepNode reads a step in format, falling back to the other of ASCII
+// and LaTeX. A leading "=" or "=>" chaining it to the previous step is
+// dropped.
+func readStepNode(raw, format string) (parser.Node, error) {
+	s := strings.TrimSpace(raw)
+	for _, prefix := range []string{`\Rightarrow`, `\implies`, "=>", "⇒", "="} {
+		s = strings.TrimSpace(strings.TrimPrefix(s, prefix))
+	}
+	formats := []string{normalizer.FormatASCII, normalizer.FormatLatex}
+	if format == normalizer.FormatLatex {
+		formats[0], formats[1] = formats[1], formats[0]
+	} else if format != "" && format != normalizer.FormatASCII {
+		formats = []string{format}
+	}
+
+	var firstErr error
+	for _, f := range formats {
+		node, err := normalizer.Parse(s, f)
+		if err == nil {
+			return node, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, firstErr
+}
+
+// readExpressionStep reads a step of an expression problem as the value it
+// is compared by. A step "a = b" must have a equal to b; b is kept.
+func readExpressionStep(raw, format string, p *workProblem) (Expr, string, error) {
+	node, err := readStepNode(raw, format)
+	if err != nil {
+		return nil, "", err
+	}
+	canonical := parser.Format(node)
+
+	var e Expr
+	if rel, ok := node.(*parser.Relation); ok && rel.Op == "=" {
+		left, err := stepValue(rel.Left, p)
+		if err != nil {
+			return nil, "", err
+		}
+		right, err := stepValue(rel.Right, p)
+		if err != nil {
+			return nil, "", err
+		}
+		if v := compareExpressions(left, right, -1); v.Status == models.VerificationRefuted {
+			return nil, "", fmt.Errorf("its two sides are not equal")
+		}
+		e = right
+	} else if e, err = stepValue(node, p); err != nil {
+		return nil, "", err
+	}
+	return e, canonical, nil
+}
+
+// stepValue evaluates a step as compared for p: an integral step is its
+// integrand when written as an integral and the derivative of the
+// antiderivative otherwise; a derivative written out is evaluated.
+func stepValue(node parser.Node, p *workProblem) (Expr, error) {
+	command, variable, body := splitCommand(node)
+	e, err := FromNode(body)
+	if err != nil {
+		return nil, err
+	}
+	if variable == "" {
+		variable = p.variable
+	}
+	switch command {
+	case "diff", "derivative", "differentiate":
+		if variable, err = unknownOf(e, variable, command); err != nil {
+			return nil, err
+		}
+		return Diff(e, variable)
+	case "integrate", "integral", "antiderivative":
+		if p.kind != workIntegral {
+			return nil, fmt.Errorf("%w: an integral in this problem", ErrUnsupported)
+		}
+		return e, nil
+	case "", "simplify", "evaluate", "expand", "factor":
+		if p.kind == workIntegral {
+			return Diff(e, p.variable)
+		}
+		return e, nil
+	}
+	return nil, fmt.Errorf("%w: %s in a step", ErrUnsupported, command)
+}
+
+// readEquationStep reads a step of an equation problem, either as an
+// equation or as an answer such as "x = 2 or x = -3", into its solutions.
+func readEquationStep(raw, format, variable string) (*solutionSet, string, error) {
+	if node, err := readStepNode(raw, format); err == nil {
+		if rel, ok := node.(*parser.Relation); ok && rel.Op == "=" {
+			left, err := FromNode(rel.Left)
+			if err != nil {
+				return nil, "", err
+			}
+			right, err := FromNode(rel.Right)
+			if err != nil {
+				return nil, "", err
+			}
+			set, err := equationSet(left, right, variable)
+			return set, parser.Format(node), err
+		}
+	}
+
+	answer, err := ParseAnswer(raw)
+	if err != nil {
+		return nil, "", fmt.Errorf("expected an equation or a solution")
+	}
+	if answer.Var != "" && answer.Var != variable {
+		return nil, "", fmt.Errorf("it solves for %s, the unknown is %s", answer.Var, variable)
+	}
+	set := &solutionSet{all: answer.AllReals}
+	parts := make([]string, len(answer.Values))
+	for i, value := range answer.Values {
+		if len(FreeSymbols(value)) > 0 {
+			return nil, "", fmt.Errorf("%s = %s is not a number", variable, value.String())
+		}
+		set.roots = append(set.roots, value)
+		set.decimals = append(set.decimals, answer.Decimals[i])
+		parts[i] = variable + " = " + value.String()
+	}
+	canonical := strings.Join(parts, " or ")
+	switch {
+	case answer.AllReals:
+		canonical = "all real numbers"
+	case answer.NoSolution:
+		canonical = "no solution"
+	}
+	return set, canonical, nil
+}
+
+// equationSet solves left = right for variable exactly with the local engine.
+func equationSet(left, right Expr, variable string) (*solutionSet, error) {
+	diff := Expand(Sub(left, right))
+	for _, v := range FreeSymbols(diff) {
+		if v != variable {
+			return nil, fmt.Errorf("%w: it has another unknown, %s", ErrUnsupported, v)
+		}
+	}
+	ref := &session{}
+	if err := ref.solveEquation(left, right, ""); err != nil {
+		return nil, err
+	}
+	set := &solutionSet{all: ref.final == "all real numbers", roots: ref.rootExprs}
+	for range set.roots {
+		set.decimals = append(set.decimals, -1)
+	}
+	return set, nil
+}
+
+// compareSets marks result valid when cur has exactly the solutions of prev,
+// and otherwise explains the first solution lost or gained.
+func compareSets(result *models.StepCheck, prev, cur *solutionSet, prevLabel, variable string) bool {
+	result.Method = MethodSymbolic
+	switch {
+	case prev.all && cur.all:
+	case prev.all:
+		result.Status = models.StepInvalid
+		result.Explanation = fmt.Sprintf("Every real %s satisfies %s, but this step only allows %s.", variable, prevLabel, describeSet(cur, variable))
+		return false
+	case cur.all:
+		result.Status = models.StepInvalid
+		result.Explanation = fmt.Sprintf("This step holds for every real %s, but %s only allows %s.", variable, prevLabel, describeSet(prev, variable))
+		return false
+	default:
+		for i, root := range prev.roots {
+			if !setContains(cur, root, prev.decimals[i]) {
+				result.Status = models.StepInvalid
+				result.Explanation = fmt.Sprintf("%s = %s satisfies %s but not this step, so a solution was lost.", variable, root.String(), prevLabel)
+				return false
+			}
+		}
+		for i, root := range cur.roots {
+			if !setContains(prev, root, cur.decimals[i]) {
+				result.Status = models.StepInvalid
+				result.Explanation = fmt.Sprintf("%s = %s satisfies this step but not %s, so it is not a solution.", variable, root.String(), prevLabel)
+				return false
+			}
+		}
+	}
+	result.Status = models.StepValid
+	result.Explanation = "Same solutions as " + prevLabel + "."
+	return true
+}
+
+func describeSet(set *solutionSet, variable string) string {
+	if len(set.roots) == 0 {
+		return "no solution"
+	}
+	parts := make([]string, len(set.roots))
+	for i, root := range set.roots {
+		parts[i] = variable + " = " + root.String()
+	}
+	return strings.Join(parts, " or ")
+}
+
+func setContains(set *solutionSet, value Expr, decimals int) bool {
+	x, err := Eval(value, nil)
+	if err != nil {
+		return false
+	}
+	for i, root := range set.roots {
+		r, err := Eval(root, nil)
+		if err != nil {
+			continue
+		}
+		tolerance := relativeTolerance * math.Max(1, math.Abs(r))
+		if d := max(decimals, set.decimals[i]); d >= 0 {
+			tolerance = roundingTolerance(d)
+		}
+		if math.Abs(x-r) <= tolerance {
+			return true
+		}
+	}
+	return false
+}
+
+// compareValues marks result valid when cur equals prev, explaining where
+// they differ otherwise.
+func compareValues(result *models.StepCheck, prev, cur Expr, prevLabel string, kind workKind, decimals int) bool {
+	v := compareExpressions(prev, cur, decimals)
+	result.Method = v.Method
+	switch v.Status {
+	case models.VerificationVerified:
+		result.Status = models.StepValid
+		result.Explanation = "Equal to " + prevLabel + "."
+		if kind == workIntegral {
+			result.Explanation = "Differentiates to the same integrand as " + prevLabel + "."
+		}
+		return true
+	case models.VerificationRefuted:
+		result.Status = models.StepInvalid
+		point, a, b, ok := differencePoint(prev, cur)
+		switch {
+		case !ok:
+			result.Explanation = "Not equal to " + prevLabel + ": " + v.Detail + "."
+		case kind == workIntegral && point == "":
+			result.Explanation = fmt.Sprintf("The derivative of this step is %s, but the integrand of %s is %s.", formatFloat(b), prevLabel, formatFloat(a))
+		case kind == workIntegral:
+			result.Explanation = fmt.Sprintf("The derivative of this step is not the integrand of %s: at %s it equals %s instead of %s.", prevLabel, point, formatFloat(b), formatFloat(a))
+		case point == "":
+			result.Explanation = fmt.Sprintf("%s equals %s, but this step equals %s.", capitalize(prevLabel), formatFloat(a), formatFloat(b))
+		default:
+			result.Explanation = fmt.Sprintf("At %s, %s equals %s but this step equals %s.", point, prevLabel, formatFloat(a), formatFloat(b))
+		}
+		return false
+	}
+	result.Status = models.StepUnverifiable
+	result.Explanation = v.Detail
+	return false
+}
+
+// differencePoint finds where prev and cur take different values: the
+// first sample point that tells them apart, or no point when both are
+// constant.
+func differencePoint(prev, cur Expr) (point string, a, b float64, ok bool) {
+	vars := FreeSymbols(Sub(prev, cur))
+	if len(vars) == 0 {
+		a, errA := Eval(prev, nil)
+		b, errB := Eval(cur, nil)
+		return "", a, b, errA == nil && errB == nil
+	}
+	for k := range samplePoints {
+		env := map[string]float64{}
+		var assignments []string
+		for j, name := range vars {
+			x := samplePoints[(k+3*j)%len(samplePoints)]
+			env[name] = x
+			assignments = append(assignments, name+" = "+formatFloat(x))
+		}
+		a, errA := Eval(prev, env)
+		b, errB := Eval(cur, env)
+		if errA != nil || errB != nil || !finite(a) || !finite(b) {
+			continue
+		}
+		if math.Abs(a-b) > relativeTolerance*math.Max(1, math.Max(math.Abs(a), math.Abs(b))) {
+			return strings.Join(assignments, ", "), a, b, true
+		}
+	}
+	return "", 0, 0, false
+}
+
+func withoutConstant(answer string) string {
+	answer = strings.TrimSpace(answer)
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSuffix(answer, "+ C"), "+C"))
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// isFinalAnswer reports whether last correctly answers problem in the form
+// it asks for; reference decides how simple a simplification must be.
+func isFinalAnswer(problem string, p *workProblem, last, reference, format string) bool {
+	if p.kind == workEquation {
+		return Verify(problem, last).Status == models.VerificationVerified
+	}
+	node, err := readStepNode(last, format)
+	if err != nil {
+		return false
+	}
+	if rel, ok := node.(*parser.Relation); ok {
+		node = rel.Right
+	}
+	if command, _, _ := splitCommand(node); command != "" {
+		return false
+	}
+	answer := parser.Format(node)
+	if Verify(problem, answer).Status != models.VerificationVerified {
+		return false
+	}
+	problemNode, err := parser.Parse(problem)
+	if err != nil {
+		return false
+	}
+	command, _, _ := splitCommand(problemNode)
+	return inFinalForm(command, answer, reference)
+}
@@ -0,0 +1,71 @@
+package mathengine
+
+import (
+	"testing"
+
+	"maths-solution-backend/models"
+)
+
+func TestCheckWork(t *testing.T) {
+	tests := []struct {
+		name         string
+		problem      string
+		reference    string
+		steps        []string
+		format       string
+		firstInvalid int
+		complete     bool
+	}{
+		{"simplification", "2(x + 3) - x", "x + 6", []string{"2x + 6 - x", "x + 6"}, "", -1, true},
+		{"sign slip", "2(x + 3) - x", "x + 6", []string{"2x + 3 - x", "x + 3"}, "", 0, false},
+		{"unfinished", "2(x + 3) - x", "x + 6", []string{"2x + 6 - x"}, "", -1, false},
+		{"quadratic", "x^2 - 5x + 6 = 0", "x = 2; x = 3", []string{"(x - 2)(x - 3) = 0", "x = 2 or x = 3"}, "", -1, true},
+		{"lost root", "x^2 - 5x + 6 = 0", "x = 2; x = 3", []string{"(x - 2)(x - 3) = 0", "x = 2"}, "", 1, false},
+		{"later steps unchecked", "2x + 4 = 10", "x = 3", []string{"2x = 14", "x = 7", "x = 3"}, "", 0, false},
+		{"latex steps", "2x + 4 = 10", "x = 3", []string{`2x = 6`, `x = \frac{6}{2}`, `x = 3`}, "latex", -1, true},
+		{"derivative", "diff x^3 + x", "3*x^2 + 1", []string{"3x^2 + 1"}, "", -1, true},
+		{"wrong derivative", "diff x^3 + x", "3*x^2 + 1", []string{"3x^2"}, "", 0, false},
+		{"antiderivative", "integrate 2x", "x^2", []string{"x^2 + C"}, "", -1, true},
+	}
+	for _, tt := range tests {
+		check, err := CheckWork(tt.problem, tt.reference, tt.steps, tt.format)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if check.FirstInvalid != tt.firstInvalid {
+			t.Errorf("%s: first invalid step %d, want %d: %+v", tt.name, check.FirstInvalid, tt.firstInvalid, check.Steps)
+		}
+		if check.Complete != tt.complete {
+			t.Errorf("%s: complete = %v, want %v", tt.name, check.Complete, tt.complete)
+		}
+		for i, step := range check.Steps {
+			want := models.StepValid
+			switch {
+			case i == tt.firstInvalid:
+				want = models.StepInvalid
+			case tt.firstInvalid >= 0 && i > tt.firstInvalid:
+				want = models.StepUnchecked
+			}
+			if step.Status != want {
+				t.Errorf("%s: step %d is %s, want %s: %s", tt.name, i, step.Status, want, step.Explanation)
+			}
+		}
+	}
+}
+
+func TestCheckWorkUnreadableStep(t *testing.T) {
+	check, err := CheckWork("2x + 4 = 10", "x = 3", []string{"2x = 6", "x = ((", "x = 3"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := []string{models.StepValid, models.StepUnverifiable, models.StepValid}
+	for i, step := range check.Steps {
+		if step.Status != statuses[i] {
+			t.Errorf("step %d is %s, want %s", i, step.Status, statuses[i])
+		}
+	}
+	if check.FirstInvalid != -1 || !check.Complete {
+		t.Errorf("first invalid %d, complete %v; want -1, true", check.FirstInvalid, check.Complete)
+	}
+}
@@ -0,0 +1,705 @@
+package mathengine
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"maths-solution-backend/models"
+)
+
+// Kinds of answers CompareAnswers can tell apart
+const (
+	AnswerKindExpression = "expression" // A single expression in free variables, such as 2*(x + 1)
+	AnswerKindValues     = "values"     // Finite solution sets and the special outcomes
+	AnswerKindIntervals  = "intervals"  // Unions of intervals or inequalities
+)
+
+// interval is a real interval; lo and hi are nil when unbounded. A single
+// value is the closed interval from it to itself.
+type interval struct {
+	lo, hi         Expr
+	loOpen, hiOpen bool
+	loDec, hiDec   int // Fractional digits written, -1 when exact
+}
+
+var (
+	intervalWrappers = strings.NewReplacer(
+		"$", "", `\displaystyle`, "", `\left`, "", `\right`, "", `\,`, "", `\!`, "",
+		`\leqslant`, "<=", `\geqslant`, ">=", `\leq`, "<=", `\geq`, ">=", `\le`, "<=", `\ge`, ">=",
+		`\lt`, "<", `\gt`, ">", "≤", "<=", "≥", ">=", "=<", "<=", "=>", ">=",
+		`\cup`, ";", "∪", ";", `\lor`, ";", `\vee`, ";", `\text{ or }`, ";", `\text{or}`, ";",
+		`\infty`, "oo", "∞", "oo",
+	)
+	unionWord    = regexp.MustCompile(`(?i)\s+(or|u)\s+`)
+	inequalityOp = regexp.MustCompile(`<=|>=|<|>`)
+	infinity     = regexp.MustCompile(`^([+-]?)\s*(oo|inf|infinity)$`)
+	variableName = regexp.MustCompile(`^[a-zA-Z](?:_\{?\w+\}?)?$`)
+)
+
+// CompareAnswers decides whether answer is equivalent to expected. Both may
+// be written in ASCII or LaTeX as values ("x = 1/2", "\frac{1}{2}",
+// "2^{-1}"), solution sets ("x = 2 or x = -3", "\{2, -3\}"), intervals and
+// inequalities ("[0, \infty)", "x < 1 or x > 2") or single expressions.
+// Numbers must agree up to float noise, however many decimals they were
+// written with; tolerance, when positive, is the absolute difference
+// allowed instead.
+// The response explains the decision one sentence at a time.
+func CompareAnswers(expected, answer string, tolerance float64) (*models.CompareAnswersResponse, error) {
+	want, err := readComparable(expected)
+	if err != nil {
+		return nil, fmt.Errorf("expected answer: %w", err)
+	}
+	got, err := readComparable(answer)
+	if err != nil {
+		return nil, fmt.Errorf("answer: %w", err)
+	}
+
+	c := &comparison{tolerance: tolerance, response: &models.CompareAnswersResponse{
+		Expected: want.String(),
+		Answer:   got.String(),
+	}}
+	c.reason("The expected answer reads as %s and the answer as %s.", want.String(), got.String())
+
+	if want.Var != "" && got.Var != "" && want.Var != got.Var {
+		c.response.Kind = AnswerKindValues
+		if want.intervals != nil || got.intervals != nil {
+			c.response.Kind = AnswerKindIntervals
+		}
+		c.reason("The expected answer is for %s but the answer is for %s.", want.Var, got.Var)
+		return c.response, nil
+	}
+
+	switch {
+	case want.intervals != nil || got.intervals != nil:
+		c.response.Kind = AnswerKindIntervals
+		c.compareIntervals(want, got)
+	case want.isExpression() && got.isExpression():
+		c.response.Kind = AnswerKindExpression
+		c.compareValueSets(want, got)
+	default:
+		c.response.Kind = AnswerKindValues
+		c.compareValueSets(want, got)
+	}
+	return c.response, nil
+}
+
+// comparableAnswer is an Answer or, for intervals and inequalities, a union
+// of intervals.
+type comparableAnswer struct {
+	*Answer
+	intervals []interval
+}
+
+func (a *comparableAnswer) isExpression() bool {
+	return a.intervals == nil && a.Var == "" && !a.NoSolution && !a.AllReals &&
+		len(a.Values) == 1 && len(FreeSymbols(a.Values[0])) > 0
+}
+
+func (a *comparableAnswer) String() string {
+	switch {
+	case a.intervals != nil:
+		return describeIntervals(a.intervals, a.Var)
+	case a.NoSolution:
+		return "no solution"
+	case a.AllReals:
+		return "all real numbers"
+	}
+	parts := make([]string, len(a.Values))
+	for i, v := range a.Values {
+		parts[i] = valueString(v, a.Decimals[i])
+		if a.Var != "" {
+			parts[i] = a.Var + " = " + parts[i]
+		}
+	}
+	return strings.Join(parts, " or ")
+}
+
+// readComparable reads text as intervals when it is written as such and as
+// an Answer otherwise.
+func readComparable(text string) (*comparableAnswer, error) {
+	s := strings.TrimSpace(text)
+	if m := boxed.FindStringSubmatch(s); m != nil {
+		s = m[1]
+	}
+	s = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(intervalWrappers.Replace(s)), "."))
+
+	var variable string
+	if m := setNotation.FindStringSubmatch(s); m != nil {
+		variable = subscriptTag.ReplaceAllString(m[1], "")
+		s = strings.TrimSpace(s[len(m[0]):])
+	}
+	if !looksLikeIntervals(s) {
+		answer, err := ParseAnswer(text)
+		if err != nil {
+			return nil, err
+		}
+		return &comparableAnswer{Answer: answer}, nil
+	}
+
+	answer := &comparableAnswer{Answer: &Answer{Var: variable}, intervals: []interval{}}
+	for _, part := range splitTopLevel(unionWord.ReplaceAllString(s, ";")) {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		var iv interval
+		var name string
+		var err error
+		if isBracketInterval(part) {
+			iv, err = readBracketInterval(part)
+		} else {
+			iv, name, err = readInequality(part)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if name != "" {
+			if answer.Var != "" && answer.Var != name {
+				return nil, fmt.Errorf("inequalities in both %s and %s", answer.Var, name)
+			}
+			answer.Var = name
+		}
+		answer.intervals = append(answer.intervals, iv)
+	}
+	if len(answer.intervals) == 0 {
+		return nil, fmt.Errorf("no interval found in %q", text)
+	}
+	answer.intervals = normalizeIntervals(answer.intervals)
+	return answer, nil
+}
+
+// looksLikeIntervals reports whether s is written with inequalities, unions
+// or infinite bounds, or is a single bracketed pair such as "(1, 2]".
+func looksLikeIntervals(s string) bool {
+	if strings.Contains(s, "oo") || inequalityOp.MatchString(s) {
+		return true
+	}
+	parts := splitTopLevel(s)
+	for _, part := range parts {
+		if !isBracketInterval(strings.TrimSpace(part)) {
+			return false
+		}
+	}
+	return len(parts) == 1 || strings.Contains(s, ";")
+}
+
+// isBracketInterval reports whether s is "(a, b)", "[a, b)", "(a, b]" or
+// "[a, b]" with a single top-level comma.
+func isBracketInterval(s string) bool {
+	if len(s) < 5 || !strings.ContainsRune("([", rune(s[0])) || !strings.ContainsRune(")]", rune(s[len(s)-1])) {
+		return false
+	}
+	depth, commas := 0, 0
+	for i, r := range s {
+		switch r {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+			if depth == 0 && i != len(s)-1 {
+				return false
+			}
+		case ',':
+			if depth == 1 {
+				commas++
+			}
+		}
+	}
+	return depth == 0 && commas == 1
+}
+
+func readBracketInterval(s string) (interval, error) {
+	inner := s[1 : len(s)-1]
+	comma := strings.Index(inner, ",")
+	for depth, i := 0, 0; i < len(inner); i++ {
+		switch inner[i] {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				comma = i
+			}
+		}
+	}
+
+	iv := interval{loOpen: s[0] == '(', hiOpen: s[len(s)-1] == ')'}
+	var err error
+	var loSign, hiSign int
+	if iv.lo, iv.loDec, loSign, err = readEndpoint(inner[:comma]); err != nil {
+		return iv, err
+	}
+	if iv.hi, iv.hiDec, hiSign, err = readEndpoint(inner[comma+1:]); err != nil {
+		return iv, err
+	}
+	if loSign > 0 || hiSign < 0 {
+		return iv, fmt.Errorf("interval %s has its infinite bound on the wrong side", s)
+	}
+	// Infinite bounds are never included, whatever bracket is written
+	iv.loOpen = iv.loOpen || iv.lo == nil
+	iv.hiOpen = iv.hiOpen || iv.hi == nil
+	return iv, nil
+}
+
+// readEndpoint reads a constant bound, or an infinite one as nil with the
+// sign of the infinity.
+func readEndpoint(s string) (Expr, int, int, error) {
+	s = strings.TrimSpace(s)
+	if m := infinity.FindStringSubmatch(s); m != nil {
+		if m[1] == "-" {
+			return nil, -1, -1, nil
+		}
+		return nil, -1, 1, nil
+	}
+	value, err := parseAnswerValue(s)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("cannot read bound %q: %w", s, err)
+	}
+	if vars := FreeSymbols(value); len(vars) > 0 {
+		return nil, 0, 0, fmt.Errorf("%w: bound %s depends on %s", ErrUnsupported, value.String(), strings.Join(vars, ", "))
+	}
+	if _, err := Eval(value, nil); err != nil {
+		return nil, 0, 0, fmt.Errorf("cannot evaluate bound %s: %w", value.String(), err)
+	}
+	return value, decimalsIn(s), 0, nil
+}
+
+// readInequality reads "x > 2", "2 <= x", "x = 3" or a chain such as
+// "-1 < x <= 4", returning the interval and the variable.
+func readInequality(s string) (interval, string, error) {
+	ops := inequalityOp.FindAllString(s, -1)
+	sides := inequalityOp.Split(s, -1)
+	if len(ops) == 0 {
+		if i := strings.Index(s, "="); i >= 0 {
+			ops, sides = []string{"="}, []string{s[:i], s[i+1:]}
+		}
+	}
+	if len(ops) == 0 || len(ops) > 2 {
+		return interval{}, "", fmt.Errorf("cannot read %q as an interval or inequality", s)
+	}
+
+	at := -1
+	for i := range sides {
+		sides[i] = strings.TrimSpace(sides[i])
+		if variableName.MatchString(sides[i]) && !infinity.MatchString(sides[i]) {
+			at = i
+		}
+	}
+	if at < 0 {
+		return interval{}, "", fmt.Errorf("no variable found in %q", s)
+	}
+	name := subscriptTag.ReplaceAllString(sides[at], "")
+
+	iv := interval{loDec: -1, hiDec: -1, loOpen: true, hiOpen: true}
+	bound := func(side, op string, variableLeft bool) error {
+		value, decimals, sign, err := readEndpoint(side)
+		if err != nil {
+			return err
+		}
+		if op == "=" {
+			if sign != 0 {
+				return fmt.Errorf("%s cannot equal infinity", name)
+			}
+			iv = interval{lo: value, hi: value, loDec: decimals, hiDec: decimals}
+			return nil
+		}
+		// Normalize to "variable < bound" or "variable > bound"
+		upper := strings.HasPrefix(op, "<") == variableLeft
+		open := !strings.HasSuffix(op, "=")
+		switch {
+		case upper && sign < 0, !upper && sign > 0:
+			return fmt.Errorf("%q excludes every real %s", s, name)
+		case upper:
+			iv.hi, iv.hiDec, iv.hiOpen = value, decimals, open || sign > 0
+		default:
+			iv.lo, iv.loDec, iv.loOpen = value, decimals, open || sign < 0
+		}
+		return nil
+	}
+
+	if at > 0 {
+		if err := bound(sides[at-1], ops[at-1], false); err != nil {
+			return iv, "", err
+		}
+	}
+	if at < len(ops) {
+		if err := bound(sides[at+1], ops[at], true); err != nil {
+			return iv, "", err
+		}
+	}
+	if len(ops) == 2 && (at != 1 || strings.HasPrefix(ops[0], "<") != strings.HasPrefix(ops[1], "<")) {
+		return iv, "", fmt.Errorf("cannot read %q as an interval", s)
+	}
+	return iv, name, nil
+}
+
+// normalizeIntervals drops empty intervals, sorts the rest and merges those
+// that overlap or touch, so that equal sets have equal representations.
+func normalizeIntervals(ivs []interval) []interval {
+	var kept []interval
+	for _, iv := range ivs {
+		if iv.lo != nil && iv.hi != nil {
+			lo, hi := mustEval(iv.lo), mustEval(iv.hi)
+			if lo > hi || (lo == hi && (iv.loOpen || iv.hiOpen)) {
+				continue
+			}
+		}
+		kept = append(kept, iv)
+	}
+	sort.SliceStable(kept, func(i, j int) bool {
+		return lowerValue(kept[i]) < lowerValue(kept[j]) ||
+			(lowerValue(kept[i]) == lowerValue(kept[j]) && !kept[i].loOpen && kept[j].loOpen)
+	})
+
+	merged := []interval{}
+	for _, iv := range kept {
+		if n := len(merged); n > 0 {
+			last := &merged[n-1]
+			hi, lo := upperValue(*last), lowerValue(iv)
+			if lo < hi || (lo == hi && !(last.hiOpen && iv.loOpen)) {
+				if upperValue(iv) > hi || (upperValue(iv) == hi && !iv.hiOpen) {
+					last.hi, last.hiOpen, last.hiDec = iv.hi, iv.hiOpen, iv.hiDec
+				}
+				continue
+			}
+		}
+		merged = append(merged, iv)
+	}
+	return merged
+}
+
+func lowerValue(iv interval) float64 {
+	if iv.lo == nil {
+		return math.Inf(-1)
+	}
+	return mustEval(iv.lo)
+}
+
+func upperValue(iv interval) float64 {
+	if iv.hi == nil {
+		return math.Inf(1)
+	}
+	return mustEval(iv.hi)
+}
+
+// mustEval evaluates a bound readEndpoint already checked.
+func mustEval(e Expr) float64 {
+	v, _ := Eval(e, nil)
+	return v
+}
+
+func describeIntervals(ivs []interval, variable string) string {
+	if len(ivs) == 0 {
+		return "no solution"
+	}
+	parts := make([]string, len(ivs))
+	for i, iv := range ivs {
+		parts[i] = describeInterval(iv, variable)
+	}
+	return strings.Join(parts, " U ")
+}
+
+func describeInterval(iv interval, variable string) string {
+	if iv.lo != nil && iv.hi != nil && !iv.loOpen && !iv.hiOpen && iv.lo.String() == iv.hi.String() {
+		if variable != "" {
+			return "{" + variable + " = " + valueString(iv.lo, iv.loDec) + "}"
+		}
+		return "{" + valueString(iv.lo, iv.loDec) + "}"
+	}
+	lo, hi := "-oo", "oo"
+	if iv.lo != nil {
+		lo = valueString(iv.lo, iv.loDec)
+	}
+	if iv.hi != nil {
+		hi = valueString(iv.hi, iv.hiDec)
+	}
+	open, closed := "[", "]"
+	if iv.loOpen {
+		open = "("
+	}
+	if iv.hiOpen {
+		closed = ")"
+	}
+	return open + lo + ", " + hi + closed
+}
+
+// comparison accumulates the outcome and reasoning of CompareAnswers.
+type comparison struct {
+	tolerance float64
+	numeric   bool
+	response  *models.CompareAnswersResponse
+}
+
+func (c *comparison) reason(format string, args ...interface{}) {
+	c.response.Reasoning = append(c.response.Reasoning, fmt.Sprintf(format, args...))
+}
+
+func (c *comparison) conclude(equivalent bool) {
+	c.response.Equivalent = equivalent
+	c.response.Method = MethodSymbolic
+	if c.numeric {
+		c.response.Method = MethodNumeric
+	}
+}
+
+// compareValueSets compares finite sets of values, which may be symbolic,
+// and the special outcomes.
+func (c *comparison) compareValueSets(want, got *comparableAnswer) {
+	switch {
+	case want.NoSolution || want.AllReals || got.NoSolution || got.AllReals:
+		equivalent := want.NoSolution == got.NoSolution && want.AllReals == got.AllReals
+		if equivalent {
+			c.reason("Both answers are %s.", want.String())
+		} else {
+			c.reason("The expected answer is %s, but the answer is %s.", want.String(), got.String())
+		}
+		c.conclude(equivalent)
+		return
+	}
+
+	// A single value against a single value needs no matching
+	if len(want.Values) == 1 && len(got.Values) == 1 {
+		equal, why := c.sameValue(want.Values[0], got.Values[0], want.Decimals[0], got.Decimals[0])
+		c.reason("%s", why)
+		c.conclude(equal)
+		return
+	}
+
+	variable := want.Var
+	if variable == "" {
+		variable = got.Var
+	}
+	label := func(v Expr, decimals int) string {
+		if variable == "" {
+			return valueString(v, decimals)
+		}
+		return variable + " = " + valueString(v, decimals)
+	}
+
+	// Match every expected value with one of the answer's, explaining each
+	// match that is not literal, then look for values the answer adds
+	equivalent := true
+	for i, v := range want.Values {
+		if !c.findValue(got, v, want.Decimals[i], true) {
+			c.reason("The answer is missing %s.", label(v, want.Decimals[i]))
+			equivalent = false
+		}
+	}
+	for i, v := range got.Values {
+		if !c.findValue(want, v, got.Decimals[i], false) {
+			c.reason("%s is not part of the expected answer.", capitalize(label(v, got.Decimals[i])))
+			equivalent = false
+		}
+	}
+	if equivalent {
+		c.reason("Both answers have the same %d distinct values.", countDistinct(want.Values))
+	}
+	c.conclude(equivalent)
+}
+
+// findValue reports whether a holds a value equal to v. When explain is
+// set, a match that is not literal is added to the reasoning.
+func (c *comparison) findValue(a *comparableAnswer, v Expr, decimals int, explain bool) bool {
+	for i, candidate := range a.Values {
+		var equal bool
+		var why string
+		if explain {
+			equal, why = c.sameValue(v, candidate, decimals, a.Decimals[i])
+		} else {
+			equal, why = c.sameValue(candidate, v, a.Decimals[i], decimals)
+		}
+		if equal {
+			if explain && !strings.HasPrefix(why, "Both are ") {
+				c.reason("%s", why)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// sameValue compares the expected value a with b, exactly when possible and
+// numerically otherwise. The decimals either was written with only affect
+// how it is shown. It explains the outcome in one sentence.
+func (c *comparison) sameValue(a, b Expr, aDecimals, bDecimals int) (bool, string) {
+	want, got := valueString(a, aDecimals), valueString(b, bDecimals)
+	if isZero(Simplify(Expand(Sub(a, b)))) {
+		if want == got {
+			return true, fmt.Sprintf("Both are %s.", want)
+		}
+		return true, fmt.Sprintf("%s equals %s after simplification.", got, want)
+	}
+
+	if vars := FreeSymbols(Sub(a, b)); len(vars) > 0 {
+		v := compareExpressions(a, b, -1)
+		switch v.Status {
+		case models.VerificationVerified:
+			c.numeric = true
+			return true, fmt.Sprintf("%s and %s agree at every sample value of %s.", got, want, strings.Join(vars, ", "))
+		case models.VerificationRefuted:
+			if point, x, y, ok := differencePoint(a, b); ok && point != "" {
+				return false, fmt.Sprintf("At %s, %s equals %s but %s equals %s.", point, want, formatFloat(x), got, formatFloat(y))
+			}
+			return false, fmt.Sprintf("%s differs from %s.", got, want)
+		}
+		return false, fmt.Sprintf("Cannot compare %s with %s: %s.", got, want, v.Detail)
+	}
+
+	x, errA := Eval(a, nil)
+	y, errB := Eval(b, nil)
+	if errA != nil || errB != nil || !finite(x) || !finite(y) {
+		return false, fmt.Sprintf("Cannot evaluate %s or %s numerically.", want, got)
+	}
+	allowed, why := c.allowance(x, y)
+	delta := math.Abs(x - y)
+	if delta > allowed {
+		if _, exact := isNum(a); exact {
+			return false, fmt.Sprintf("%s differs from %s by %s.", got, want, formatFloat(delta))
+		}
+		return false, fmt.Sprintf("%s differs from %s, which is approximately %s, by %s.", got, want, formatFloat(x), formatFloat(delta))
+	}
+	c.numeric = true
+	return true, fmt.Sprintf("%s and %s agree %s.", got, want, why)
+}
+
+// allowance is how far two numbers may differ and still count as equal:
+// float noise only, unless the caller passed a tolerance. The decimals
+// either side was written with do not widen it, or an answer could buy
+// itself slack by rounding more coarsely.
+func (c *comparison) allowance(x, y float64) (float64, string) {
+	allowed := relativeTolerance * math.Max(1, math.Max(math.Abs(x), math.Abs(y)))
+	if c.tolerance > allowed {
+		return c.tolerance, "within the tolerance of " + formatFloat(c.tolerance)
+	}
+	return allowed, "numerically"
+}
+
+// compareIntervals compares unions of intervals; finite sets of constant
+// values take part as single-point intervals.
+func (c *comparison) compareIntervals(want, got *comparableAnswer) {
+	a, err := asIntervals(want)
+	if err != nil {
+		c.reason("The expected answer cannot be compared with intervals: %v.", err)
+		c.conclude(false)
+		return
+	}
+	b, err := asIntervals(got)
+	if err != nil {
+		c.reason("The answer cannot be compared with intervals: %v.", err)
+		c.conclude(false)
+		return
+	}
+	variable := want.Var
+	if variable == "" {
+		variable = got.Var
+	}
+
+	if len(a) != len(b) {
+		c.reason("The expected answer is made of %s but the answer of %s.", countIntervals(len(a)), countIntervals(len(b)))
+		c.conclude(false)
+		return
+	}
+	for i := range a {
+		if !c.sameBound(a[i].lo, b[i].lo, a[i].loDec, b[i].loDec, a[i].loOpen, b[i].loOpen, "lower", describeInterval(a[i], ""), describeInterval(b[i], "")) ||
+			!c.sameBound(a[i].hi, b[i].hi, a[i].hiDec, b[i].hiDec, a[i].hiOpen, b[i].hiOpen, "upper", describeInterval(a[i], ""), describeInterval(b[i], "")) {
+			c.conclude(false)
+			return
+		}
+	}
+	c.reason("Both describe the same set of %s: %s.", realsOf(variable), describeIntervals(a, ""))
+	c.conclude(true)
+}
+
+// sameBound compares one bound of two intervals, explaining any difference.
+func (c *comparison) sameBound(a, b Expr, aDecimals, bDecimals int, aOpen, bOpen bool, side, want, got string) bool {
+	switch {
+	case a == nil && b == nil:
+		return true
+	case a == nil || b == nil:
+		c.reason("The expected interval %s and the interval %s have different %s bounds: only one is infinite.", want, got, side)
+		return false
+	}
+	equal, why := c.sameValue(a, b, aDecimals, bDecimals)
+	if !equal {
+		c.reason("The expected interval %s and the interval %s have different %s bounds: %s", want, got, side, why)
+		return false
+	}
+	if !strings.HasPrefix(why, "Both are ") {
+		c.reason("%s", why)
+	}
+	if aOpen != bOpen {
+		if aOpen {
+			c.reason("The answer %s includes %s, which the expected interval %s excludes.", got, valueString(b, bDecimals), want)
+		} else {
+			c.reason("The answer %s excludes %s, which the expected interval %s includes.", got, valueString(b, bDecimals), want)
+		}
+		return false
+	}
+	return true
+}
+
+// asIntervals converts an answer to a normalized union of intervals.
+func asIntervals(a *comparableAnswer) ([]interval, error) {
+	switch {
+	case a.intervals != nil:
+		return a.intervals, nil
+	case a.NoSolution:
+		return []interval{}, nil
+	case a.AllReals:
+		return []interval{{loOpen: true, hiOpen: true, loDec: -1, hiDec: -1}}, nil
+	}
+	ivs := make([]interval, len(a.Values))
+	for i, v := range a.Values {
+		if vars := FreeSymbols(v); len(vars) > 0 {
+			return nil, fmt.Errorf("%s depends on %s", v.String(), strings.Join(vars, ", "))
+		}
+		if _, err := Eval(v, nil); err != nil {
+			return nil, fmt.Errorf("cannot evaluate %s", v.String())
+		}
+		ivs[i] = interval{lo: v, hi: v, loDec: a.Decimals[i], hiDec: a.Decimals[i]}
+	}
+	return normalizeIntervals(ivs), nil
+}
+
+func countIntervals(n int) string {
+	switch n {
+	case 0:
+		return "no intervals (it is empty)"
+	case 1:
+		return "1 interval"
+	}
+	return fmt.Sprintf("%d separate intervals", n)
+}
+
+func realsOf(variable string) string {
+	if variable == "" {
+		return "real numbers"
+	}
+	return "real " + variable
+}
+
+func countDistinct(values []Expr) int {
+	seen := map[string]bool{}
+	for _, v := range values {
+		seen[Simplify(v).String()] = true
+	}
+	return len(seen)
+}
+
+// valueString shows a value written with decimals as a decimal, and any
+// other value in canonical form.
+func valueString(v Expr, decimals int) string {
+	if decimals < 0 {
+		return v.String()
+	}
+	x, err := Eval(v, nil)
+	if err != nil {
+		return v.String()
+	}
+	return strconv.FormatFloat(x, 'f', decimals, 64)
+}
@@ -0,0 +1,61 @@
+package mathengine
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompareAnswers(t *testing.T) {
+	tests := []struct {
+		expected, answer string
+		tolerance        float64
+		equivalent       bool
+	}{
+		{"1/2", "0.5", 0, true},
+		{"x = 2 or x = -3", `\{-3, 2\}`, 0, true},
+		{"2(x + 1)", "2x + 2", 0, true},
+		{"1e-5", "0.00001", 0, true},
+		{"0.00001", "1E-5", 0, true},
+		{"2.5e3", "2500", 0, true},
+		{"1/3", "0.3", 0, false},
+		{"2/3", "0.7", 0, false},
+		{"pi", "3.1", 0, false},
+		{"0.001", "0.0", 0, false},
+		{"1/3", "0.333", 0, false},
+		{"1/3", "0.333", 0.001, true},
+		{"pi", "3.1416", 0.0001, true},
+		{"pi", "3.1", 0.01, false},
+		{"x = 1/3", "x = 0.33", 0.01, true},
+		{"[0, 1/3)", "[0, 0.3)", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := CompareAnswers(tt.expected, tt.answer, tt.tolerance)
+		if err != nil {
+			t.Errorf("CompareAnswers(%q, %q, %g): %v", tt.expected, tt.answer, tt.tolerance, err)
+			continue
+		}
+		if got.Equivalent != tt.equivalent {
+			t.Errorf("CompareAnswers(%q, %q, %g) = %v, want %v: %s", tt.expected, tt.answer, tt.tolerance, got.Equivalent, tt.equivalent, strings.Join(got.Reasoning, " "))
+		}
+	}
+}
+
+func TestDecimalsIn(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int
+	}{
+		{"2", -1},
+		{"1e-5", -1},
+		{"0.25", 2},
+		{"x = 1.5 or x = 0.125", 3},
+		{"2.5e-3", 4},
+		{"2.5E3", 0},
+		{"1.25e+1", 1},
+	}
+	for _, tt := range tests {
+		if got := decimalsIn(tt.s); got != tt.want {
+			t.Errorf("decimalsIn(%q) = %d, want %d", tt.s, got, tt.want)
+		}
+	}
+}
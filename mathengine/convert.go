@@ -0,0 +1,103 @@
+package mathengine
+
+import (
+	"fmt"
+	"math/big"
+
+	"maths-solution-backend/parser"
+)
+
+// Parse parses a plain expression into the engine's canonical form.
+func Parse(input string) (Expr, error) {
+	node, err := parser.ParseExpression(input)
+	if err != nil {
+		return nil, err
+	}
+	return FromNode(node)
+}
+
+// FromNode converts a syntax tree into an unsimplified engine expression.
+// Relations and commands have no expression form and are rejected.
+func FromNode(n parser.Node) (Expr, error) {
+	switch n := n.(type) {
+	case *parser.Number:
+		r, ok := new(big.Rat).SetString(n.Text)
+		if !ok {
+			return nil, fmt.Errorf("invalid number %q", n.Text)
+		}
+		return &Num{Val: r}, nil
+	case *parser.Ident:
+		return NewSym(n.Name), nil
+	case *parser.Unary:
+		x, err := FromNode(n.X)
+		if err != nil {
+			return nil, err
+		}
+		if n.Op == "-" {
+			return Neg(x), nil
+		}
+		return x, nil
+	case *parser.Binary:
+		x, err := FromNode(n.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := FromNode(n.Y)
+		if err != nil {
+			return nil, err
+		}
+		switch n.Op {
+		case "+":
+			return &Add{Terms: []Expr{x, y}}, nil
+		case "-":
+			return Sub(x, y), nil
+		case "*":
+			return &Mul{Factors: []Expr{x, y}}, nil
+		case "/":
+			return Div(x, y), nil
+		case "^":
+			return &Pow{Base: x, Exp: y}, nil
+		}
+		return nil, fmt.Errorf("unknown operator %q", n.Op)
+	case *parser.Call:
+		args := make([]Expr, len(n.Args))
+		for i, a := range n.Args {
+			arg, err := FromNode(a)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = arg
+		}
+		return makeFunc(n.Func, args)
+	case *parser.Relation:
+		return nil, fmt.Errorf("expected an expression, got %q relation", n.Op)
+	}
+	return nil, fmt.Errorf("unsupported syntax node %T", n)
+}
+
+// makeFunc maps function names onto the engine's canonical node kinds.
+func makeFunc(name string, args []Expr) (Expr, error) {
+	if _, ok := parser.Commands[name]; ok {
+		return nil, fmt.Errorf("%s cannot be used inside an expression", name)
+	}
+	switch name {
+	case "sqrt":
+		return Sqrt(args[0]), nil
+	case "root":
+		return &Pow{Base: args[0], Exp: &Pow{Base: args[1], Exp: NewInt(-1)}}, nil
+	case "exp":
+		return &Pow{Base: NewSym("e"), Exp: args[0]}, nil
+	case "log":
+		if len(args) == 2 {
+			// log(x, b) = ln(x)/ln(b)
+			return Div(&Func{Name: "ln", Arg: args[0]}, &Func{Name: "ln", Arg: args[1]}), nil
+		}
+	case "arcsin":
+		name = "asin"
+	case "arccos":
+		name = "acos"
+	case "arctan":
+		name = "atan"
+	}
+	return &Func{Name: name, Arg: args[0]}, nil
+}
@@ -0,0 +1,321 @@
+package mathengine
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Rule names reported on differentiation steps.
+const (
+	RuleConstant       = "Constant rule"
+	RuleIdentity       = "Identity rule"
+	RuleSum            = "Sum rule"
+	RuleConstantFactor = "Constant multiple rule"
+	RuleProduct        = "Product rule"
+	RuleQuotient       = "Quotient rule"
+	RulePower          = "Power rule"
+	RuleChain          = "Chain rule"
+	RuleExponential    = "Exponential rule"
+	RuleLogarithmic    = "Logarithmic differentiation"
+	RuleLogarithm      = "Logarithm rule"
+	RuleSimplify       = "Simplify"
+)
+
+// differentiator applies the differentiation rules recursively, recording
+// one step per rule application when s is set.
+type differentiator struct {
+	v string
+	s *session
+}
+
+// Diff returns the simplified derivative of e with respect to v.
+func Diff(e Expr, v string) (Expr, error) {
+	d := &differentiator{v: v}
+	result, err := d.derive(Simplify(e))
+	if err != nil {
+		return nil, err
+	}
+	return tidy(result), nil
+}
+
+// differentiate solves a derivative problem with steps. variable may be
+// empty when e has a single free variable.
+func (s *session) differentiate(e Expr, variable string) error {
+	e = Simplify(e)
+	variable, err := unknownOf(e, variable, "diff")
+	if err != nil {
+		return err
+	}
+
+	d := &differentiator{v: variable, s: s}
+	s.step(d.dx(e))
+	result, err := d.derive(e)
+	if err != nil {
+		return err
+	}
+
+	final := tidy(result)
+	s.ruleStep(RuleSimplify, d.dx(e)+" = "+final.Latex())
+	s.final = final.String()
+	return nil
+}
+
+// tidy simplifies e and prefers the expanded form when it is shorter.
+func tidy(e Expr) Expr {
+	simplified := Simplify(e)
+	if expanded := Expand(simplified); len(expanded.String()) <= len(simplified.String()) {
+		return expanded
+	}
+	return simplified
+}
+
+func (d *differentiator) step(rule, latex string) {
+	if d.s != nil {
+		d.s.ruleStep(rule, latex)
+	}
+}
+
+// dx renders d/dv [e].
+func (d *differentiator) dx(e Expr) string {
+	return `\frac{d}{d` + NewSym(d.v).Latex() + `}\left[` + e.Latex() + `\right]`
+}
+
+func (d *differentiator) derive(e Expr) (Expr, error) {
+	if !Contains(e, d.v) {
+		d.step(RuleConstant, d.dx(e)+" = 0")
+		return NewInt(0), nil
+	}
+
+	switch e := e.(type) {
+	case *Sym:
+		d.step(RuleIdentity, d.dx(e)+" = 1")
+		return NewInt(1), nil
+	case *Add:
+		return d.sum(e)
+	case *Mul:
+		return d.product(e)
+	case *Pow:
+		return d.power(e)
+	case *Func:
+		outer, rule, err := funcDerivative(e.Name, e.Arg)
+		if err != nil {
+			return nil, err
+		}
+		return d.chain(e, e.Arg, outer, rule)
+	}
+	return nil, fmt.Errorf("%w: cannot differentiate %s", ErrUnsupported, e.String())
+}
+
+func (d *differentiator) sum(e *Add) (Expr, error) {
+	var b strings.Builder
+	for i, t := range e.Terms {
+		if neg, ok := negate(t); ok {
+			b.WriteString(" - " + d.dx(neg))
+			continue
+		}
+		if i > 0 {
+			b.WriteString(" + ")
+		}
+		b.WriteString(d.dx(t))
+	}
+	expansion := b.String()
+	if strings.HasPrefix(expansion, " - ") {
+		expansion = "-" + expansion[len(" - "):]
+	}
+	d.step(RuleSum, d.dx(e)+" = "+expansion)
+
+	// Negative terms were written as differences, so derive them that way
+	terms := make([]Expr, len(e.Terms))
+	for i, t := range e.Terms {
+		neg, isNeg := negate(t)
+		if isNeg {
+			t = neg
+		}
+		dt, err := d.derive(t)
+		if err != nil {
+			return nil, err
+		}
+		if isNeg {
+			dt = Neg(dt)
+		}
+		terms[i] = dt
+	}
+	return Simplify(&Add{Terms: terms}), nil
+}
+
+// product applies the constant multiple, quotient or product rule.
+func (d *differentiator) product(e *Mul) (Expr, error) {
+	var constants, varying []Expr
+	for _, f := range e.Factors {
+		if Contains(f, d.v) {
+			varying = append(varying, f)
+		} else {
+			constants = append(constants, f)
+		}
+	}
+
+	if len(constants) > 0 {
+		c, u := simplifyMul(constants), simplifyMul(varying)
+		d.step(RuleConstantFactor, d.dx(e)+" = "+coefficientLatex(c)+d.dx(u))
+		du, err := d.derive(u)
+		if err != nil {
+			return nil, err
+		}
+		return Simplify(&Mul{Factors: []Expr{c, du}}), nil
+	}
+
+	num, den := numeratorDenominator(&Mul{Factors: varying})
+	if len(num) > 0 && len(den) > 0 {
+		u, w := simplifyMul(num), simplifyMul(den)
+		d.step(RuleQuotient, d.dx(e)+` = \frac{`+d.dx(u)+` \cdot `+factorLatex(w)+` - `+factorLatex(u)+` \cdot `+d.dx(w)+`}{`+Simplify(&Pow{Base: w, Exp: NewInt(2)}).Latex()+`}`)
+		du, err := d.derive(u)
+		if err != nil {
+			return nil, err
+		}
+		dw, err := d.derive(w)
+		if err != nil {
+			return nil, err
+		}
+		numerator := Expand(Sub(&Mul{Factors: []Expr{du, w}}, &Mul{Factors: []Expr{u, dw}}))
+		return Simplify(&Mul{Factors: []Expr{numerator, &Pow{Base: w, Exp: NewInt(-2)}}}), nil
+	}
+
+	f, g := varying[0], simplifyMul(varying[1:])
+	d.step(RuleProduct, d.dx(e)+" = "+d.dx(f)+` \cdot `+factorLatex(g)+" + "+factorLatex(f)+` \cdot `+d.dx(g))
+	df, err := d.derive(f)
+	if err != nil {
+		return nil, err
+	}
+	dg, err := d.derive(g)
+	if err != nil {
+		return nil, err
+	}
+	return Simplify(&Add{Terms: []Expr{
+		&Mul{Factors: []Expr{df, g}},
+		&Mul{Factors: []Expr{f, dg}},
+	}}), nil
+}
+
+func (d *differentiator) power(e *Pow) (Expr, error) {
+	baseVaries, expVaries := Contains(e.Base, d.v), Contains(e.Exp, d.v)
+	switch {
+	case baseVaries && !expVaries:
+		// d/dx u^n = n u^(n-1) u'
+		outer := Simplify(&Mul{Factors: []Expr{e.Exp, &Pow{Base: e.Base, Exp: &Add{Terms: []Expr{e.Exp, NewInt(-1)}}}}})
+		return d.chain(e, e.Base, outer, RulePower)
+	case !baseVaries:
+		// d/dx a^u = a^u ln(a) u'
+		outer := Expr(e)
+		if s, ok := e.Base.(*Sym); !ok || s.Name != "e" {
+			outer = Simplify(&Mul{Factors: []Expr{e, &Func{Name: "ln", Arg: e.Base}}})
+		}
+		return d.chain(e, e.Exp, outer, RuleExponential)
+	}
+
+	// f^g = e^(g ln f), so (f^g)' = f^g (g' ln f + g f'/f)
+	f, g := e.Base, e.Exp
+	d.step(RuleLogarithmic, d.dx(e)+" = "+factorLatex(e)+`\left(`+d.dx(g)+` \ln\left(`+f.Latex()+`\right) + `+factorLatex(g)+` \cdot \frac{`+d.dx(f)+`}{`+f.Latex()+`}\right)`)
+	df, err := d.derive(f)
+	if err != nil {
+		return nil, err
+	}
+	dg, err := d.derive(g)
+	if err != nil {
+		return nil, err
+	}
+	inner := &Add{Terms: []Expr{
+		&Mul{Factors: []Expr{dg, &Func{Name: "ln", Arg: f}}},
+		&Mul{Factors: []Expr{g, df, &Pow{Base: f, Exp: NewInt(-1)}}},
+	}}
+	return Simplify(&Mul{Factors: []Expr{e, inner}}), nil
+}
+
+// chain finishes a rule whose outer derivative is outer(inner): applied
+// directly when inner is the variable, through the chain rule otherwise.
+func (d *differentiator) chain(e, inner, outer Expr, rule string) (Expr, error) {
+	if s, ok := inner.(*Sym); ok && s.Name == d.v {
+		d.step(rule, d.dx(e)+" = "+outer.Latex())
+		return outer, nil
+	}
+
+	d.step(RuleChain, d.dx(e)+" = "+factorLatex(outer)+` \cdot `+d.dx(inner))
+	du, err := d.derive(inner)
+	if err != nil {
+		return nil, err
+	}
+	return Simplify(&Mul{Factors: []Expr{outer, du}}), nil
+}
+
+// funcDerivative returns f'(u) for a named function and the rule applied.
+func funcDerivative(name string, u Expr) (Expr, string, error) {
+	fn := func(name string) Expr { return &Func{Name: name, Arg: u} }
+	pow := func(base Expr, n int64) Expr { return &Pow{Base: base, Exp: NewInt(n)} }
+	neg := func(e Expr) Expr { return &Mul{Factors: []Expr{NewInt(-1), e}} }
+	oneMinusU2 := &Add{Terms: []Expr{NewInt(1), neg(pow(u, 2))}}
+
+	var outer Expr
+	switch name {
+	case "sin":
+		outer = fn("cos")
+	case "cos":
+		outer = neg(fn("sin"))
+	case "tan":
+		outer = pow(fn("sec"), 2)
+	case "sec":
+		outer = &Mul{Factors: []Expr{fn("sec"), fn("tan")}}
+	case "csc":
+		outer = neg(&Mul{Factors: []Expr{fn("csc"), fn("cot")}})
+	case "cot":
+		outer = neg(pow(fn("csc"), 2))
+	case "asin":
+		outer = &Pow{Base: oneMinusU2, Exp: NewRat(-1, 2)}
+	case "acos":
+		outer = neg(&Pow{Base: oneMinusU2, Exp: NewRat(-1, 2)})
+	case "atan":
+		outer = pow(&Add{Terms: []Expr{NewInt(1), pow(u, 2)}}, -1)
+	case "sinh":
+		outer = fn("cosh")
+	case "cosh":
+		outer = fn("sinh")
+	case "tanh":
+		outer = pow(fn("cosh"), -2)
+	case "ln":
+		outer = pow(u, -1)
+	case "log":
+		outer = pow(&Mul{Factors: []Expr{u, &Func{Name: "ln", Arg: NewInt(10)}}}, -1)
+	case "abs":
+		outer = &Mul{Factors: []Expr{u, pow(fn("abs"), -1)}}
+	default:
+		return nil, "", fmt.Errorf("%w: derivative of %s", ErrUnsupported, name)
+	}
+	return Simplify(outer), derivativeRules[name], nil
+}
+
+var derivativeRules = map[string]string{
+	"sin": "Derivative of sine", "cos": "Derivative of cosine", "tan": "Derivative of tangent",
+	"sec": "Derivative of secant", "csc": "Derivative of cosecant", "cot": "Derivative of cotangent",
+	"asin": "Derivative of arcsine", "acos": "Derivative of arccosine", "atan": "Derivative of arctangent",
+	"sinh": "Derivative of sinh", "cosh": "Derivative of cosh", "tanh": "Derivative of tanh",
+	"ln": RuleLogarithm, "log": RuleLogarithm, "abs": "Absolute value rule",
+}
+
+// factorLatex renders e as a factor of a product, parenthesised if needed.
+func factorLatex(e Expr) string {
+	latex := e.Latex()
+	if precedence(e) < precMul || strings.HasPrefix(latex, "-") {
+		return `\left(` + latex + `\right)`
+	}
+	return latex
+}
+
+// coefficientLatex renders a constant factor placed before d/dx or ∫.
+func coefficientLatex(c Expr) string {
+	if r, ok := isNum(c); ok {
+		if r.Cmp(big.NewRat(-1, 1)) == 0 {
+			return "-"
+		}
+		return latexRat(r) + " "
+	}
+	return factorLatex(c) + ` \cdot `
+}
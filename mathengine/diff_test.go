@@ -0,0 +1,41 @@
+package mathengine
+
+import "testing"
+
+func TestDifferentiate(t *testing.T) {
+	tests := []struct {
+		input string
+		final string
+		rule  string // A rule the steps must name
+	}{
+		{"diff x^3", "3*x^2", RulePower},
+		{"diff(3x^2 + 2x + 1)", "6*x + 2", RuleSum},
+		{"diff(5)", "0", RuleConstant},
+		{"diff sin(x)*x", "x*cos(x) + sin(x)", RuleProduct},
+		{"diff(x/(x+1))", "1/((x + 1)^2)", RuleQuotient},
+		{"diff e^(2x)", "2*e^(2*x)", RuleChain},
+		{"diff ln(x^2 + 1)", "(2*x)/(x^2 + 1)", RuleChain},
+		{"diff 2^x", "2^x*ln(2)", RuleExponential},
+		{"diff x^x", "x^x + x^x*ln(x)", RuleLogarithmic},
+		{"diff(sqrt(x))", "1/(2*sqrt(x))", RulePower},
+		{"diff(y*x^2, y)", "x^2", RuleConstantFactor},
+	}
+	en := NewEngine()
+	for _, tt := range tests {
+		result, err := en.Solve(tt.input)
+		if err != nil {
+			t.Errorf("Solve(%q): %v", tt.input, err)
+			continue
+		}
+		if result.Final != tt.final {
+			t.Errorf("Solve(%q) = %s, want %s", tt.input, result.Final, tt.final)
+		}
+		named := false
+		for _, step := range result.Steps {
+			named = named || step.Rule == tt.rule
+		}
+		if !named {
+			t.Errorf("Solve(%q) steps do not apply the %s", tt.input, tt.rule)
+		}
+	}
+}
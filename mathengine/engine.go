@@ -0,0 +1,248 @@
+package mathengine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"maths-solution-backend/models"
+	"maths-solution-backend/parser"
+)
+
+// ErrUnsupported is returned for well-formed problems the engine cannot solve.
+var ErrUnsupported = errors.New("unsupported problem")
+
+// Result is a worked solution in the same shape the remote AI service returns.
+type Result struct {
+	Steps []models.SolutionStep
+	Final string
+}
+
+// Engine solves problems locally without any network access.
+type Engine struct{}
+
+func NewEngine() *Engine {
+	return &Engine{}
+}
+
+// Options tune a single solve.
+type Options struct {
+	// Precision is the number of significant digits of decimal results;
+	// zero means DefaultPrecision.
+	Precision int
+}
+
+// session accumulates the steps of a single solve.
+type session struct {
+	ctx       context.Context
+	steps     []models.SolutionStep
+	final     string
+	rootExprs []Expr
+	digits    int  // Significant digits of decimal approximations
+	decimal   bool // The problem was written with decimals
+}
+
+// cancelled returns the error of the solve's context once it is done.
+// Sessions without a context, such as those of Verify, run to completion.
+func (s *session) cancelled() error {
+	if s.ctx == nil {
+		return nil
+	}
+	return s.ctx.Err()
+}
+
+func (s *session) step(latex string) {
+	s.ruleStep("", latex)
+}
+
+// ruleStep records a step justified by a named rule.
+func (s *session) ruleStep(rule, latex string) {
+	s.steps = append(s.steps, models.SolutionStep{
+		Index: len(s.steps) + 1,
+		Latex: latex,
+		Rule:  rule,
+	})
+}
+
+// Solve parses input and solves it. Supported problems are arithmetic,
+// simplification, "expand ...", "factor ...", derivatives ("diff ..."),
+// indefinite integrals ("integrate ...") and linear, quadratic or
+// rationally factorable polynomial equations in one variable. Malformed
+// input yields a *parser.Error, and undefined values such as 1/0, ln(0) or
+// sqrt(-1) yield ErrUndefined.
+func (en *Engine) Solve(input string) (*Result, error) {
+	return en.SolveWithOptions(input, Options{})
+}
+
+// SolveWithOptions is Solve with per-request options.
+func (en *Engine) SolveWithOptions(input string, opts Options) (*Result, error) {
+	return en.SolveContext(context.Background(), input, opts)
+}
+
+// SolveContext is SolveWithOptions that gives up with ctx.Err() once ctx is
+// done. The context is checked between stages and in the search loops of
+// the solvers, not inside single simplifications.
+func (en *Engine) SolveContext(ctx context.Context, input string, opts Options) (*Result, error) {
+	node, err := parser.Parse(input)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s := &session{
+		ctx:     ctx,
+		digits:  ClampPrecision(opts.Precision),
+		decimal: hasDecimalLiteral(node),
+	}
+	if err := s.run(node); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return &Result{Steps: s.steps, Final: s.final}, nil
+}
+
+// hasDecimalLiteral reports whether node contains a number written with a
+// decimal point or an exponent, such as 0.5 or 1e-5.
+func hasDecimalLiteral(node parser.Node) bool {
+	switch n := node.(type) {
+	case *parser.Number:
+		return strings.ContainsAny(n.Text, ".eE")
+	case *parser.Unary:
+		return hasDecimalLiteral(n.X)
+	case *parser.Binary:
+		return hasDecimalLiteral(n.X) || hasDecimalLiteral(n.Y)
+	case *parser.Call:
+		for _, arg := range n.Args {
+			if hasDecimalLiteral(arg) {
+				return true
+			}
+		}
+	case *parser.Relation:
+		return hasDecimalLiteral(n.Left) || hasDecimalLiteral(n.Right)
+	}
+	return false
+}
+
+// splitCommand separates a command such as solve(x^2 = 4, x) into its name,
+// optional variable and the problem it applies to.
+func splitCommand(node parser.Node) (command, variable string, body parser.Node) {
+	call, ok := node.(*parser.Call)
+	if !ok {
+		return "", "", node
+	}
+	if _, isCommand := parser.Commands[call.Func]; !isCommand {
+		return "", "", node
+	}
+	if len(call.Args) > 1 {
+		if ident, ok := call.Args[1].(*parser.Ident); ok {
+			variable = ident.Name
+		}
+	}
+	return call.Func, variable, call.Args[0]
+}
+
+func (s *session) run(node parser.Node) error {
+	command, variable, node := splitCommand(node)
+
+	if rel, ok := node.(*parser.Relation); ok {
+		if command != "" && command != "solve" {
+			return fmt.Errorf("%w: %s does not apply to equations", ErrUnsupported, command)
+		}
+		if rel.Op != "=" {
+			return fmt.Errorf("%w: inequalities", ErrUnsupported)
+		}
+		left, err := FromNode(rel.Left)
+		if err != nil {
+			return err
+		}
+		right, err := FromNode(rel.Right)
+		if err != nil {
+			return err
+		}
+		if err := checkDefined(&Add{Terms: []Expr{left, right}}); err != nil {
+			return err
+		}
+		if err := s.cancelled(); err != nil {
+			return err
+		}
+		return s.solveEquation(left, right, variable)
+	}
+
+	e, err := FromNode(node)
+	if err != nil {
+		return err
+	}
+	if err := checkDefined(e); err != nil {
+		return err
+	}
+	if err := s.cancelled(); err != nil {
+		return err
+	}
+	switch command {
+	case "solve":
+		return s.solveEquation(e, NewInt(0), variable)
+	case "expand":
+		expanded, err := ExpandAll(e)
+		if err != nil {
+			return err
+		}
+		s.rewrite(e, expanded)
+	case "factor":
+		s.rewrite(e, Factor(e))
+	case "diff", "derivative", "differentiate":
+		return s.differentiate(e, variable)
+	case "integrate", "integral", "antiderivative":
+		return s.integrate(e, variable)
+	default:
+		s.evaluate(e)
+	}
+	return nil
+}
+
+// rewrite records e becoming result as the final answer.
+func (s *session) rewrite(e, result Expr) {
+	s.step(e.Latex())
+	s.step(`= ` + result.Latex())
+	s.final = result.String()
+}
+
+func (s *session) evaluate(e Expr) {
+	s.step(e.Latex())
+	simplified := Simplify(e)
+	if expanded := Expand(simplified); len(expanded.String()) < len(simplified.String()) {
+		simplified = expanded
+	}
+	if simplified.String() != e.String() {
+		s.step(`= ` + simplified.Latex())
+	}
+	s.final = simplified.String()
+
+	// Irrational or fractional constants also get a decimal approximation,
+	// which becomes the answer when the problem was written in decimals
+	if len(FreeSymbols(simplified)) == 0 {
+		if r, ok := isNum(simplified); !ok || !r.IsInt() {
+			if decimal, ok := s.approx(simplified); ok {
+				exact, isExact := new(big.Rat).SetString(decimal)
+				if r != nil && isExact && exact.Cmp(r) == 0 {
+					s.step(`= ` + decimal)
+				} else {
+					s.step(`\approx ` + decimal)
+				}
+				if s.decimal {
+					s.final = decimal
+				}
+			}
+		}
+	}
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', 10, 64)
+}
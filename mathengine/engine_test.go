@@ -0,0 +1,42 @@
+package mathengine
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestSolveContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	en := NewEngine()
+	for _, input := range []string{"1 + 1", "x^3 - 6x^2 + 11x - 6 = 0", "integrate x*sin(x)"} {
+		if _, err := en.SolveContext(ctx, input, Options{}); !errors.Is(err, context.Canceled) {
+			t.Errorf("SolveContext(%q) error = %v, want context.Canceled", input, err)
+		}
+	}
+}
+
+func TestSolveDecimalInput(t *testing.T) {
+	tests := []struct {
+		input string
+		final string
+	}{
+		{"1/4 + 1/2", "3/4"},
+		{"0.25 + 1/2", "0.75"},
+		{"1e-5*3", "0.00003"},
+		{"2E3/3", "666.6666667"},
+		{"e/e + 1/2", "3/2"},
+	}
+	en := NewEngine()
+	for _, tt := range tests {
+		result, err := en.Solve(tt.input)
+		if err != nil {
+			t.Errorf("Solve(%q): %v", tt.input, err)
+			continue
+		}
+		if result.Final != tt.final {
+			t.Errorf("Solve(%q) = %s, want %s", tt.input, result.Final, tt.final)
+		}
+	}
+}
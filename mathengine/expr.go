@@ -0,0 +1,510 @@
+// Package mathengine is a small pure-Go computer algebra system used as an
+// offline solver: it simplifies expressions, expands and factors polynomials
+// and solves linear and quadratic equations, producing step-by-step LaTeX.
+package mathengine
+
+import (
+	"math/big"
+	"strings"
+)
+
+// Expr is an immutable symbolic expression. Sub and Div are represented as
+// Add/Mul with negated or inverted operands, so only five node kinds exist.
+type Expr interface {
+	String() string
+	Latex() string
+}
+
+type Num struct {
+	Val *big.Rat
+}
+
+type Sym struct {
+	Name string
+}
+
+type Add struct {
+	Terms []Expr
+}
+
+type Mul struct {
+	Factors []Expr
+}
+
+type Pow struct {
+	Base Expr
+	Exp  Expr
+}
+
+type Func struct {
+	Name string
+	Arg  Expr
+}
+
+var (
+	zero = big.NewRat(0, 1)
+	one  = big.NewRat(1, 1)
+	half = big.NewRat(1, 2)
+)
+
+func NewInt(n int64) *Num {
+	return &Num{Val: big.NewRat(n, 1)}
+}
+
+func NewRat(a, b int64) *Num {
+	return &Num{Val: big.NewRat(a, b)}
+}
+
+// NewNum copies r so callers may keep mutating their own value.
+func NewNum(r *big.Rat) *Num {
+	return &Num{Val: new(big.Rat).Set(r)}
+}
+
+func NewSym(name string) *Sym {
+	return &Sym{Name: name}
+}
+
+func Neg(e Expr) Expr {
+	return &Mul{Factors: []Expr{NewInt(-1), e}}
+}
+
+func Sub(a, b Expr) Expr {
+	return &Add{Terms: []Expr{a, Neg(b)}}
+}
+
+func Div(a, b Expr) Expr {
+	return &Mul{Factors: []Expr{a, &Pow{Base: b, Exp: NewInt(-1)}}}
+}
+
+func Sqrt(e Expr) Expr {
+	return &Pow{Base: e, Exp: NewRat(1, 2)}
+}
+
+func isNum(e Expr) (*big.Rat, bool) {
+	if n, ok := e.(*Num); ok {
+		return n.Val, true
+	}
+	return nil, false
+}
+
+func isInt(e Expr) (*big.Int, bool) {
+	if r, ok := isNum(e); ok && r.IsInt() {
+		return r.Num(), true
+	}
+	return nil, false
+}
+
+func isZero(e Expr) bool {
+	r, ok := isNum(e)
+	return ok && r.Sign() == 0
+}
+
+func isOne(e Expr) bool {
+	r, ok := isNum(e)
+	return ok && r.Cmp(one) == 0
+}
+
+// isConstant reports whether name is a mathematical constant rather than a variable.
+func isConstant(name string) bool {
+	return name == "pi" || name == "e"
+}
+
+// FreeSymbols returns the variables appearing in e, sorted, without constants.
+func FreeSymbols(e Expr) []string {
+	seen := map[string]bool{}
+	var walk func(Expr)
+	walk = func(e Expr) {
+		switch e := e.(type) {
+		case *Sym:
+			if !isConstant(e.Name) {
+				seen[e.Name] = true
+			}
+		case *Add:
+			for _, t := range e.Terms {
+				walk(t)
+			}
+		case *Mul:
+			for _, f := range e.Factors {
+				walk(f)
+			}
+		case *Pow:
+			walk(e.Base)
+			walk(e.Exp)
+		case *Func:
+			walk(e.Arg)
+		}
+	}
+	walk(e)
+	return sortedKeys(seen)
+}
+
+// Contains reports whether the symbol name occurs anywhere in e.
+func Contains(e Expr, name string) bool {
+	for _, s := range FreeSymbols(e) {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Substitute replaces every occurrence of the symbol name with value.
+func Substitute(e Expr, name string, value Expr) Expr {
+	switch e := e.(type) {
+	case *Sym:
+		if e.Name == name {
+			return value
+		}
+		return e
+	case *Add:
+		terms := make([]Expr, len(e.Terms))
+		for i, t := range e.Terms {
+			terms[i] = Substitute(t, name, value)
+		}
+		return &Add{Terms: terms}
+	case *Mul:
+		factors := make([]Expr, len(e.Factors))
+		for i, f := range e.Factors {
+			factors[i] = Substitute(f, name, value)
+		}
+		return &Mul{Factors: factors}
+	case *Pow:
+		return &Pow{Base: Substitute(e.Base, name, value), Exp: Substitute(e.Exp, name, value)}
+	case *Func:
+		return &Func{Name: e.Name, Arg: Substitute(e.Arg, name, value)}
+	}
+	return e
+}
+
+// Equal reports structural equality of two canonical expressions.
+func Equal(a, b Expr) bool {
+	return a.String() == b.String()
+}
+
+// ---- ASCII printing ----
+
+const (
+	precAdd = iota + 1
+	precMul
+	precNeg
+	precPow
+	precAtom
+)
+
+func precedence(e Expr) int {
+	switch e := e.(type) {
+	case *Num:
+		if e.Val.Sign() < 0 || !e.Val.IsInt() {
+			return precMul
+		}
+		return precAtom
+	case *Add:
+		return precAdd
+	case *Mul:
+		if c, _ := splitCoeff(e); c.Sign() < 0 {
+			return precNeg
+		}
+		return precMul
+	case *Pow:
+		if isSqrt(e) {
+			return precAtom
+		}
+		if r, ok := isNum(e.Exp); ok && r.Sign() < 0 {
+			return precMul
+		}
+		return precPow
+	}
+	return precAtom
+}
+
+func wrap(s string, inner, outer int) string {
+	if inner < outer {
+		return "(" + s + ")"
+	}
+	return s
+}
+
+func (n *Num) String() string {
+	return n.Val.RatString()
+}
+
+func (s *Sym) String() string {
+	return s.Name
+}
+
+func (a *Add) String() string {
+	var b strings.Builder
+	for i, t := range a.Terms {
+		if i > 0 {
+			if negated, ok := negate(t); ok {
+				b.WriteString(" - ")
+				b.WriteString(wrap(negated.String(), precedence(negated), precMul))
+				continue
+			}
+			b.WriteString(" + ")
+		}
+		b.WriteString(t.String())
+	}
+	return b.String()
+}
+
+func (m *Mul) String() string {
+	coef, rest := splitCoeff(m)
+	if coef.Sign() < 0 {
+		inner := mulOf(new(big.Rat).Neg(coef), rest)
+		return "-" + wrap(inner.String(), precedence(inner), precMul)
+	}
+
+	num, den := numeratorDenominator(m)
+	format := func(factors []Expr) string {
+		parts := make([]string, len(factors))
+		for i, f := range factors {
+			parts[i] = wrap(f.String(), precedence(f), precMul)
+		}
+		return strings.Join(parts, "*")
+	}
+	if len(den) == 0 {
+		return format(num)
+	}
+	numStr := "1"
+	if len(num) > 0 {
+		numStr = format(num)
+		if len(num) > 1 {
+			numStr = "(" + numStr + ")"
+		}
+	}
+	denStr := format(den)
+	if p := precedence(den[0]); len(den) > 1 || (p >= precMul && p < precAtom) {
+		denStr = "(" + denStr + ")"
+	}
+	return numStr + "/" + denStr
+}
+
+func (p *Pow) String() string {
+	if isSqrt(p) {
+		return "sqrt(" + p.Base.String() + ")"
+	}
+	if r, ok := isNum(p.Exp); ok && r.Sign() < 0 {
+		inv := simplifyPow(p.Base, NewNum(new(big.Rat).Neg(r)))
+		return "1/" + wrap(inv.String(), precedence(inv), precAtom)
+	}
+	base := wrap(p.Base.String(), precedence(p.Base), precAtom)
+	exp := wrap(p.Exp.String(), precedence(p.Exp), precAtom)
+	return base + "^" + exp
+}
+
+func (f *Func) String() string {
+	return f.Name + "(" + f.Arg.String() + ")"
+}
+
+// ---- LaTeX printing ----
+
+var latexFuncs = map[string]string{
+	"sin": `\sin`, "cos": `\cos`, "tan": `\tan`,
+	"sec": `\sec`, "csc": `\csc`, "cot": `\cot`,
+	"asin": `\arcsin`, "acos": `\arccos`, "atan": `\arctan`,
+	"sinh": `\sinh`, "cosh": `\cosh`, "tanh": `\tanh`,
+	"ln": `\ln`, "log": `\log`,
+}
+
+func latexRat(r *big.Rat) string {
+	if r.IsInt() {
+		return r.Num().String()
+	}
+	if r.Sign() < 0 {
+		abs := new(big.Rat).Neg(r)
+		return `-\frac{` + abs.Num().String() + `}{` + abs.Denom().String() + `}`
+	}
+	return `\frac{` + r.Num().String() + `}{` + r.Denom().String() + `}`
+}
+
+func latexWrap(s string, inner, outer int) string {
+	if inner < outer {
+		return `\left(` + s + `\right)`
+	}
+	return s
+}
+
+func (n *Num) Latex() string {
+	return latexRat(n.Val)
+}
+
+func (s *Sym) Latex() string {
+	if s.Name == "pi" || s.Name == "lambda" {
+		return `\` + s.Name
+	}
+	if len(s.Name) > 1 {
+		return `\mathrm{` + s.Name + `}`
+	}
+	return s.Name
+}
+
+func (a *Add) Latex() string {
+	var b strings.Builder
+	for i, t := range a.Terms {
+		if i > 0 {
+			if negated, ok := negate(t); ok {
+				b.WriteString(" - ")
+				b.WriteString(latexWrap(negated.Latex(), precedence(negated), precMul))
+				continue
+			}
+			b.WriteString(" + ")
+		}
+		b.WriteString(t.Latex())
+	}
+	return b.String()
+}
+
+func (m *Mul) Latex() string {
+	coef, rest := splitCoeff(m)
+	if coef.Sign() < 0 {
+		inner := mulOf(new(big.Rat).Neg(coef), rest)
+		return "-" + latexWrap(inner.Latex(), precedence(inner), precMul)
+	}
+
+	num, den := numeratorDenominator(m)
+	format := func(factors []Expr) string {
+		var b strings.Builder
+		if len(factors) == 1 && len(den) > 0 {
+			// A lone factor inside \frac needs no parentheses
+			return factors[0].Latex()
+		}
+		for i, f := range factors {
+			s := latexWrap(f.Latex(), precedence(f), precMul)
+			if i > 0 {
+				// Two adjacent numbers need an explicit operator to stay readable
+				if _, prevNum := isNum(factors[i-1]); prevNum {
+					if _, curNum := isNum(f); curNum {
+						b.WriteString(` \cdot `)
+					}
+				}
+			}
+			b.WriteString(s)
+		}
+		return b.String()
+	}
+	if len(den) == 0 {
+		return format(num)
+	}
+	numStr := "1"
+	if len(num) > 0 {
+		numStr = format(num)
+	}
+	return `\frac{` + numStr + `}{` + format(den) + `}`
+}
+
+func (p *Pow) Latex() string {
+	if r, ok := isNum(p.Exp); ok {
+		if r.Sign() < 0 {
+			inv := simplifyPow(p.Base, NewNum(new(big.Rat).Neg(r)))
+			return `\frac{1}{` + inv.Latex() + `}`
+		}
+		if r.Num().IsInt64() && r.Num().Int64() == 1 && !r.IsInt() {
+			if r.Denom().Int64() == 2 {
+				return `\sqrt{` + p.Base.Latex() + `}`
+			}
+			return `\sqrt[` + r.Denom().String() + `]{` + p.Base.Latex() + `}`
+		}
+	}
+	if s, ok := p.Base.(*Sym); ok && s.Name == "e" {
+		return `e^{` + p.Exp.Latex() + `}`
+	}
+	base := latexWrap(p.Base.Latex(), precedence(p.Base), precAtom)
+	if _, ok := p.Base.(*Func); ok {
+		base = `\left(` + p.Base.Latex() + `\right)`
+	}
+	return base + `^{` + p.Exp.Latex() + `}`
+}
+
+func (f *Func) Latex() string {
+	name, ok := latexFuncs[f.Name]
+	if !ok {
+		if f.Name == "abs" {
+			return `\left|` + f.Arg.Latex() + `\right|`
+		}
+		name = `\operatorname{` + f.Name + `}`
+	}
+	return name + `\left(` + f.Arg.Latex() + `\right)`
+}
+
+// ---- shared helpers ----
+
+// splitCoeff separates the leading rational coefficient from the rest of a term.
+// rest is nil when the term is purely numeric.
+func splitCoeff(e Expr) (*big.Rat, Expr) {
+	switch e := e.(type) {
+	case *Num:
+		return e.Val, nil
+	case *Mul:
+		if len(e.Factors) > 0 {
+			if r, ok := isNum(e.Factors[0]); ok {
+				rest := e.Factors[1:]
+				switch len(rest) {
+				case 0:
+					return r, nil
+				case 1:
+					return r, rest[0]
+				default:
+					return r, &Mul{Factors: rest}
+				}
+			}
+		}
+	}
+	return one, e
+}
+
+// mulOf rebuilds coef*rest without re-simplifying.
+func mulOf(coef *big.Rat, rest Expr) Expr {
+	if rest == nil {
+		return NewNum(coef)
+	}
+	if coef.Cmp(one) == 0 {
+		return rest
+	}
+	factors := []Expr{NewNum(coef)}
+	if m, ok := rest.(*Mul); ok {
+		factors = append(factors, m.Factors...)
+	} else {
+		factors = append(factors, rest)
+	}
+	return &Mul{Factors: factors}
+}
+
+// negate returns -e when e prints with a leading minus sign.
+func negate(e Expr) (Expr, bool) {
+	coef, rest := splitCoeff(e)
+	if coef.Sign() >= 0 {
+		return nil, false
+	}
+	return mulOf(new(big.Rat).Neg(coef), rest), true
+}
+
+// numeratorDenominator splits a product into factors with positive and
+// negative exponents, for printing as a fraction.
+func numeratorDenominator(m *Mul) ([]Expr, []Expr) {
+	var num, den []Expr
+	for _, f := range m.Factors {
+		switch f := f.(type) {
+		case *Num:
+			if !f.Val.IsInt() {
+				if f.Val.Num().Cmp(big.NewInt(1)) != 0 {
+					num = append(num, &Num{Val: new(big.Rat).SetInt(f.Val.Num())})
+				}
+				den = append(den, &Num{Val: new(big.Rat).SetInt(f.Val.Denom())})
+				continue
+			}
+		case *Pow:
+			if r, ok := isNum(f.Exp); ok && r.Sign() < 0 {
+				den = append(den, simplifyPow(f.Base, NewNum(new(big.Rat).Neg(r))))
+				continue
+			}
+		}
+		num = append(num, f)
+	}
+	return num, den
+}
+
+func isSqrt(p *Pow) bool {
+	r, ok := isNum(p.Exp)
+	return ok && r.Cmp(half) == 0
+}
@@ -0,0 +1,78 @@
+package mathengine
+
+import (
+	"maths-solution-backend/models"
+	"maths-solution-backend/parser"
+)
+
+// ruleHints explain each rule as a strategy to try, never as its outcome.
+var ruleHints = map[string]string{
+	RuleConstant:           "The derivative of a constant is zero.",
+	RuleIdentity:           "The derivative of the variable itself is 1.",
+	RuleSum:                "Differentiate a sum term by term.",
+	RuleConstantFactor:     "Constant factors can be taken outside the derivative.",
+	RuleProduct:            "A product of two functions calls for the product rule: (uv)' = u'v + uv'.",
+	RuleQuotient:           "A quotient calls for the quotient rule: (u/v)' = (u'v - uv')/v^2.",
+	RulePower:              "Use the power rule: d/dx x^n = n x^(n-1).",
+	RuleChain:              "There is a function inside another function: differentiate the outer one and multiply by the derivative of the inner one.",
+	RuleExponential:        "Recall how exponential functions differentiate; a base other than e brings in its logarithm.",
+	RuleLogarithmic:        "With the variable in both base and exponent, take logarithms before differentiating.",
+	RuleLogarithm:          "Recall the derivative of ln(x), and use the chain rule for a logarithm of a function.",
+	RuleLinearSubstitution: "The integrand is a standard function of a linear expression ax + b: integrate as usual and divide by a.",
+	RuleStandardIntegral:   "Look for the integrand in a table of standard integrals.",
+	RuleExpand:             "Multiply out the brackets first so that each term can be handled on its own.",
+	RulePolynomialDivision: "The numerator's degree is not lower than the denominator's: divide the polynomials first.",
+	RulePartialFractions:   "Factor the denominator and split the fraction into partial fractions.",
+	RuleQuadratic:          "Complete the square in the quadratic.",
+	RuleSubstitution:       "Part of the integrand is the derivative of another part: try a substitution u = ...",
+	RuleByParts:            "The integrand is a product of two different kinds of function: try integration by parts.",
+	RuleCheck:              "Check your antiderivative by differentiating it.",
+	RuleAugmentedMatrix:    "Write the system as an augmented matrix of coefficients and constants.",
+	RuleSwapRows:           "Swap rows so that the pivot is not zero.",
+	RuleScaleRow:           "Scale the pivot row so that its pivot becomes 1.",
+	RuleEliminate:          "Subtract multiples of the pivot row to clear the rest of its column.",
+	RuleReadSolution:       "Read the values of the unknowns off the reduced matrix; a row 0 = c with c nonzero means no solution.",
+	RuleDeterminant:        "The determinant is the product of the pivots, with a sign change for each row swap.",
+	RuleInverse:            "Row-reduce [A | I]; when the left half becomes I, the right half is the inverse.",
+	RuleCharacteristic:     "The eigenvalues are the roots of det(lambda I - A) = 0.",
+	RuleEigenvalues:        "Look for rational roots of the characteristic polynomial, then use the quadratic formula on what is left.",
+}
+
+// Hints returns conceptual hints for solving input: a strategy for its kind
+// of problem, then one hint per distinct rule the worked steps apply. They
+// name the techniques without giving away any result.
+func Hints(input string, steps []models.SolutionStep) []string {
+	hints := []string{problemHint(input)}
+	seen := map[string]bool{}
+	for _, step := range steps {
+		hint, ok := ruleHints[step.Rule]
+		if !ok || seen[step.Rule] {
+			continue
+		}
+		seen[step.Rule] = true
+		hints = append(hints, hint)
+	}
+	return hints
+}
+
+func problemHint(input string) string {
+	node, err := parser.Parse(input)
+	if err != nil {
+		return "Simplify step by step, following the order of operations."
+	}
+	command, _, body := splitCommand(node)
+	if _, ok := body.(*parser.Relation); ok || command == "solve" {
+		return "Collect every term on one side so the equation reads ... = 0, then look for a factorisation or use the quadratic formula."
+	}
+	switch command {
+	case "expand":
+		return "Multiply out every bracket and collect like terms."
+	case "factor":
+		return "Take out any common factor first, then look for patterns such as a difference of squares or a factorable quadratic."
+	case "diff", "derivative", "differentiate":
+		return "Identify the outermost operation of the expression and apply the matching differentiation rule."
+	case "integrate", "integral", "antiderivative":
+		return "Look for a standard integral, a substitution or integration by parts, and check the result by differentiating."
+	}
+	return "Simplify step by step, following the order of operations."
+}
@@ -0,0 +1,51 @@
+package mathengine
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHints(t *testing.T) {
+	tests := []struct {
+		input    string
+		first    string // Start of the strategy hint
+		ruleHint string // Hint of a rule the worked steps apply
+	}{
+		{"x^2 - 5x + 6 = 0", "Collect every term", ""},
+		{"expand (x + 1)(x + 2)", "Multiply out every bracket", ""},
+		{"factor x^2 - 9", "Take out any common factor", ""},
+		{"diff sin(x^2)", "Identify the outermost operation", ruleHints[RuleChain]},
+		{"diff x^3 + x", "Identify the outermost operation", ruleHints[RuleSum]},
+		{"integrate x*e^x", "Look for a standard integral", ruleHints[RuleByParts]},
+		{"2 + 3*4", "Simplify step by step", ""},
+	}
+	en := NewEngine()
+	for _, tt := range tests {
+		result, err := en.Solve(tt.input)
+		if err != nil {
+			t.Errorf("Solve(%q): %v", tt.input, err)
+			continue
+		}
+		hints := Hints(tt.input, result.Steps)
+		if !strings.HasPrefix(hints[0], tt.first) {
+			t.Errorf("Hints(%q)[0] = %q, want it to start with %q", tt.input, hints[0], tt.first)
+		}
+
+		seen := map[string]bool{}
+		found := tt.ruleHint == ""
+		for _, hint := range hints {
+			if seen[hint] {
+				t.Errorf("Hints(%q) repeats %q", tt.input, hint)
+			}
+			seen[hint] = true
+			found = found || hint == tt.ruleHint
+			// Hints must not give the answer away
+			if strings.Contains(hint, result.Final) {
+				t.Errorf("Hints(%q) reveal the answer %s in %q", tt.input, result.Final, hint)
+			}
+		}
+		if !found {
+			t.Errorf("Hints(%q) = %q, want %q among them", tt.input, hints, tt.ruleHint)
+		}
+	}
+}
@@ -0,0 +1,939 @@
+package mathengine
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"maths-solution-backend/models"
+)
+
+// Rule names reported on integration steps, besides those shared with
+// differentiation.
+const (
+	RuleLinearSubstitution = "Linear substitution"
+	RuleStandardIntegral   = "Standard integral"
+	RuleExpand             = "Expand"
+	RulePolynomialDivision = "Polynomial division"
+	RulePartialFractions   = "Partial fractions"
+	RuleQuadratic          = "Completing the square"
+	RuleSubstitution       = "u-substitution"
+	RuleByParts            = "Integration by parts"
+	RuleHalfAngle          = "Half-angle identity"
+	RuleSolveForIntegral   = "Solve for the integral"
+	RuleCheck              = "Check by differentiation"
+)
+
+// maxIntegrationDepth bounds the recursion of substitution and by-parts
+// attempts, which would otherwise cycle on integrands like e^x sin(x).
+const maxIntegrationDepth = 8
+
+// integrator applies integration rules recursively, recording one step per
+// rule application when s is set.
+type integrator struct {
+	v     string
+	s     *session
+	depth int
+}
+
+// Integrate returns an antiderivative of e with respect to v, without the
+// constant of integration.
+func Integrate(e Expr, v string) (Expr, error) {
+	in := &integrator{v: v}
+	result, err := in.integrate(Simplify(e))
+	if err != nil {
+		return nil, err
+	}
+	return tidy(result), nil
+}
+
+// integrate solves an indefinite integral with steps and checks the result
+// by differentiating it back.
+func (s *session) integrate(e Expr, variable string) error {
+	e = Simplify(e)
+	variable, err := unknownOf(e, variable, "integrate")
+	if err != nil {
+		return err
+	}
+
+	in := &integrator{v: variable, s: s}
+	s.step(in.integral(e))
+	result, err := in.integrate(e)
+	if err != nil {
+		return err
+	}
+	result = tidy(result)
+	s.ruleStep(RuleSimplify, in.integral(e)+" = "+result.Latex()+" + C")
+
+	derivative, err := Diff(result, variable)
+	if err != nil {
+		return err
+	}
+	if check := compareExpressions(e, derivative, -1); check.Status != models.VerificationVerified {
+		return fmt.Errorf("%w: the antiderivative found for %s does not differentiate back to it", ErrUnsupported, e.String())
+	}
+	d := &differentiator{v: variable}
+	s.ruleStep(RuleCheck, d.dx(result)+" = "+e.Latex())
+	s.final = result.String() + " + C"
+	return nil
+}
+
+func (in *integrator) x() Expr {
+	return NewSym(in.v)
+}
+
+// integral renders ∫ e dv.
+func (in *integrator) integral(e Expr) string {
+	return `\int ` + factorLatex(e) + ` \, d` + in.x().Latex()
+}
+
+func (in *integrator) step(rule, latex string) {
+	if in.s != nil {
+		in.s.ruleStep(rule, latex)
+	}
+}
+
+// mark and reset discard the steps of an attempt that did not work out.
+func (in *integrator) mark() int {
+	if in.s == nil {
+		return 0
+	}
+	return len(in.s.steps)
+}
+
+func (in *integrator) reset(mark int) {
+	if in.s != nil {
+		in.s.steps = in.s.steps[:mark]
+	}
+}
+
+// cancelled returns the error of a done session context, if any.
+func (in *integrator) cancelled() error {
+	if in.s == nil {
+		return nil
+	}
+	return in.s.cancelled()
+}
+
+func (in *integrator) integrate(e Expr) (Expr, error) {
+	if err := in.cancelled(); err != nil {
+		return nil, err
+	}
+	if in.depth >= maxIntegrationDepth {
+		return nil, fmt.Errorf("%w: integral of %s", ErrUnsupported, e.String())
+	}
+	in.depth++
+	defer func() { in.depth-- }()
+
+	if !Contains(e, in.v) {
+		result := simplifyMul([]Expr{e, in.x()})
+		in.step(RuleConstant, in.integral(e)+" = "+result.Latex())
+		return result, nil
+	}
+
+	switch e := e.(type) {
+	case *Add:
+		return in.sum(e)
+	case *Mul:
+		if result, ok, err := in.constantMultiple(e); ok {
+			return result, err
+		}
+	}
+
+	if result, rule, ok := in.standard(e); ok {
+		in.step(rule, in.integral(e)+" = "+result.Latex())
+		return result, nil
+	}
+
+	for _, method := range []func(Expr) (Expr, bool){in.halfAngle, in.rational, in.expand, in.substitution, in.cyclicParts, in.byParts} {
+		mark := in.mark()
+		if result, ok := method(e); ok {
+			return result, nil
+		}
+		in.reset(mark)
+		if err := in.cancelled(); err != nil {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("%w: cannot integrate %s", ErrUnsupported, e.String())
+}
+
+func (in *integrator) sum(e *Add) (Expr, error) {
+	var b strings.Builder
+	for i, t := range e.Terms {
+		if neg, ok := negate(t); ok {
+			b.WriteString(" - " + in.integral(neg))
+			continue
+		}
+		if i > 0 {
+			b.WriteString(" + ")
+		}
+		b.WriteString(in.integral(t))
+	}
+	expansion := b.String()
+	if strings.HasPrefix(expansion, " - ") {
+		expansion = "-" + expansion[len(" - "):]
+	}
+	in.step(RuleSum, in.integral(e)+" = "+expansion)
+
+	terms := make([]Expr, len(e.Terms))
+	for i, t := range e.Terms {
+		neg, isNeg := negate(t)
+		if isNeg {
+			t = neg
+		}
+		it, err := in.integrate(t)
+		if err != nil {
+			return nil, err
+		}
+		if isNeg {
+			it = Neg(it)
+		}
+		terms[i] = it
+	}
+	return Simplify(&Add{Terms: terms}), nil
+}
+
+// constantMultiple pulls factors free of the variable out of the integral.
+func (in *integrator) constantMultiple(e *Mul) (Expr, bool, error) {
+	var constants, varying []Expr
+	for _, f := range e.Factors {
+		if Contains(f, in.v) {
+			varying = append(varying, f)
+		} else {
+			constants = append(constants, f)
+		}
+	}
+	if len(constants) == 0 {
+		return nil, false, nil
+	}
+
+	c, u := simplifyMul(constants), simplifyMul(varying)
+	in.step(RuleConstantFactor, in.integral(e)+" = "+coefficientLatex(c)+in.integral(u))
+	iu, err := in.integrate(u)
+	if err != nil {
+		return nil, true, err
+	}
+	return Simplify(&Mul{Factors: []Expr{c, iu}}), true, nil
+}
+
+// linear returns a when e is a·v + b with a ≠ 0.
+func (in *integrator) linear(e Expr) (*big.Rat, bool) {
+	p, ok := ToPoly(e, in.v)
+	if !ok || p.Degree() != 1 {
+		return nil, false
+	}
+	return p.Coeff(1), true
+}
+
+// standard looks e up in the table of elementary antiderivatives, allowing
+// a linear inner function a·x + b.
+func (in *integrator) standard(e Expr) (Expr, string, bool) {
+	over := func(f Expr, a *big.Rat) Expr {
+		return Simplify(&Mul{Factors: []Expr{f, NewNum(new(big.Rat).Inv(a))}})
+	}
+	ruleFor := func(inner Expr, rule string) string {
+		if s, ok := inner.(*Sym); ok && s.Name == in.v {
+			return rule
+		}
+		return RuleLinearSubstitution
+	}
+
+	switch e := e.(type) {
+	case *Sym:
+		return Simplify(Div(&Pow{Base: e, Exp: NewInt(2)}, NewInt(2))), RulePower, true
+	case *Pow:
+		baseVaries, expVaries := Contains(e.Base, in.v), Contains(e.Exp, in.v)
+		switch {
+		case baseVaries && !expVaries:
+			if a, ok := in.linear(e.Base); ok {
+				if r, ok := isNum(e.Exp); ok && r.Cmp(big.NewRat(-1, 1)) == 0 {
+					return over(&Func{Name: "ln", Arg: &Func{Name: "abs", Arg: e.Base}}, a), ruleFor(e.Base, RuleLogarithm), true
+				}
+				n := Simplify(&Add{Terms: []Expr{e.Exp, NewInt(1)}})
+				return over(Div(&Pow{Base: e.Base, Exp: n}, n), a), ruleFor(e.Base, RulePower), true
+			}
+			if result, ok := in.arcsine(e); ok {
+				return result, RuleStandardIntegral, true
+			}
+			if result, ok := in.squaredTrig(e); ok {
+				return result, RuleStandardIntegral, true
+			}
+		case !baseVaries:
+			a, ok := in.linear(e.Exp)
+			if !ok {
+				return nil, "", false
+			}
+			if s, ok := e.Base.(*Sym); ok && s.Name == "e" {
+				return over(e, a), ruleFor(e.Exp, RuleExponential), true
+			}
+			return over(Div(e, &Func{Name: "ln", Arg: e.Base}), a), ruleFor(e.Exp, RuleExponential), true
+		}
+	case *Func:
+		a, ok := in.linear(e.Arg)
+		if !ok {
+			return nil, "", false
+		}
+		if f, ok := standardAntiderivative(e.Name, e.Arg); ok {
+			return over(f, a), ruleFor(e.Arg, RuleStandardIntegral), true
+		}
+	}
+	return nil, "", false
+}
+
+// standardAntiderivative returns ∫ f(u) du for the named function.
+func standardAntiderivative(name string, u Expr) (Expr, bool) {
+	fn := func(name string) Expr { return &Func{Name: name, Arg: u} }
+	lnAbs := func(e Expr) Expr { return &Func{Name: "ln", Arg: &Func{Name: "abs", Arg: e}} }
+	neg := func(e Expr) Expr { return Neg(e) }
+	uTimes := func(e Expr) Expr { return &Mul{Factors: []Expr{u, e}} }
+	sqrtOneMinusU2 := Sqrt(&Add{Terms: []Expr{NewInt(1), Neg(&Pow{Base: u, Exp: NewInt(2)})}})
+
+	switch name {
+	case "sin":
+		return neg(fn("cos")), true
+	case "cos":
+		return fn("sin"), true
+	case "tan":
+		return neg(lnAbs(fn("cos"))), true
+	case "cot":
+		return lnAbs(fn("sin")), true
+	case "sec":
+		return lnAbs(&Add{Terms: []Expr{fn("sec"), fn("tan")}}), true
+	case "csc":
+		return neg(lnAbs(&Add{Terms: []Expr{fn("csc"), fn("cot")}})), true
+	case "sinh":
+		return fn("cosh"), true
+	case "cosh":
+		return fn("sinh"), true
+	case "tanh":
+		return &Func{Name: "ln", Arg: fn("cosh")}, true
+	case "ln":
+		return Sub(uTimes(fn("ln")), u), true
+	case "log":
+		return Div(Sub(uTimes(&Func{Name: "ln", Arg: u}), u), &Func{Name: "ln", Arg: NewInt(10)}), true
+	case "asin":
+		return &Add{Terms: []Expr{uTimes(fn("asin")), sqrtOneMinusU2}}, true
+	case "acos":
+		return Sub(uTimes(fn("acos")), sqrtOneMinusU2), true
+	case "atan":
+		return Sub(uTimes(fn("atan")), Div(&Func{Name: "ln", Arg: &Add{Terms: []Expr{NewInt(1), &Pow{Base: u, Exp: NewInt(2)}}}}, NewInt(2))), true
+	case "abs":
+		return Div(uTimes(fn("abs")), NewInt(2)), true
+	}
+	return nil, false
+}
+
+// arcsine handles (c - d·x²)^(-1/2) = asin(x·√(d/c))/√d for c, d > 0.
+func (in *integrator) arcsine(e *Pow) (Expr, bool) {
+	r, ok := isNum(e.Exp)
+	if !ok || r.Cmp(big.NewRat(-1, 2)) != 0 {
+		return nil, false
+	}
+	p, ok := ToPoly(e.Base, in.v)
+	if !ok || p.Degree() != 2 || p.Coeff(1).Sign() != 0 || p.Coeff(0).Sign() <= 0 || p.Coeff(2).Sign() >= 0 {
+		return nil, false
+	}
+	c, d := p.Coeff(0), new(big.Rat).Neg(p.Coeff(2))
+	arg := &Mul{Factors: []Expr{in.x(), Sqrt(NewNum(new(big.Rat).Quo(d, c)))}}
+	return Simplify(Div(&Func{Name: "asin", Arg: arg}, Sqrt(NewNum(d)))), true
+}
+
+// squaredTrig handles sec², csc² and their reciprocal forms of a linear
+// argument; sin² and cos² are left to halfAngle.
+func (in *integrator) squaredTrig(e *Pow) (Expr, bool) {
+	f, ok := e.Base.(*Func)
+	if !ok {
+		return nil, false
+	}
+	n, ok := isInt(e.Exp)
+	if !ok || !n.IsInt64() {
+		return nil, false
+	}
+	a, ok := in.linear(f.Arg)
+	if !ok {
+		return nil, false
+	}
+
+	var result Expr
+	switch {
+	case n.Int64() == 2 && f.Name == "sec", n.Int64() == -2 && f.Name == "cos":
+		result = &Func{Name: "tan", Arg: f.Arg}
+	case n.Int64() == 2 && f.Name == "csc", n.Int64() == -2 && f.Name == "sin":
+		result = Neg(&Func{Name: "cot", Arg: f.Arg})
+	default:
+		return nil, false
+	}
+	return Simplify(&Mul{Factors: []Expr{result, NewNum(new(big.Rat).Inv(a))}}), true
+}
+
+// halfAngle rewrites sin² and cos² of a linear argument u with
+// sin²(u) = (1 - cos(2u))/2 and cos²(u) = (1 + cos(2u))/2 and integrates the
+// result.
+func (in *integrator) halfAngle(e Expr) (Expr, bool) {
+	p, ok := e.(*Pow)
+	if !ok {
+		return nil, false
+	}
+	f, ok := p.Base.(*Func)
+	if !ok || (f.Name != "sin" && f.Name != "cos") {
+		return nil, false
+	}
+	if n, ok := isInt(p.Exp); !ok || n.Cmp(big.NewInt(2)) != 0 {
+		return nil, false
+	}
+	if _, ok := in.linear(f.Arg); !ok {
+		return nil, false
+	}
+
+	cos2u := &Func{Name: "cos", Arg: Simplify(&Mul{Factors: []Expr{NewInt(2), f.Arg}})}
+	sign := int64(1)
+	if f.Name == "sin" {
+		sign = -1
+	}
+	rewritten := Expand(Div(&Add{Terms: []Expr{NewInt(1), &Mul{Factors: []Expr{NewInt(sign), cos2u}}}}, NewInt(2)))
+	in.step(RuleHalfAngle, in.integral(e)+" = "+in.integral(rewritten))
+	result, err := in.integrate(rewritten)
+	if err != nil {
+		return nil, false
+	}
+	return result, true
+}
+
+// cyclicParts handles e^(a·x + b) times sin or cos of c·x + d. Integrating
+// by parts twice brings back the original integral I, so
+// I = e^(ax+b)·(a·f(cx+d) ± c·g(cx+d))/(a² + c²), where g is the other
+// function.
+func (in *integrator) cyclicParts(e Expr) (Expr, bool) {
+	m, ok := e.(*Mul)
+	if !ok || len(m.Factors) != 2 {
+		return nil, false
+	}
+	var exp *Pow
+	var trig *Func
+	for _, f := range m.Factors {
+		switch f := f.(type) {
+		case *Pow:
+			if s, ok := f.Base.(*Sym); ok && s.Name == "e" {
+				exp = f
+			}
+		case *Func:
+			if f.Name == "sin" || f.Name == "cos" {
+				trig = f
+			}
+		}
+	}
+	if exp == nil || trig == nil {
+		return nil, false
+	}
+	a, ok := in.linear(exp.Exp)
+	if !ok {
+		return nil, false
+	}
+	c, ok := in.linear(trig.Arg)
+	if !ok {
+		return nil, false
+	}
+
+	// For sin the second integral enters with a minus sign, for cos with a plus
+	other, sign := "cos", big.NewRat(-1, 1)
+	if trig.Name == "cos" {
+		other, sign = "sin", big.NewRat(1, 1)
+	}
+	g := &Func{Name: other, Arg: trig.Arg}
+	num := func(r *big.Rat) Expr { return NewNum(r) }
+	inv := func(r *big.Rat) *big.Rat { return new(big.Rat).Inv(r) }
+	mul := func(x, y *big.Rat) *big.Rat { return new(big.Rat).Mul(x, y) }
+
+	first := Simplify(&Mul{Factors: []Expr{num(inv(a)), exp, trig}})
+	second := Simplify(&Mul{Factors: []Expr{num(mul(sign, mul(c, inv(mul(a, a))))), exp, g}})
+	cycle := mul(c, mul(c, inv(mul(a, a))))
+	i := `I = ` + in.integral(e)
+	in.step(RuleByParts, i+" = "+first.Latex()+signedLatex(mul(sign, mul(c, inv(a))))+in.integral(Simplify(&Mul{Factors: []Expr{exp, g}})))
+	in.step(RuleByParts, `I = `+Simplify(&Add{Terms: []Expr{first, second}}).Latex()+signedLatex(new(big.Rat).Neg(cycle))+`I`)
+
+	denominator := new(big.Rat).Add(mul(a, a), mul(c, c))
+	result := Simplify(&Mul{Factors: []Expr{
+		num(inv(denominator)), exp,
+		&Add{Terms: []Expr{&Mul{Factors: []Expr{num(a), trig}}, &Mul{Factors: []Expr{num(mul(sign, c)), g}}}},
+	}})
+	in.step(RuleSolveForIntegral, i+" = "+result.Latex())
+	return result, true
+}
+
+// signedLatex renders r as a coefficient joined to a preceding term, with
+// a coefficient of one left out.
+func signedLatex(r *big.Rat) string {
+	sign, abs := " + ", new(big.Rat).Abs(r)
+	if r.Sign() < 0 {
+		sign = " - "
+	}
+	if abs.Cmp(one) == 0 {
+		return sign
+	}
+	return sign + latexRat(abs) + " "
+}
+
+// fraction splits e into polynomial numerator and denominator, if it is a
+// rational function with a non-constant denominator.
+func (in *integrator) fraction(e Expr) (*Poly, *Poly, bool) {
+	var num, den []Expr
+	switch e := e.(type) {
+	case *Mul:
+		num, den = numeratorDenominator(e)
+	case *Pow:
+		num, den = numeratorDenominator(&Mul{Factors: []Expr{e}})
+	default:
+		return nil, nil, false
+	}
+	if len(den) == 0 {
+		return nil, nil, false
+	}
+	numerator, ok := ToPoly(simplifyMul(num), in.v)
+	if !ok {
+		return nil, nil, false
+	}
+	denominator, ok := ToPoly(simplifyMul(den), in.v)
+	if !ok || denominator.Degree() < 1 {
+		return nil, nil, false
+	}
+	return numerator, denominator, true
+}
+
+// rational integrates polynomial quotients: polynomial division first, then
+// partial fractions over rational linear factors and at most one quadratic.
+func (in *integrator) rational(e Expr) (Expr, bool) {
+	num, den, ok := in.fraction(e)
+	if !ok {
+		return nil, false
+	}
+	quotient, rem := num.DivMod(den)
+	fractions, quadratic, ok := partialFractions(rem, den)
+	if !ok {
+		return nil, false
+	}
+
+	var parts []Expr
+	if quotient.Degree() >= 0 {
+		parts = append(parts, quotient.Expr())
+	}
+	parts = append(parts, fractions...)
+	if quadratic != nil {
+		parts = append(parts, quadratic.expr())
+	}
+	if len(parts) == 1 && quadratic != nil {
+		return in.quadratic(quadratic)
+	}
+
+	rule := RulePartialFractions
+	if quotient.Degree() >= 0 && len(parts) == 2 {
+		rule = RulePolynomialDivision
+	}
+	integrals := make([]string, len(parts))
+	for i, p := range parts {
+		integrals[i] = in.integral(p)
+	}
+	in.step(rule, in.integral(e)+" = "+strings.Join(integrals, " + "))
+
+	var results []Expr
+	for _, p := range parts {
+		if quadratic != nil && p == parts[len(parts)-1] {
+			r, ok := in.quadratic(quadratic)
+			if !ok {
+				return nil, false
+			}
+			results = append(results, r)
+			continue
+		}
+		r, err := in.integrate(p)
+		if err != nil {
+			return nil, false
+		}
+		results = append(results, r)
+	}
+	return Simplify(&Add{Terms: results}), true
+}
+
+// quadraticFraction is (b·x + c) / (x² + p·x + q) with x² + p·x + q
+// irreducible over the rationals.
+type quadraticFraction struct {
+	v       string
+	b, c    *big.Rat
+	p, q    *big.Rat
+	scaling *big.Rat // 1/lead of the original denominator
+}
+
+func (f *quadraticFraction) denominator() Expr {
+	return (&Poly{Var: f.v, Coeffs: []*big.Rat{f.q, f.p, big.NewRat(1, 1)}}).Expr()
+}
+
+func (f *quadraticFraction) expr() Expr {
+	numerator := &Poly{Var: f.v, Coeffs: []*big.Rat{
+		new(big.Rat).Mul(f.c, f.scaling),
+		new(big.Rat).Mul(f.b, f.scaling),
+	}}
+	numerator.trim()
+	// Keep the numerator integral, e.g. -(x - 1)/(3(x^2 + x + 1))
+	integral, content := numerator.integerForm()
+	return simplifyMul([]Expr{NewNum(content), integral.Expr(), simplifyPow(f.denominator(), NewInt(-1))})
+}
+
+// partialFractions decomposes rem/den (deg rem < deg den) into terms
+// A/(x - r)^k and at most one irreducible quadratic fraction. It fails
+// when den has irreducible factors of higher degree.
+func partialFractions(rem, den *Poly) ([]Expr, *quadraticFraction, bool) {
+	if rem.Degree() < 0 {
+		return nil, nil, true
+	}
+	lead := den.Lead()
+	monic := &Poly{Var: den.Var, Coeffs: make([]*big.Rat, len(den.Coeffs))}
+	for i, c := range den.Coeffs {
+		monic.Coeffs[i] = new(big.Rat).Quo(c, lead)
+	}
+	roots, rest := monic.RationalRoots()
+	if rest.Degree() > 2 {
+		return nil, nil, false
+	}
+
+	// Distinct roots with their multiplicities, in ascending order
+	var distinct []*big.Rat
+	multiplicity := map[string]int{}
+	for _, r := range roots {
+		if multiplicity[r.String()] == 0 {
+			distinct = append(distinct, r)
+		}
+		multiplicity[r.String()]++
+	}
+	sort.Slice(distinct, func(i, j int) bool { return distinct[i].Cmp(distinct[j]) < 0 })
+
+	// Each unknown multiplies monic divided by its own denominator
+	type unknown struct {
+		root  *big.Rat
+		power int
+		basis *Poly
+	}
+	var unknowns []unknown
+	for _, r := range distinct {
+		basis := monic
+		for k := 1; k <= multiplicity[r.String()]; k++ {
+			basis = basis.divideLinear(r)
+			unknowns = append(unknowns, unknown{root: r, power: k, basis: basis})
+		}
+	}
+	if rest.Degree() == 2 {
+		basis, _ := monic.DivMod(rest)
+		shifted := &Poly{Var: den.Var, Coeffs: append([]*big.Rat{new(big.Rat)}, basis.Coeffs...)}
+		unknowns = append(unknowns, unknown{basis: shifted}, unknown{basis: basis})
+	}
+
+	n := monic.Degree()
+	if len(unknowns) != n {
+		return nil, nil, false
+	}
+	a := make([][]*big.Rat, n)
+	b := make([]*big.Rat, n)
+	for i := 0; i < n; i++ {
+		a[i] = make([]*big.Rat, n)
+		for j, u := range unknowns {
+			a[i][j] = u.basis.Coeff(i)
+		}
+		b[i] = new(big.Rat).Quo(rem.Coeff(i), lead)
+	}
+	solution, ok := solveRational(a, b)
+	if !ok {
+		return nil, nil, false
+	}
+
+	x := NewSym(den.Var)
+	var terms []Expr
+	for j, u := range unknowns {
+		if u.root == nil || solution[j].Sign() == 0 {
+			continue
+		}
+		linear := simplifyAdd([]Expr{x, NewNum(new(big.Rat).Neg(u.root))})
+		terms = append(terms, simplifyMul([]Expr{NewNum(solution[j]), simplifyPow(linear, NewInt(int64(-u.power)))}))
+	}
+
+	var quadratic *quadraticFraction
+	if rest.Degree() == 2 {
+		bq, cq := solution[n-2], solution[n-1]
+		if bq.Sign() != 0 || cq.Sign() != 0 {
+			quadratic = &quadraticFraction{
+				v: den.Var, b: bq, c: cq,
+				p: rest.Coeff(1), q: rest.Coeff(0),
+				scaling: big.NewRat(1, 1),
+			}
+		}
+	}
+	return terms, quadratic, true
+}
+
+// quadratic integrates (b·x + c)/(x² + p·x + q) by completing the square:
+// a logarithm for the part proportional to 2x + p, and an arctangent (or a
+// logarithm when the roots are irrational) for the rest.
+func (in *integrator) quadratic(f *quadraticFraction) (Expr, bool) {
+	x := NewSym(f.v)
+	b := new(big.Rat).Mul(f.b, f.scaling)
+	c := new(big.Rat).Mul(f.c, f.scaling)
+	den := f.denominator()
+
+	// b·x + c = (b/2)(2x + p) + (c - b·p/2)
+	half := new(big.Rat).Quo(b, big.NewRat(2, 1))
+	rest := new(big.Rat).Sub(c, new(big.Rat).Mul(half, f.p))
+	twoXPlusP := simplifyAdd([]Expr{simplifyMul([]Expr{NewInt(2), x}), NewNum(f.p)})
+
+	var terms []Expr
+	if half.Sign() != 0 {
+		terms = append(terms, &Mul{Factors: []Expr{NewNum(half), &Func{Name: "ln", Arg: &Func{Name: "abs", Arg: den}}}})
+	}
+	if rest.Sign() != 0 {
+		// Δ = p² - 4q
+		disc := new(big.Rat).Sub(new(big.Rat).Mul(f.p, f.p), new(big.Rat).Mul(big.NewRat(4, 1), f.q))
+		switch disc.Sign() {
+		case -1:
+			root := Simplify(Sqrt(NewNum(new(big.Rat).Neg(disc))))
+			terms = append(terms, &Mul{Factors: []Expr{
+				NewNum(new(big.Rat).Mul(rest, big.NewRat(2, 1))),
+				&Pow{Base: root, Exp: NewInt(-1)},
+				&Func{Name: "atan", Arg: &Mul{Factors: []Expr{twoXPlusP, &Pow{Base: root, Exp: NewInt(-1)}}}},
+			}})
+		case 1:
+			root := Simplify(Sqrt(NewNum(disc)))
+			ratio := &Mul{Factors: []Expr{
+				&Add{Terms: []Expr{twoXPlusP, Neg(root)}},
+				&Pow{Base: &Add{Terms: []Expr{twoXPlusP, root}}, Exp: NewInt(-1)},
+			}}
+			terms = append(terms, &Mul{Factors: []Expr{
+				NewNum(rest),
+				&Pow{Base: root, Exp: NewInt(-1)},
+				&Func{Name: "ln", Arg: &Func{Name: "abs", Arg: ratio}},
+			}})
+		default:
+			return nil, false
+		}
+	}
+
+	result := Simplify(&Add{Terms: terms})
+	in.step(RuleQuadratic, in.integral(f.expr())+" = "+result.Latex())
+	return result, true
+}
+
+// expand multiplies out products and powers of sums, e.g. x(x + 1)^2.
+func (in *integrator) expand(e Expr) (Expr, bool) {
+	expanded := Expand(e)
+	if _, ok := expanded.(*Add); !ok || expanded.String() == e.String() {
+		return nil, false
+	}
+	in.step(RuleExpand, in.integral(e)+" = "+in.integral(expanded))
+	result, err := in.integrate(expanded)
+	if err != nil {
+		return nil, false
+	}
+	return result, true
+}
+
+// substitution tries u = g(x) for each inner expression g of e and accepts
+// the first for which e / g'(x) can be written in u alone.
+func (in *integrator) substitution(e Expr) (Expr, bool) {
+	name := "u"
+	for _, candidate := range []string{"u", "t", "w", "z"} {
+		if !Contains(e, candidate) && candidate != in.v {
+			name = candidate
+			break
+		}
+	}
+	u := NewSym(name)
+
+	for _, inner := range innerExpressions(e, in.v) {
+		if in.cancelled() != nil {
+			return nil, false
+		}
+		du, err := Diff(inner, in.v)
+		if err != nil || isZero(du) {
+			continue
+		}
+		ratio := Simplify(&Mul{Factors: []Expr{e, &Pow{Base: du, Exp: NewInt(-1)}}})
+		replaced := Simplify(replaceExpr(ratio, inner, u))
+		if Contains(replaced, in.v) {
+			continue
+		}
+
+		mark := in.mark()
+		in.step(RuleSubstitution, u.Latex()+" = "+inner.Latex()+`,\quad d`+u.Latex()+" = "+factorLatex(du)+` \, d`+in.x().Latex())
+		in.step(RuleSubstitution, in.integral(e)+` = \int `+factorLatex(replaced)+` \, d`+u.Latex())
+		sub := &integrator{v: name, s: in.s, depth: in.depth}
+		antiderivative, err := sub.integrate(replaced)
+		if err != nil {
+			in.reset(mark)
+			continue
+		}
+		result := Simplify(Substitute(antiderivative, name, inner))
+		in.step(RuleSubstitution, in.integral(e)+" = "+result.Latex())
+		return result, true
+	}
+	return nil, false
+}
+
+// innerExpressions lists candidate substitutions: function arguments,
+// bases and exponents of powers, and factors of products.
+func innerExpressions(e Expr, v string) []Expr {
+	var out []Expr
+	seen := map[string]bool{}
+	add := func(inner Expr) {
+		if _, isSym := inner.(*Sym); isSym || !Contains(inner, v) || seen[inner.String()] {
+			return
+		}
+		seen[inner.String()] = true
+		out = append(out, inner)
+	}
+
+	// Post-order, so inner expressions such as x^2 in x*e^(x^2) come first
+	var walk func(e Expr)
+	walk = func(e Expr) {
+		switch e := e.(type) {
+		case *Add:
+			for _, t := range e.Terms {
+				walk(t)
+			}
+		case *Mul:
+			for _, f := range e.Factors {
+				walk(f)
+			}
+			for _, f := range e.Factors {
+				add(f)
+			}
+		case *Pow:
+			walk(e.Base)
+			walk(e.Exp)
+			add(e.Base)
+			add(e.Exp)
+		case *Func:
+			walk(e.Arg)
+			add(e.Arg)
+		}
+	}
+	walk(e)
+	return out
+}
+
+// replaceExpr replaces every subexpression structurally equal to target.
+func replaceExpr(e, target, with Expr) Expr {
+	if Equal(e, target) {
+		return with
+	}
+	switch e := e.(type) {
+	case *Add:
+		terms := make([]Expr, len(e.Terms))
+		for i, t := range e.Terms {
+			terms[i] = replaceExpr(t, target, with)
+		}
+		return &Add{Terms: terms}
+	case *Mul:
+		factors := make([]Expr, len(e.Factors))
+		for i, f := range e.Factors {
+			factors[i] = replaceExpr(f, target, with)
+		}
+		return &Mul{Factors: factors}
+	case *Pow:
+		return &Pow{Base: replaceExpr(e.Base, target, with), Exp: replaceExpr(e.Exp, target, with)}
+	case *Func:
+		return &Func{Name: e.Name, Arg: replaceExpr(e.Arg, target, with)}
+	}
+	return e
+}
+
+// byParts integrates a product as u·v - ∫ v du, choosing u by the LIATE
+// order (logarithmic, inverse trigonometric, algebraic, trigonometric,
+// exponential).
+func (in *integrator) byParts(e Expr) (Expr, bool) {
+	m, ok := e.(*Mul)
+	if !ok {
+		return nil, false
+	}
+
+	best, bestRank := -1, len(liateOrder)
+	for i, f := range m.Factors {
+		if rank := liateRank(f, in.v); rank < bestRank {
+			best, bestRank = i, rank
+		}
+	}
+	if best < 0 {
+		return nil, false
+	}
+	u := m.Factors[best]
+	var rest []Expr
+	for i, f := range m.Factors {
+		if i != best {
+			rest = append(rest, f)
+		}
+	}
+	dv := simplifyMul(rest)
+
+	du, err := Diff(u, in.v)
+	if err != nil {
+		return nil, false
+	}
+	v, err := (&integrator{v: in.v, depth: in.depth}).integrate(dv)
+	if err != nil {
+		return nil, false
+	}
+	v = tidy(v)
+
+	dx := ` \, d` + in.x().Latex()
+	in.step(RuleByParts, `u = `+u.Latex()+`,\quad dv = `+factorLatex(dv)+dx+`,\quad du = `+factorLatex(du)+dx+`,\quad v = `+v.Latex())
+	uv := Simplify(&Mul{Factors: []Expr{u, v}})
+	vdu := Simplify(&Mul{Factors: []Expr{v, du}})
+	in.step(RuleByParts, in.integral(e)+" = "+uv.Latex()+" - "+in.integral(vdu))
+
+	remaining, err := in.integrate(vdu)
+	if err != nil {
+		return nil, false
+	}
+	return Simplify(Sub(uv, remaining)), true
+}
+
+var liateOrder = []string{"logarithmic", "inverse trigonometric", "algebraic", "trigonometric", "exponential"}
+
+// liateRank returns f's position in liateOrder, or len(liateOrder) when f
+// is unsuitable as u.
+func liateRank(f Expr, v string) int {
+	switch f := f.(type) {
+	case *Func:
+		switch f.Name {
+		case "ln", "log":
+			return 0
+		case "asin", "acos", "atan":
+			return 1
+		case "sin", "cos", "tan", "sec", "csc", "cot", "sinh", "cosh", "tanh":
+			return 3
+		}
+	case *Pow:
+		if !Contains(f.Base, v) {
+			return 4
+		}
+		if _, ok := f.Base.(*Func); ok {
+			return 3
+		}
+	}
+	if p, ok := ToPoly(f, v); ok && p.Degree() > 0 {
+		return 2
+	}
+	return len(liateOrder)
+}
+
+// unknownOf returns variable, or the single free variable of e when it is
+// empty; command is quoted in the error.
+func unknownOf(e Expr, variable, command string) (string, error) {
+	if variable != "" {
+		return variable, nil
+	}
+	vars := FreeSymbols(e)
+	switch len(vars) {
+	case 0:
+		return "x", nil
+	case 1:
+		return vars[0], nil
+	}
+	return "", fmt.Errorf("%w: several variables (%s), name one, e.g. %s(f, %s)", ErrUnsupported, strings.Join(vars, ", "), command, vars[0])
+}
@@ -0,0 +1,36 @@
+package mathengine
+
+import (
+	"testing"
+
+	"maths-solution-backend/models"
+)
+
+func TestIntegrate(t *testing.T) {
+	tests := []struct {
+		input string
+		final string
+	}{
+		{"integrate x^2", "x^3/3 + C"},
+		{"integrate sec(x)^2", "tan(x) + C"},
+		{"integrate sin(x)^2", "x/2 - sin(2*x)/4 + C"},
+		{"integrate cos(x)^2", "x/2 + sin(2*x)/4 + C"},
+		{"integrate e^x*sin(x)", "(e^x*(-cos(x) + sin(x)))/2 + C"},
+		{"integrate e^x*cos(x)", "(e^x*(cos(x) + sin(x)))/2 + C"},
+		{"integrate e^(2x)*sin(3x)", "(e^(2*x)*(-3*cos(3*x) + 2*sin(3*x)))/13 + C"},
+	}
+	en := NewEngine()
+	for _, tt := range tests {
+		result, err := en.Solve(tt.input)
+		if err != nil {
+			t.Errorf("Solve(%q): %v", tt.input, err)
+			continue
+		}
+		if result.Final != tt.final {
+			t.Errorf("Solve(%q) = %s, want %s", tt.input, result.Final, tt.final)
+		}
+		if v := Verify(tt.input, result.Final); v.Status != models.VerificationVerified {
+			t.Errorf("Verify(%q, %q) = %s: %s", tt.input, result.Final, v.Status, v.Detail)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package mathengine
+
+import "math/big"
+
+// solveRational solves the square system a·x = b exactly by Gauss-Jordan
+// elimination. It reports false when the system is singular.
+func solveRational(a [][]*big.Rat, b []*big.Rat) ([]*big.Rat, bool) {
+	n := len(b)
+	m := make([][]*big.Rat, n)
+	for i := range a {
+		m[i] = make([]*big.Rat, n+1)
+		for j := range a[i] {
+			m[i][j] = new(big.Rat).Set(a[i][j])
+		}
+		m[i][n] = new(big.Rat).Set(b[i])
+	}
+
+	for col := 0; col < n; col++ {
+		pivot := -1
+		for row := col; row < n; row++ {
+			if m[row][col].Sign() != 0 {
+				pivot = row
+				break
+			}
+		}
+		if pivot < 0 {
+			return nil, false
+		}
+		m[col], m[pivot] = m[pivot], m[col]
+
+		inv := new(big.Rat).Inv(m[col][col])
+		for j := col; j <= n; j++ {
+			m[col][j].Mul(m[col][j], inv)
+		}
+		for row := 0; row < n; row++ {
+			if row == col || m[row][col].Sign() == 0 {
+				continue
+			}
+			factor := new(big.Rat).Set(m[row][col])
+			for j := col; j <= n; j++ {
+				m[row][j].Sub(m[row][j], new(big.Rat).Mul(factor, m[col][j]))
+			}
+		}
+	}
+
+	x := make([]*big.Rat, n)
+	for i := range x {
+		x[i] = m[i][n]
+	}
+	return x, true
+}
@@ -0,0 +1,316 @@
+package mathengine
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// maxMatrixSize bounds the rows and columns accepted for elimination.
+const maxMatrixSize = 10
+
+// maxEigenSize bounds the matrices whose eigenvalues are computed exactly.
+const maxEigenSize = 4
+
+// Matrix is a dense matrix of exact rationals, indexed [row][column].
+type Matrix [][]*big.Rat
+
+// NewMatrix checks that rows is a non-empty rectangular matrix within the
+// size limits and returns a copy of it.
+func NewMatrix(rows [][]*big.Rat) (Matrix, error) {
+	if len(rows) == 0 || len(rows[0]) == 0 {
+		return nil, fmt.Errorf("matrix is empty")
+	}
+	if len(rows) > maxMatrixSize || len(rows[0]) > maxMatrixSize {
+		return nil, fmt.Errorf("%w: matrices larger than %dx%d", ErrUnsupported, maxMatrixSize, maxMatrixSize)
+	}
+	m := make(Matrix, len(rows))
+	for i, row := range rows {
+		if len(row) != len(rows[0]) {
+			return nil, fmt.Errorf("row %d has %d entries, expected %d", i+1, len(row), len(rows[0]))
+		}
+		m[i] = make([]*big.Rat, len(row))
+		for j, v := range row {
+			m[i][j] = new(big.Rat).Set(v)
+		}
+	}
+	return m, nil
+}
+
+// ParseEntry reads a matrix entry such as "3", "-2/3", "0.25", "2.5E-3" or
+// "1/2 + 1/3"; it must simplify to a rational number. Entries always go
+// through the parser, which bounds exponents, never straight to big.Rat.
+func ParseEntry(s string) (*big.Rat, error) {
+	e, err := Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	r, ok := isNum(Simplify(e))
+	if !ok {
+		return nil, fmt.Errorf("matrix entry %q is not a rational number", s)
+	}
+	return r, nil
+}
+
+func (m Matrix) Rows() int { return len(m) }
+
+func (m Matrix) Cols() int { return len(m[0]) }
+
+func (m Matrix) IsSquare() bool { return m.Rows() == m.Cols() }
+
+func (m Matrix) clone() Matrix {
+	c, _ := NewMatrix(m)
+	return c
+}
+
+func identity(n int) Matrix {
+	m := make(Matrix, n)
+	for i := range m {
+		m[i] = make([]*big.Rat, n)
+		for j := range m[i] {
+			m[i][j] = new(big.Rat)
+		}
+		m[i][i].SetInt64(1)
+	}
+	return m
+}
+
+// Strings renders every entry as an exact fraction.
+func (m Matrix) Strings() [][]string {
+	out := make([][]string, len(m))
+	for i, row := range m {
+		out[i] = make([]string, len(row))
+		for j, v := range row {
+			out[i][j] = v.RatString()
+		}
+	}
+	return out
+}
+
+// String renders m as nested lists, e.g. [[1, 2], [3, 4]].
+func (m Matrix) String() string {
+	rows := make([]string, len(m))
+	for i, row := range m.Strings() {
+		rows[i] = "[" + strings.Join(row, ", ") + "]"
+	}
+	return "[" + strings.Join(rows, ", ") + "]"
+}
+
+// Latex renders m as a bracketed matrix; the last augmented columns are
+// separated by a vertical bar.
+func (m Matrix) Latex(augmented int) string {
+	spec := strings.Repeat("r", m.Cols()-augmented)
+	if augmented > 0 {
+		spec += "|" + strings.Repeat("r", augmented)
+	}
+	rows := make([]string, len(m))
+	for i, row := range m {
+		cells := make([]string, len(row))
+		for j, v := range row {
+			cells[j] = latexRat(v)
+		}
+		rows[i] = strings.Join(cells, " & ")
+	}
+	return `\left[\begin{array}{` + spec + `}` + strings.Join(rows, ` \\ `) + `\end{array}\right]`
+}
+
+// rowName renders R_i for 0-based row i.
+func rowName(i int) string {
+	return fmt.Sprintf("R_{%d}", i+1)
+}
+
+// rowReduce brings m to reduced row echelon form by Gauss-Jordan
+// elimination, recording each row operation with the matrix it produces.
+// Only the first m.Cols()-augmented columns are used as pivots. It returns
+// the reduced matrix, the pivot columns and the determinant factor: the
+// product of the pivots and the sign of the row swaps.
+func (s *session) rowReduce(m Matrix, augmented int) (Matrix, []int, *big.Rat) {
+	m = m.clone()
+	det := big.NewRat(1, 1)
+	var pivots []int
+	row := 0
+	for col := 0; col < m.Cols()-augmented && row < m.Rows(); col++ {
+		pivot := -1
+		for r := row; r < m.Rows(); r++ {
+			if m[r][col].Sign() != 0 {
+				pivot = r
+				break
+			}
+		}
+		if pivot < 0 {
+			det.SetInt64(0)
+			continue
+		}
+
+		if pivot != row {
+			m[pivot], m[row] = m[row], m[pivot]
+			det.Neg(det)
+			s.ruleStep(RuleSwapRows, rowName(row)+` \leftrightarrow `+rowName(pivot)+`\quad `+m.Latex(augmented))
+		}
+
+		if p := new(big.Rat).Set(m[row][col]); p.Cmp(one) != 0 {
+			det.Mul(det, p)
+			inv := new(big.Rat).Inv(p)
+			for j := range m[row] {
+				m[row][j].Mul(m[row][j], inv)
+			}
+			s.ruleStep(RuleScaleRow, rowName(row)+` \to `+latexCoefficient(inv)+rowName(row)+`\quad `+m.Latex(augmented))
+		}
+
+		var ops []string
+		for r := 0; r < m.Rows(); r++ {
+			if r == row || m[r][col].Sign() == 0 {
+				continue
+			}
+			factor := new(big.Rat).Set(m[r][col])
+			for j := range m[r] {
+				m[r][j].Sub(m[r][j], new(big.Rat).Mul(factor, m[row][j]))
+			}
+			sign := " - "
+			if factor.Sign() < 0 {
+				sign = " + "
+			}
+			ops = append(ops, rowName(r)+` \to `+rowName(r)+sign+latexCoefficient(new(big.Rat).Abs(factor))+rowName(row))
+		}
+		if len(ops) > 0 {
+			s.ruleStep(RuleEliminate, strings.Join(ops, `,\; `)+`\quad `+m.Latex(augmented))
+		}
+
+		pivots = append(pivots, col)
+		row++
+	}
+	if len(pivots) < m.Rows() {
+		det.SetInt64(0)
+	}
+	return m, pivots, det
+}
+
+// latexCoefficient renders a row multiplier, omitting a unit factor.
+func latexCoefficient(r *big.Rat) string {
+	switch {
+	case r.Cmp(one) == 0:
+		return ""
+	case r.Cmp(big.NewRat(-1, 1)) == 0:
+		return "-"
+	}
+	if r.IsInt() {
+		return r.RatString()
+	}
+	return latexRat(r) + " "
+}
+
+// Rank returns the number of pivots of m.
+func (m Matrix) Rank() int {
+	_, pivots, _ := (&session{}).rowReduce(m, 0)
+	return len(pivots)
+}
+
+// Determinant returns det(m) for square m.
+func (m Matrix) Determinant() *big.Rat {
+	_, _, det := (&session{}).rowReduce(m, 0)
+	return det
+}
+
+// Inverse returns m⁻¹, or false when m is singular or not square.
+func (m Matrix) Inverse() (Matrix, bool) {
+	if !m.IsSquare() {
+		return nil, false
+	}
+	n := m.Rows()
+	augmented := make(Matrix, n)
+	id := identity(n)
+	for i := range m {
+		augmented[i] = append(append([]*big.Rat{}, m[i]...), id[i]...)
+	}
+	reduced, pivots, _ := (&session{}).rowReduce(augmented, n)
+	if len(pivots) < n {
+		return nil, false
+	}
+	inverse := make(Matrix, n)
+	for i := range reduced {
+		inverse[i] = reduced[i][n:]
+	}
+	return inverse, true
+}
+
+// CharacteristicPolynomial returns det(λI - m) in the variable "lambda",
+// computed with the Faddeev-LeVerrier recurrence.
+func (m Matrix) CharacteristicPolynomial() *Poly {
+	n := m.Rows()
+	coeffs := make([]*big.Rat, n+1)
+	coeffs[n] = big.NewRat(1, 1)
+
+	// M_k = A·M_{k-1} + c_{n-k+1}·I and c_{n-k} = -tr(A·M_k)/k
+	prev := make(Matrix, n)
+	for i := range prev {
+		prev[i] = make([]*big.Rat, n)
+		for j := range prev[i] {
+			prev[i][j] = new(big.Rat)
+		}
+	}
+	for k := 1; k <= n; k++ {
+		next := m.mul(prev)
+		for i := 0; i < n; i++ {
+			next[i][i].Add(next[i][i], coeffs[n-k+1])
+		}
+		product := m.mul(next)
+		trace := new(big.Rat)
+		for i := 0; i < n; i++ {
+			trace.Add(trace, product[i][i])
+		}
+		coeffs[n-k] = trace.Neg(trace).Quo(trace, big.NewRat(int64(k), 1))
+		prev = next
+	}
+
+	p := &Poly{Var: "lambda", Coeffs: coeffs}
+	p.trim()
+	return p
+}
+
+func (m Matrix) mul(b Matrix) Matrix {
+	out := make(Matrix, m.Rows())
+	for i := range out {
+		out[i] = make([]*big.Rat, b.Cols())
+		for j := range out[i] {
+			sum := new(big.Rat)
+			for k := 0; k < m.Cols(); k++ {
+				sum.Add(sum, new(big.Rat).Mul(m[i][k], b[k][j]))
+			}
+			out[i][j] = sum
+		}
+	}
+	return out
+}
+
+// Eigenvalues returns the exact eigenvalues of a small square matrix with
+// multiplicity: rational roots of the characteristic polynomial plus the
+// roots of a remaining quadratic, complex ones written with i. unresolved
+// is the factor of degree three or more left without exact roots.
+func (m Matrix) Eigenvalues() (values []Expr, unresolved *Poly) {
+	p := m.CharacteristicPolynomial()
+	roots, rest := p.RationalRoots()
+	for _, r := range roots {
+		values = append(values, NewNum(r))
+	}
+
+	switch rest.Degree() {
+	case 2:
+		a, b, c := rest.Coeff(2), rest.Coeff(1), rest.Coeff(0)
+		disc := new(big.Rat).Sub(new(big.Rat).Mul(b, b), new(big.Rat).Mul(big.NewRat(4, 1), new(big.Rat).Mul(a, c)))
+		twoA := new(big.Rat).Mul(big.NewRat(2, 1), a)
+		re := NewNum(new(big.Rat).Quo(new(big.Rat).Neg(b), twoA))
+		var im Expr
+		if disc.Sign() < 0 {
+			im = simplifyMul([]Expr{NewNum(new(big.Rat).Inv(twoA)), Simplify(Sqrt(NewNum(new(big.Rat).Neg(disc)))), NewSym("i")})
+		} else {
+			im = simplifyMul([]Expr{NewNum(new(big.Rat).Inv(twoA)), Simplify(Sqrt(NewNum(disc)))})
+		}
+		for _, sign := range []int64{-1, 1} {
+			values = append(values, simplifyAdd([]Expr{re, simplifyMul([]Expr{NewInt(sign), im})}))
+		}
+	case -1, 0:
+	default:
+		unresolved = rest
+	}
+	return values, unresolved
+}
@@ -0,0 +1,32 @@
+package mathengine
+
+import "testing"
+
+func TestParseEntry(t *testing.T) {
+	tests := []struct {
+		entry string
+		want  string
+	}{
+		{"3", "3/1"},
+		{"-2/3", "-2/3"},
+		{"0.25", "1/4"},
+		{"2.5E-3", "1/400"},
+		{"-1e2", "-100/1"},
+		{"1/2 + 1/3", "5/6"},
+	}
+	for _, tt := range tests {
+		got, err := ParseEntry(tt.entry)
+		if err != nil {
+			t.Errorf("ParseEntry(%q): %v", tt.entry, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("ParseEntry(%q) = %s, want %s", tt.entry, got, tt.want)
+		}
+	}
+	for _, entry := range []string{"x", "sqrt(2)", "1e999999999", "1/0"} {
+		if _, err := ParseEntry(entry); err == nil {
+			t.Errorf("ParseEntry(%q) succeeded, want an error", entry)
+		}
+	}
+}
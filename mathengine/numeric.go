@@ -0,0 +1,619 @@
+package mathengine
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Numbers are evaluated on math/big: rationals stay exact and everything
+// else is a big.Float carrying a configurable number of significant digits,
+// so results never show binary noise such as 0.30000000000000004.
+
+const (
+	// DefaultPrecision is the number of significant digits of decimal
+	// approximations when none is requested.
+	DefaultPrecision = 10
+	// MaxPrecision bounds the requested number of significant digits.
+	MaxPrecision = 100
+	// evalPrecision is the number of digits behind the float64 Eval.
+	evalPrecision = 30
+)
+
+// ErrUndefined is returned when an expression has no real value, such as
+// sqrt(-1) or 0/0.
+var ErrUndefined = errors.New("undefined value")
+
+// ClampPrecision maps a requested number of significant digits into
+// [1, MaxPrecision]; zero or less means DefaultPrecision.
+func ClampPrecision(digits int) int {
+	switch {
+	case digits <= 0:
+		return DefaultPrecision
+	case digits > MaxPrecision:
+		return MaxPrecision
+	}
+	return digits
+}
+
+// precisionBits is the mantissa size for digits significant digits, with
+// guard bits so the last printed digit is correctly rounded.
+func precisionBits(digits int) uint {
+	return uint(math.Ceil(float64(ClampPrecision(digits))*math.Log2(10))) + 32
+}
+
+// EvalBig evaluates e with the given variable bindings to digits
+// significant digits. Infinite results, e.g. 1/0, are returned as ±Inf.
+func EvalBig(e Expr, env map[string]*big.Float, digits int) (x *big.Float, err error) {
+	// big.Float panics where IEEE arithmetic would produce NaN
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(big.ErrNaN); !ok {
+				panic(r)
+			}
+			x, err = nil, ErrUndefined
+		}
+	}()
+	ev := &evaluator{prec: precisionBits(digits), env: env}
+	return ev.eval(e)
+}
+
+// Eval evaluates e as a float64 with the given variable bindings. It runs
+// on EvalBig and returns NaN where the expression is undefined.
+func Eval(e Expr, env map[string]float64) (float64, error) {
+	bigEnv := make(map[string]*big.Float, len(env))
+	for name, v := range env {
+		if !finite(v) {
+			return math.NaN(), nil
+		}
+		bigEnv[name] = big.NewFloat(v)
+	}
+	x, err := EvalBig(e, bigEnv, evalPrecision)
+	if errors.Is(err, ErrUndefined) {
+		return math.NaN(), nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	f, _ := x.Float64()
+	return f, nil
+}
+
+// maxLeadingZeros bounds the zeros FormatDecimal writes after the decimal
+// point before the first significant digit.
+const maxLeadingZeros = 1000
+
+// FormatDecimal renders x rounded to digits significant digits, positionally
+// and without trailing zeros: the parser would read scientific notation such
+// as 1e-100 with e as Euler's number. Integer digits are never rounded away,
+// since 12345678900 would read as an exact integer; a value with as many
+// integer digits as digits keeps them all and one decimal. Only magnitudes
+// beyond MaxPrecision integer digits or maxLeadingZeros leading zeros are
+// written in scientific notation.
+func FormatDecimal(x *big.Float, digits int) string {
+	if x.Sign() == 0 {
+		return "0"
+	}
+	digits = ClampPrecision(digits)
+	if whole := decimalExponent(x, MaxPrecision) + 1; whole >= digits && whole < MaxPrecision {
+		digits = whole + 1
+	}
+	exp := decimalExponent(x, digits)
+	if exp+1 >= digits && exp+1 < MaxPrecision {
+		// Rounding carried into a new integer digit, as 999.96 to 1000
+		digits = exp + 2
+		exp = decimalExponent(x, digits)
+	}
+	scientific := x.Text('e', digits-1)
+	if exp+1 >= MaxPrecision || exp < -maxLeadingZeros {
+		i := strings.IndexByte(scientific, 'e')
+		return strings.TrimRight(strings.TrimRight(scientific[:i], "0"), ".") + scientific[i:]
+	}
+
+	// The rounded value is exact in decimal, so FloatString does not round again
+	r, ok := new(big.Rat).SetString(scientific)
+	if !ok {
+		return scientific
+	}
+	places := digits - 1 - exp
+	if places < 0 {
+		places = 0
+	}
+	text := r.FloatString(places)
+	if strings.Contains(text, ".") {
+		text = strings.TrimRight(strings.TrimRight(text, "0"), ".")
+	}
+	return text
+}
+
+// decimalExponent is the power of ten of the leading digit of x rounded to
+// digits significant digits, e.g. 2 for 999.7 at three digits but 3 at two.
+func decimalExponent(x *big.Float, digits int) int {
+	text := x.Text('e', digits-1)
+	exp, _ := strconv.Atoi(text[strings.LastIndexByte(text, 'e')+1:])
+	return exp
+}
+
+// FormatRat renders r as a decimal rounded to digits significant digits.
+func FormatRat(r *big.Rat, digits int) string {
+	return FormatDecimal(new(big.Float).SetPrec(precisionBits(MaxPrecision)).SetRat(r), digits)
+}
+
+var decimalLiteral = regexp.MustCompile(`\d*\.\d+(?:[eE][+-]?\d+)?`)
+
+// FormatFinal rounds decimal literals in a final answer that carry more
+// than digits significant digits, so 0.30000000000000004 reads 0.3. Other
+// text, including shorter literals such as 0.50, is kept as written, and
+// integer digits are never rounded away, so 12345678901.5 stays as it is.
+func FormatFinal(final string, digits int) string {
+	digits = ClampPrecision(digits)
+	return decimalLiteral.ReplaceAllStringFunc(final, func(literal string) string {
+		mantissa := strings.TrimLeft(strings.Replace(strings.SplitN(strings.ToLower(literal), "e", 2)[0], ".", "", 1), "0")
+		if len(mantissa) <= digits {
+			return literal
+		}
+		x, _, err := big.ParseFloat(literal, 10, precisionBits(len(mantissa)), big.ToNearestEven)
+		if err != nil {
+			return literal
+		}
+		return FormatDecimal(x, digits)
+	})
+}
+
+// approx renders the numeric value of a constant expression at the
+// session's precision.
+func (s *session) approx(e Expr) (string, bool) {
+	x, err := EvalBig(e, nil, s.digits)
+	if err != nil || x.IsInf() {
+		return "", false
+	}
+	// FormatDecimal keeps every integer digit of large values; evaluate
+	// them precisely enough for that
+	if exp := decimalExponent(x, s.digits); exp+1 >= s.digits {
+		if x, err = EvalBig(e, nil, exp+2); err != nil || x.IsInf() {
+			return "", false
+		}
+	}
+	return FormatDecimal(x, s.digits), true
+}
+
+type evaluator struct {
+	prec uint
+	env  map[string]*big.Float
+}
+
+func newFloat(prec uint) *big.Float {
+	return new(big.Float).SetPrec(prec)
+}
+
+func (ev *evaluator) eval(e Expr) (*big.Float, error) {
+	switch e := e.(type) {
+	case *Num:
+		return newFloat(ev.prec).SetRat(e.Val), nil
+	case *Sym:
+		if v, ok := ev.env[e.Name]; ok {
+			return newFloat(ev.prec).Set(v), nil
+		}
+		switch e.Name {
+		case "pi":
+			return newFloat(ev.prec).Set(bigPi(ev.prec)), nil
+		case "e":
+			return bigExp(newFloat(ev.prec).SetInt64(1), ev.prec), nil
+		}
+		return nil, fmt.Errorf("unbound variable %q", e.Name)
+	case *Add:
+		sum := newFloat(ev.prec)
+		for _, t := range e.Terms {
+			v, err := ev.eval(t)
+			if err != nil {
+				return nil, err
+			}
+			sum.Add(sum, v)
+		}
+		return sum, nil
+	case *Mul:
+		product := newFloat(ev.prec).SetInt64(1)
+		for _, f := range e.Factors {
+			v, err := ev.eval(f)
+			if err != nil {
+				return nil, err
+			}
+			product.Mul(product, v)
+		}
+		return product, nil
+	case *Pow:
+		base, err := ev.eval(e.Base)
+		if err != nil {
+			return nil, err
+		}
+		if r, ok := isNum(e.Exp); ok {
+			return floatPowRat(base, r, ev.prec)
+		}
+		exp, err := ev.eval(e.Exp)
+		if err != nil {
+			return nil, err
+		}
+		if s, ok := e.Base.(*Sym); ok && s.Name == "e" {
+			return bigExp(exp, ev.prec), nil
+		}
+		return powFloat(base, exp, ev.prec)
+	case *Func:
+		arg, err := ev.eval(e.Arg)
+		if err != nil {
+			return nil, err
+		}
+		return bigFunc(e.Name, arg, ev.prec)
+	}
+	return nil, fmt.Errorf("cannot evaluate %T", e)
+}
+
+// floatPowRat raises x to an exact rational power. Odd roots of negative
+// numbers are real.
+func floatPowRat(x *big.Float, r *big.Rat, prec uint) (*big.Float, error) {
+	if r.IsInt() && r.Num().IsInt64() && abs64(r.Num().Int64()) <= 1<<20 {
+		return intPow(x, r.Num().Int64(), prec), nil
+	}
+	if x.Sign() < 0 {
+		if r.Denom().Bit(0) == 0 {
+			return nil, ErrUndefined
+		}
+		v, err := floatPowRat(new(big.Float).Neg(x), r, prec)
+		if err != nil {
+			return nil, err
+		}
+		if r.Num().Bit(0) == 1 {
+			v.Neg(v)
+		}
+		return v, nil
+	}
+	if r.Cmp(half) == 0 {
+		return newFloat(prec).Sqrt(x), nil
+	}
+	return powFloat(x, newFloat(prec).SetRat(r), prec)
+}
+
+func abs64(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// intPow computes x^n by repeated squaring.
+func intPow(x *big.Float, n int64, prec uint) *big.Float {
+	work := prec + 64
+	result := newFloat(work).SetInt64(1)
+	base := newFloat(work).Set(x)
+	for k := abs64(n); k > 0; k >>= 1 {
+		if k&1 == 1 {
+			result.Mul(result, base)
+		}
+		base.Mul(base, base)
+	}
+	if n < 0 {
+		result.Quo(newFloat(work).SetInt64(1), result)
+	}
+	return newFloat(prec).Set(result)
+}
+
+// powFloat computes x^y = exp(y ln x) for a non-rational exponent.
+func powFloat(x, y *big.Float, prec uint) (*big.Float, error) {
+	switch x.Sign() {
+	case 0:
+		switch y.Sign() {
+		case 1:
+			return newFloat(prec), nil
+		case 0:
+			return newFloat(prec).SetInt64(1), nil
+		}
+		return newFloat(prec).SetInf(false), nil
+	case -1:
+		if n, acc := y.Int64(); acc == big.Exact && y.IsInt() {
+			return intPow(x, n, prec), nil
+		}
+		return nil, ErrUndefined
+	}
+	work := prec + 64
+	ln, err := bigLn(x, work)
+	if err != nil {
+		return nil, err
+	}
+	return newFloat(prec).Set(bigExp(ln.Mul(ln, y), work)), nil
+}
+
+func bigFunc(name string, x *big.Float, prec uint) (*big.Float, error) {
+	work := prec + 32
+	one := newFloat(work).SetInt64(1)
+	var v *big.Float
+	switch name {
+	case "sin", "cos", "tan", "sec", "csc", "cot":
+		if x.IsInf() {
+			return nil, ErrUndefined
+		}
+		sin, cos := bigSinCos(x, work)
+		switch name {
+		case "sin":
+			v = sin
+		case "cos":
+			v = cos
+		case "tan":
+			v = sin.Quo(sin, cos)
+		case "sec":
+			v = cos.Quo(one, cos)
+		case "csc":
+			v = sin.Quo(one, sin)
+		case "cot":
+			v = cos.Quo(cos, sin)
+		}
+	case "asin", "acos":
+		asin, err := bigAsin(x, work)
+		if err != nil {
+			return nil, err
+		}
+		v = asin
+		if name == "acos" {
+			v = halfPi(work).Sub(halfPi(work), asin)
+		}
+	case "atan":
+		v = bigAtan(x, work)
+	case "sinh", "cosh":
+		ex := bigExp(x, work)
+		inv := newFloat(work).Quo(one, ex)
+		if name == "sinh" {
+			v = ex.Sub(ex, inv)
+		} else {
+			v = ex.Add(ex, inv)
+		}
+		v.SetMantExp(v, -1)
+	case "tanh":
+		// 1 - 2/(e^(2x) + 1) stays finite for large |x|
+		e2x := bigExp(newFloat(work).SetMantExp(x, 1), work)
+		v = newFloat(work).Quo(newFloat(work).SetInt64(2), e2x.Add(e2x, one))
+		v.Sub(one, v)
+	case "ln", "log":
+		ln, err := bigLn(x, work)
+		if err != nil {
+			return nil, err
+		}
+		v = ln
+		if name == "log" {
+			ln10, _ := bigLn(newFloat(work).SetInt64(10), work)
+			v.Quo(v, ln10)
+		}
+	case "abs":
+		v = newFloat(work).Abs(x)
+	default:
+		return nil, fmt.Errorf("unknown function %q", name)
+	}
+	return newFloat(prec).Set(v), nil
+}
+
+// negligible reports whether adding term can no longer change sum at prec.
+func negligible(term, sum *big.Float, prec uint) bool {
+	if term.Sign() == 0 {
+		return true
+	}
+	scale := 0
+	if sum.Sign() != 0 {
+		scale = sum.MantExp(nil)
+	}
+	return term.MantExp(nil) < scale-int(prec)
+}
+
+// bigExp computes e^x as 2^n · e^r with |r| ≤ ln(2)/2, evaluating e^r by
+// its Taylor series after halving r eight times.
+func bigExp(x *big.Float, prec uint) *big.Float {
+	if x.IsInf() {
+		if x.Sign() > 0 {
+			return newFloat(prec).SetInf(false)
+		}
+		return newFloat(prec)
+	}
+	if x.MantExp(nil) > 31 {
+		// Beyond the exponent range of big.Float
+		if x.Sign() > 0 {
+			return newFloat(prec).SetInf(false)
+		}
+		return newFloat(prec)
+	}
+
+	const halvings = 8
+	work := prec + 64
+	ln2 := bigLn2(work)
+	q := newFloat(work).Quo(x, ln2)
+	if q.Sign() >= 0 {
+		q.Add(q, big.NewFloat(0.5))
+	} else {
+		q.Sub(q, big.NewFloat(0.5))
+	}
+	n, _ := q.Int64()
+	r := newFloat(work).Sub(x, newFloat(work).Mul(ln2, newFloat(work).SetInt64(n)))
+	r.SetMantExp(r, -halvings)
+
+	sum := newFloat(work).SetInt64(1)
+	term := newFloat(work).SetInt64(1)
+	for i := int64(1); ; i++ {
+		term.Mul(term, r)
+		term.Quo(term, newFloat(work).SetInt64(i))
+		if negligible(term, sum, work) {
+			break
+		}
+		sum.Add(sum, term)
+	}
+	for i := 0; i < halvings; i++ {
+		sum.Mul(sum, sum)
+	}
+	return newFloat(prec).SetMantExp(sum, int(n))
+}
+
+// bigLn computes ln(x) = ln(m) + k ln(2) for x = m·2^k with m in [0.5, 1),
+// using ln(m) = 2 atanh((m-1)/(m+1)).
+func bigLn(x *big.Float, prec uint) (*big.Float, error) {
+	switch {
+	case x.Sign() < 0:
+		return nil, ErrUndefined
+	case x.Sign() == 0:
+		return newFloat(prec).SetInf(true), nil
+	case x.IsInf():
+		return newFloat(prec).SetInf(false), nil
+	}
+	work := prec + 64
+	m := newFloat(work)
+	k := x.MantExp(m)
+	one := newFloat(work).SetInt64(1)
+	z := newFloat(work).Quo(newFloat(work).Sub(m, one), newFloat(work).Add(m, one))
+	ln := oddSeries(z, false, work)
+	ln.SetMantExp(ln, 1)
+	ln.Add(ln, newFloat(work).Mul(bigLn2(work), newFloat(work).SetInt64(int64(k))))
+	return newFloat(prec).Set(ln), nil
+}
+
+// oddSeries sums z + z³/3 + z⁵/5 + ..., the series of atanh, or of atan
+// when alternate is set. It converges quickly for |z| ≤ 1/3.
+func oddSeries(z *big.Float, alternate bool, prec uint) *big.Float {
+	sum := newFloat(prec).Set(z)
+	power := newFloat(prec).Set(z)
+	z2 := newFloat(prec).Mul(z, z)
+	if alternate {
+		z2.Neg(z2)
+	}
+	for k := int64(3); ; k += 2 {
+		power.Mul(power, z2)
+		term := newFloat(prec).Quo(power, newFloat(prec).SetInt64(k))
+		if negligible(term, sum, prec) {
+			break
+		}
+		sum.Add(sum, term)
+	}
+	return sum
+}
+
+// bigSinCos reduces x to [-π, π] and sums the Taylor series of both.
+func bigSinCos(x *big.Float, prec uint) (*big.Float, *big.Float) {
+	work := prec + 64
+	if exp := x.MantExp(nil); exp > 0 {
+		work += uint(exp)
+	}
+	pi := bigPi(work)
+	twoPi := newFloat(work).SetMantExp(pi, 1)
+	turns, _ := newFloat(work).Quo(x, twoPi).Int(nil)
+	r := newFloat(work).Sub(x, newFloat(work).Mul(twoPi, newFloat(work).SetInt(turns)))
+	if r.Cmp(pi) > 0 {
+		r.Sub(r, twoPi)
+	} else if r.Cmp(newFloat(work).Neg(pi)) < 0 {
+		r.Add(r, twoPi)
+	}
+
+	r2 := newFloat(work).Mul(r, r)
+	r2.Neg(r2)
+	threshold := newFloat(work).SetMantExp(newFloat(work).SetInt64(1), -int(work))
+	series := func(first *big.Float, k int64) *big.Float {
+		sum := newFloat(work).Set(first)
+		term := newFloat(work).Set(first)
+		for ; ; k += 2 {
+			term.Mul(term, r2)
+			term.Quo(term, newFloat(work).SetInt64(k*(k+1)))
+			if newFloat(work).Abs(term).Cmp(threshold) < 0 {
+				break
+			}
+			sum.Add(sum, term)
+		}
+		return newFloat(prec).Set(sum)
+	}
+	return series(r, 2), series(newFloat(work).SetInt64(1), 1)
+}
+
+// bigAtan maps |x| into [0, 1], halves the angle until |x| ≤ 1/8 with
+// atan(x) = 2 atan(x / (1 + sqrt(1 + x²))), then sums the series.
+func bigAtan(x *big.Float, prec uint) *big.Float {
+	if x.IsInf() {
+		v := halfPi(prec)
+		if x.Sign() < 0 {
+			v.Neg(v)
+		}
+		return v
+	}
+	work := prec + 64
+	one := newFloat(work).SetInt64(1)
+	a := newFloat(work).Abs(x)
+	invert := a.Cmp(one) > 0
+	if invert {
+		a.Quo(one, a)
+	}
+	doublings := 0
+	for a.Cmp(big.NewFloat(0.125)) > 0 {
+		root := newFloat(work).Sqrt(newFloat(work).Add(one, newFloat(work).Mul(a, a)))
+		a.Quo(a, root.Add(root, one))
+		doublings++
+	}
+	v := oddSeries(a, true, work)
+	v.SetMantExp(v, doublings)
+	if invert {
+		v.Sub(halfPi(work), v)
+	}
+	if x.Sign() < 0 {
+		v.Neg(v)
+	}
+	return newFloat(prec).Set(v)
+}
+
+// bigAsin computes asin(x) = atan(x / sqrt(1 - x²)).
+func bigAsin(x *big.Float, prec uint) (*big.Float, error) {
+	one := newFloat(prec).SetInt64(1)
+	switch newFloat(prec).Abs(x).Cmp(one) {
+	case 1:
+		return nil, ErrUndefined
+	case 0:
+		v := halfPi(prec)
+		if x.Sign() < 0 {
+			v.Neg(v)
+		}
+		return v, nil
+	}
+	root := newFloat(prec).Sqrt(newFloat(prec).Sub(one, newFloat(prec).Mul(x, x)))
+	return bigAtan(root.Quo(x, root), prec), nil
+}
+
+var constants = struct {
+	sync.Mutex
+	pi, ln2 map[uint]*big.Float
+}{pi: map[uint]*big.Float{}, ln2: map[uint]*big.Float{}}
+
+// bigPi computes π = 16 atan(1/5) - 4 atan(1/239), cached per precision.
+func bigPi(prec uint) *big.Float {
+	constants.Lock()
+	defer constants.Unlock()
+	if v, ok := constants.pi[prec]; ok {
+		return newFloat(prec).Set(v)
+	}
+	work := prec + 32
+	a := oddSeries(newFloat(work).Quo(newFloat(work).SetInt64(1), newFloat(work).SetInt64(5)), true, work)
+	b := oddSeries(newFloat(work).Quo(newFloat(work).SetInt64(1), newFloat(work).SetInt64(239)), true, work)
+	a.Mul(a, newFloat(work).SetInt64(16))
+	b.Mul(b, newFloat(work).SetInt64(4))
+	constants.pi[prec] = newFloat(prec).Sub(a, b)
+	return newFloat(prec).Set(constants.pi[prec])
+}
+
+func halfPi(prec uint) *big.Float {
+	pi := bigPi(prec)
+	return pi.SetMantExp(pi, -1)
+}
+
+// bigLn2 computes ln(2) = 2 atanh(1/3), cached per precision.
+func bigLn2(prec uint) *big.Float {
+	constants.Lock()
+	defer constants.Unlock()
+	if v, ok := constants.ln2[prec]; ok {
+		return newFloat(prec).Set(v)
+	}
+	work := prec + 32
+	v := oddSeries(newFloat(work).Quo(newFloat(work).SetInt64(1), newFloat(work).SetInt64(3)), false, work)
+	constants.ln2[prec] = newFloat(prec).SetMantExp(v, 1)
+	return newFloat(prec).Set(constants.ln2[prec])
+}
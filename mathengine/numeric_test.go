@@ -0,0 +1,79 @@
+package mathengine
+
+import (
+	"math/big"
+	"testing"
+
+	"maths-solution-backend/models"
+)
+
+func TestFormatDecimal(t *testing.T) {
+	tests := []struct {
+		value  string
+		digits int
+		want   string
+	}{
+		{"0.1", 10, "0.1"},
+		{"0.30000000000000004", 10, "0.3"},
+		{"3.14159265358979", 5, "3.1416"},
+		{"-2.5", 10, "-2.5"},
+		{"0.0000003333333333333", 10, "0.0000003333333333"},
+		{"1e-100", 10, "0." + zeros(99) + "1"},
+		{"999.96", 4, "999.96"},
+		{"999.94", 3, "999.9"},
+		{"12345678901.5", 10, "12345678901.5"},
+		{"123.456", 3, "123.5"},
+		{"1e150", 10, "1e+150"},
+	}
+	for _, tt := range tests {
+		x, _, err := big.ParseFloat(tt.value, 10, precisionBits(MaxPrecision), big.ToNearestEven)
+		if err != nil {
+			t.Fatalf("ParseFloat(%q): %v", tt.value, err)
+		}
+		if got := FormatDecimal(x, tt.digits); got != tt.want {
+			t.Errorf("FormatDecimal(%s, %d) = %s, want %s", tt.value, tt.digits, got, tt.want)
+		}
+	}
+}
+
+func TestFormatFinal(t *testing.T) {
+	tests := []struct {
+		final string
+		want  string
+	}{
+		{"0.30000000000000004", "0.3"},
+		{"x = 0.50", "x = 0.50"},
+		{"x = 12345678901.5", "x = 12345678901.5"},
+		{"x = 12345678901.50000001", "x = 12345678901.5"},
+		{"3.333333333333333e-07", "0.0000003333333333"},
+	}
+	for _, tt := range tests {
+		if got := FormatFinal(tt.final, 10); got != tt.want {
+			t.Errorf("FormatFinal(%q) = %q, want %q", tt.final, got, tt.want)
+		}
+	}
+}
+
+// TestDecimalAnswersVerify checks that decimal answers the engine writes
+// read back as the same value.
+func TestDecimalAnswersVerify(t *testing.T) {
+	en := NewEngine()
+	for _, input := range []string{"0.1^100", "0.000001/3", "2.5^40", "1.5*pi", "0.3*10^15/7"} {
+		result, err := en.Solve(input)
+		if err != nil {
+			t.Errorf("Solve(%q): %v", input, err)
+			continue
+		}
+		if v := Verify(input, result.Final); v.Status != models.VerificationVerified {
+			t.Errorf("Verify(%q, %q) = %s: %s", input, result.Final, v.Status, v.Detail)
+		}
+	}
+}
+
+func zeros(n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = '0'
+	}
+	return string(b)
+}
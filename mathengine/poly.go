@@ -0,0 +1,282 @@
+package mathengine
+
+import (
+	"math/big"
+	"sort"
+)
+
+// Poly is a univariate polynomial with rational coefficients; Coeffs[i] is
+// the coefficient of Var^i.
+type Poly struct {
+	Var    string
+	Coeffs []*big.Rat
+}
+
+// ToPoly converts e into a polynomial in v. It fails when e contains other
+// variables or non-polynomial terms in v.
+func ToPoly(e Expr, v string) (*Poly, bool) {
+	p := &Poly{Var: v}
+	for _, term := range termsOf(Expand(e)) {
+		coef, rest := splitCoeff(term)
+		n, ok := monomialDegree(rest, v)
+		if !ok {
+			return nil, false
+		}
+		for len(p.Coeffs) <= n {
+			p.Coeffs = append(p.Coeffs, new(big.Rat))
+		}
+		p.Coeffs[n].Add(p.Coeffs[n], coef)
+	}
+	p.trim()
+	return p, true
+}
+
+func monomialDegree(e Expr, v string) (int, bool) {
+	switch e := e.(type) {
+	case nil:
+		return 0, true
+	case *Sym:
+		if e.Name == v {
+			return 1, true
+		}
+	case *Pow:
+		if s, ok := e.Base.(*Sym); ok && s.Name == v {
+			if n, ok := isInt(e.Exp); ok && n.Sign() > 0 && n.IsInt64() && n.Int64() <= 64 {
+				return int(n.Int64()), true
+			}
+		}
+	}
+	return 0, false
+}
+
+func (p *Poly) trim() {
+	for len(p.Coeffs) > 0 && p.Coeffs[len(p.Coeffs)-1].Sign() == 0 {
+		p.Coeffs = p.Coeffs[:len(p.Coeffs)-1]
+	}
+}
+
+// Degree returns -1 for the zero polynomial.
+func (p *Poly) Degree() int {
+	return len(p.Coeffs) - 1
+}
+
+func (p *Poly) Coeff(i int) *big.Rat {
+	if i < len(p.Coeffs) {
+		return p.Coeffs[i]
+	}
+	return new(big.Rat)
+}
+
+func (p *Poly) Lead() *big.Rat {
+	return p.Coeff(p.Degree())
+}
+
+func (p *Poly) Eval(x *big.Rat) *big.Rat {
+	result := new(big.Rat)
+	for i := p.Degree(); i >= 0; i-- {
+		result.Mul(result, x)
+		result.Add(result, p.Coeffs[i])
+	}
+	return result
+}
+
+// Expr converts the polynomial back to a canonical expression.
+func (p *Poly) Expr() Expr {
+	terms := make([]Expr, 0, len(p.Coeffs))
+	x := NewSym(p.Var)
+	for i := p.Degree(); i >= 0; i-- {
+		if p.Coeffs[i].Sign() == 0 {
+			continue
+		}
+		terms = append(terms, simplifyMul([]Expr{NewNum(p.Coeffs[i]), simplifyPow(x, NewInt(int64(i)))}))
+	}
+	return simplifyAdd(terms)
+}
+
+// divideLinear divides p by (x - r) using synthetic division; the remainder
+// is assumed to be zero.
+func (p *Poly) divideLinear(r *big.Rat) *Poly {
+	n := p.Degree()
+	q := &Poly{Var: p.Var, Coeffs: make([]*big.Rat, n)}
+	carry := new(big.Rat)
+	for i := n; i >= 1; i-- {
+		carry = new(big.Rat).Add(p.Coeffs[i], new(big.Rat).Mul(carry, r))
+		q.Coeffs[i-1] = carry
+	}
+	return q
+}
+
+// DivMod divides p by d, returning the quotient and remainder.
+func (p *Poly) DivMod(d *Poly) (*Poly, *Poly) {
+	rem := &Poly{Var: p.Var, Coeffs: make([]*big.Rat, len(p.Coeffs))}
+	for i, c := range p.Coeffs {
+		rem.Coeffs[i] = new(big.Rat).Set(c)
+	}
+	quo := &Poly{Var: p.Var}
+	if rem.Degree() < d.Degree() {
+		return quo, rem
+	}
+
+	quo.Coeffs = make([]*big.Rat, rem.Degree()-d.Degree()+1)
+	for i := range quo.Coeffs {
+		quo.Coeffs[i] = new(big.Rat)
+	}
+	for rem.Degree() >= d.Degree() {
+		shift := rem.Degree() - d.Degree()
+		factor := new(big.Rat).Quo(rem.Lead(), d.Lead())
+		quo.Coeffs[shift] = factor
+		for i, c := range d.Coeffs {
+			rem.Coeffs[i+shift].Sub(rem.Coeffs[i+shift], new(big.Rat).Mul(factor, c))
+		}
+		rem.trim()
+	}
+	quo.trim()
+	return quo, rem
+}
+
+// integerForm scales p to integer coefficients with positive content removed,
+// returning the scaled polynomial and the factor taken out.
+func (p *Poly) integerForm() (*Poly, *big.Rat) {
+	lcm := big.NewInt(1)
+	for _, c := range p.Coeffs {
+		g := new(big.Int).GCD(nil, nil, lcm, c.Denom())
+		lcm.Mul(lcm, new(big.Int).Quo(c.Denom(), g))
+	}
+	gcd := new(big.Int)
+	for _, c := range p.Coeffs {
+		n := new(big.Int).Mul(c.Num(), new(big.Int).Quo(lcm, c.Denom()))
+		gcd.GCD(nil, nil, gcd, new(big.Int).Abs(n))
+	}
+	if gcd.Sign() == 0 {
+		gcd.SetInt64(1)
+	}
+	if p.Lead().Sign() < 0 {
+		gcd.Neg(gcd)
+	}
+	content := new(big.Rat).SetFrac(gcd, lcm)
+	scaled := &Poly{Var: p.Var, Coeffs: make([]*big.Rat, len(p.Coeffs))}
+	for i, c := range p.Coeffs {
+		scaled.Coeffs[i] = new(big.Rat).Quo(c, content)
+	}
+	return scaled, content
+}
+
+// RationalRoots finds the rational roots of p (with multiplicity) using the
+// rational root theorem, and returns the unfactored remainder.
+func (p *Poly) RationalRoots() ([]*big.Rat, *Poly) {
+	var roots []*big.Rat
+	rest := &Poly{Var: p.Var, Coeffs: p.Coeffs}
+
+	// Zero roots first, so the constant term is non-zero below
+	for rest.Degree() > 0 && rest.Coeffs[0].Sign() == 0 {
+		roots = append(roots, new(big.Rat))
+		rest = &Poly{Var: p.Var, Coeffs: rest.Coeffs[1:]}
+	}
+
+	for rest.Degree() > 0 {
+		scaled, _ := rest.integerForm()
+		candidates := rootCandidates(scaled.Coeffs[0].Num(), scaled.Lead().Num())
+		found := false
+		for _, c := range candidates {
+			if scaled.Eval(c).Sign() == 0 {
+				roots = append(roots, c)
+				rest = rest.divideLinear(c)
+				found = true
+				break
+			}
+		}
+		if !found {
+			break
+		}
+	}
+
+	sort.Slice(roots, func(i, j int) bool { return roots[i].Cmp(roots[j]) < 0 })
+	return roots, rest
+}
+
+// rootCandidates returns ±p/q for p | constant and q | lead. Coefficients too
+// large to enumerate cheaply yield no candidates.
+func rootCandidates(constant, lead *big.Int) []*big.Rat {
+	ps := divisors(new(big.Int).Abs(constant))
+	qs := divisors(new(big.Int).Abs(lead))
+	if ps == nil || qs == nil {
+		return nil
+	}
+	seen := map[string]bool{}
+	var out []*big.Rat
+	for _, p := range ps {
+		for _, q := range qs {
+			for _, sign := range []int64{1, -1} {
+				r := new(big.Rat).SetFrac(new(big.Int).Mul(big.NewInt(sign), p), q)
+				if !seen[r.String()] {
+					seen[r.String()] = true
+					out = append(out, r)
+				}
+			}
+		}
+	}
+	return out
+}
+
+func divisors(n *big.Int) []*big.Int {
+	if !n.IsInt64() || n.Int64() > 1_000_000 {
+		return nil
+	}
+	v := n.Int64()
+	var out []*big.Int
+	for d := int64(1); d*d <= v; d++ {
+		if v%d == 0 {
+			out = append(out, big.NewInt(d))
+			if d*d != v {
+				out = append(out, big.NewInt(v/d))
+			}
+		}
+	}
+	return out
+}
+
+// Factor factors a univariate polynomial over the rationals as far as its
+// rational roots allow. Non-polynomial input is returned simplified.
+func Factor(e Expr) Expr {
+	e = Simplify(e)
+	vars := FreeSymbols(e)
+	if len(vars) != 1 {
+		return e
+	}
+	p, ok := ToPoly(e, vars[0])
+	if !ok || p.Degree() < 2 {
+		return e
+	}
+
+	scaled, content := p.integerForm()
+	roots, rest := scaled.RationalRoots()
+	if len(roots) == 0 {
+		return e
+	}
+
+	x := NewSym(p.Var)
+	factors := []Expr{NewNum(content)}
+	restScale := new(big.Rat).Set(rest.Lead())
+	for _, r := range roots {
+		// Write each root p/q as the integer factor (q x - p)
+		q := new(big.Rat).SetInt(r.Denom())
+		restScale.Quo(restScale, q)
+		factors = append(factors, simplifyAdd([]Expr{
+			simplifyMul([]Expr{NewNum(q), x}),
+			NewNum(new(big.Rat).Neg(new(big.Rat).SetInt(r.Num()))),
+		}))
+	}
+	if rest.Degree() > 0 {
+		monic := &Poly{Var: p.Var, Coeffs: make([]*big.Rat, len(rest.Coeffs))}
+		for i, c := range rest.Coeffs {
+			monic.Coeffs[i] = new(big.Rat).Quo(c, rest.Lead())
+		}
+		// Keep the remaining factor with integer coefficients where possible
+		integral, scale := monic.integerForm()
+		restScale.Mul(restScale, scale)
+		factors = append(factors, integral.Expr())
+	}
+	factors[0] = NewNum(new(big.Rat).Mul(content, restScale))
+	// Repeated roots collapse into powers such as (x - 1)^2
+	return simplifyMul(factors)
+}
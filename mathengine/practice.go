@@ -0,0 +1,489 @@
+package mathengine
+
+import (
+	"fmt"
+	"math/big"
+	"math/rand/v2"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"maths-solution-backend/models"
+	"maths-solution-backend/parser"
+)
+
+// Practice topics
+const (
+	TopicArithmetic = "arithmetic"
+	TopicLinear     = "linear_equation"
+	TopicQuadratic  = "quadratic_equation"
+	TopicPolynomial = "polynomial_equation"
+	TopicExpand     = "expand"
+	TopicFactor     = "factor"
+	TopicSimplify   = "simplify"
+	TopicDerivative = "derivative"
+	TopicIntegral   = "integral"
+)
+
+// Difficulty levels of generated problems
+const (
+	DifficultyEasy   = "easy"
+	DifficultyMedium = "medium"
+	DifficultyHard   = "hard"
+)
+
+// Topics lists the topics GeneratePractice accepts.
+var Topics = []string{TopicArithmetic, TopicLinear, TopicQuadratic, TopicPolynomial, TopicExpand, TopicFactor, TopicSimplify, TopicDerivative, TopicIntegral}
+
+// practiceTemplates hold problems per topic and difficulty. {a} to {e} are
+// nonzero coefficients, {n} a small exponent. Equations and factoring are
+// built from their roots instead, so their answers are always nice.
+var practiceTemplates = map[string]map[string][]string{
+	TopicArithmetic: {
+		DifficultyEasy:   {"{a} + {b}*{c}", "{a}*{b} - {c}"},
+		DifficultyMedium: {"({a} - {b})*{c} + {d}", "{a}/{b} + {c}/{d}"},
+		DifficultyHard:   {"({a}/{b} - {c})^2", "{a}^2 - {b}*({c} - {d})"},
+	},
+	TopicLinear: {
+		DifficultyEasy:   {"{a}x + {b} = {c}"},
+		DifficultyMedium: {"{a}x + {b} = {c}x + {d}"},
+		DifficultyHard:   {"{a}(x + {b}) = {c}(x + {d}) + {e}"},
+	},
+	TopicSimplify: {
+		DifficultyEasy:   {"{a}x + {b}x - {c}x", "{a}x + {b} + {c}x"},
+		DifficultyMedium: {"{a}(x + {b}) - {c}x", "{a}x^2 + {b}x - {c}x^2"},
+		DifficultyHard:   {"({a}x^2 + {b}x)/x", "{a}x(x + {b}) - {c}x^2", "x^2 + {a}x - x(x - {b})"},
+	},
+	TopicExpand: {
+		DifficultyEasy:   {"expand((x + {a})^2)", "expand((x + {a})(x + {b}))"},
+		DifficultyMedium: {"expand(({a}x + {b})({c}x + {d}))", "expand(({a}x + {b})^2)"},
+		DifficultyHard:   {"expand(({a}x + {b})^3)", "expand((x + {a})(x^2 + {b}x + {c}))"},
+	},
+	TopicDerivative: {
+		DifficultyEasy:   {"diff({a}x^3 + {b}x^2 + {c}x)"},
+		DifficultyMedium: {"diff({a}sin({b}x))", "diff({a}e^({b}x))", "diff(({a}x + {b})^{n})"},
+		DifficultyHard:   {"diff(x^{n}*sin({a}x))", "diff(ln({a}x^2 + {b}))"},
+	},
+	TopicIntegral: {
+		DifficultyEasy:   {"integrate({a}x^2 + {b}x + {c})"},
+		DifficultyMedium: {"integrate({a}cos({b}x))", "integrate({a}e^({b}x))"},
+		DifficultyHard:   {"integrate(x*e^({a}x))", "integrate({a}x*cos(x))"},
+	},
+}
+
+// maxPracticeAttempts bounds the retries spent finding one acceptable problem.
+const maxPracticeAttempts = 50
+
+// PracticeOptions control problem generation. Nice asks for answers with
+// integers and small fractions only.
+type PracticeOptions struct {
+	Difficulty string
+	Count      int
+	Nice       bool
+}
+
+// PracticeProblem is a generated problem in canonical ASCII with the local
+// engine's answer.
+type PracticeProblem struct {
+	Problem string
+	Answer  string
+}
+
+// GeneratePractice returns opts.Count distinct problems on topic.
+func (en *Engine) GeneratePractice(topic string, opts PracticeOptions) ([]PracticeProblem, error) {
+	var generate func() (string, error)
+	switch topic {
+	case TopicQuadratic:
+		generate = func() (string, error) { return rootsEquation(2, opts.Difficulty, 0), nil }
+	case TopicPolynomial:
+		generate = func() (string, error) { return rootsEquation(3, opts.Difficulty, 0), nil }
+	case TopicFactor:
+		degree := 2
+		if opts.Difficulty == DifficultyHard {
+			degree = 3
+		}
+		generate = func() (string, error) { return "factor(" + rootsPolynomial(degree, opts.Difficulty, 0) + ")", nil }
+	default:
+		templates, ok := practiceTemplates[topic][opts.Difficulty]
+		if !ok {
+			return nil, fmt.Errorf("unknown topic %q (available: %s)", topic, strings.Join(Topics, ", "))
+		}
+		generate = func() (string, error) {
+			return fillTemplate(templates[rand.IntN(len(templates))], opts.Difficulty)
+		}
+	}
+	return en.collectPractice(generate, opts, "")
+}
+
+// PracticeLike returns opts.Count problems with the structure of problem
+// (canonical ASCII) and fresh coefficients. Polynomial equations and
+// factoring problems of degree two or three are rebuilt from new roots;
+// anything else keeps its syntax tree with its numbers replaced.
+func (en *Engine) PracticeLike(problem string, opts PracticeOptions) ([]PracticeProblem, error) {
+	node, err := parser.Parse(problem)
+	if err != nil {
+		return nil, err
+	}
+
+	var generate func() (string, error)
+	if degree, lead, factor, ok := polynomialShape(node); ok {
+		generate = func() (string, error) {
+			if factor {
+				return "factor(" + rootsPolynomial(degree, opts.Difficulty, lead) + ")", nil
+			}
+			return rootsEquation(degree, opts.Difficulty, lead), nil
+		}
+	} else {
+		generate = func() (string, error) {
+			return parser.Format(randomizeNumbers(node, opts.Difficulty)), nil
+		}
+	}
+	return en.collectPractice(generate, opts, problem)
+}
+
+// collectPractice draws problems until it has opts.Count distinct ones the
+// engine solves, with nice answers if requested.
+func (en *Engine) collectPractice(generate func() (string, error), opts PracticeOptions, exclude string) ([]PracticeProblem, error) {
+	seen := map[string]bool{exclude: true}
+	var problems []PracticeProblem
+	for attempts := 0; len(problems) < opts.Count && attempts < opts.Count*maxPracticeAttempts; attempts++ {
+		raw, err := generate()
+		if err != nil {
+			return nil, err
+		}
+		node, err := parser.Parse(raw)
+		if err != nil {
+			continue
+		}
+		problem := parser.Format(node)
+		if seen[problem] {
+			continue
+		}
+		result, err := en.Solve(problem)
+		if err != nil || result.Final == "" || (opts.Nice && !niceAnswer(node, result.Final)) {
+			continue
+		}
+		seen[problem] = true
+		problems = append(problems, PracticeProblem{Problem: problem, Answer: result.Final})
+	}
+	if len(problems) == 0 {
+		return nil, fmt.Errorf("%w: cannot generate problems like this one", ErrUnsupported)
+	}
+	return problems, nil
+}
+
+// DetectTopic names the topic of problem (canonical ASCII).
+func DetectTopic(problem string) string {
+	node, err := parser.Parse(problem)
+	if err != nil {
+		return TopicSimplify
+	}
+	if degree, _, factor, ok := polynomialShape(node); ok {
+		switch {
+		case factor:
+			return TopicFactor
+		case degree == 2:
+			return TopicQuadratic
+		}
+		return TopicPolynomial
+	}
+	command, _, body := splitCommand(node)
+	if _, ok := body.(*parser.Relation); ok || command == "solve" {
+		return TopicLinear
+	}
+	switch command {
+	case "expand":
+		return TopicExpand
+	case "factor":
+		return TopicFactor
+	case "diff", "derivative", "differentiate":
+		return TopicDerivative
+	case "integrate", "integral", "antiderivative":
+		return TopicIntegral
+	}
+	e, err := FromNode(body)
+	if err == nil && len(FreeSymbols(e)) == 0 {
+		return TopicArithmetic
+	}
+	return TopicSimplify
+}
+
+// polynomialShape recognises equations and factoring problems that are
+// polynomials of degree two or three in one variable, returning the degree
+// and the size of the leading coefficient.
+func polynomialShape(node parser.Node) (degree int, lead int64, factor, ok bool) {
+	command, _, body := splitCommand(node)
+	var e Expr
+	var err error
+	switch {
+	case command == "factor":
+		factor = true
+		e, err = FromNode(body)
+	case command == "" || command == "solve":
+		rel, isRel := body.(*parser.Relation)
+		if !isRel {
+			return 0, 0, false, false
+		}
+		if rel.Op != "=" {
+			return 0, 0, false, false
+		}
+		left, errL := FromNode(rel.Left)
+		right, errR := FromNode(rel.Right)
+		if errL != nil || errR != nil {
+			return 0, 0, false, false
+		}
+		e = Sub(left, right)
+	default:
+		return 0, 0, false, false
+	}
+	if err != nil {
+		return 0, 0, false, false
+	}
+	vars := FreeSymbols(e)
+	if len(vars) != 1 {
+		return 0, 0, false, false
+	}
+	p, isPoly := ToPoly(Expand(e), vars[0])
+	if !isPoly || p.Degree() < 2 || p.Degree() > 3 || !p.Coeff(p.Degree()).IsInt() {
+		return 0, 0, false, false
+	}
+	lead = new(big.Int).Abs(p.Coeff(p.Degree()).Num()).Int64()
+	return p.Degree(), lead, factor, true
+}
+
+// rootsPolynomial builds lead·(x - r1)···(x - rn) expanded, with integer
+// roots. A zero lead picks one to suit difficulty.
+func rootsPolynomial(degree int, difficulty string, lead int64) string {
+	if lead == 0 {
+		lead = 1
+		if difficulty == DifficultyHard {
+			lead = int64(1 + rand.IntN(3))
+		}
+	}
+	bound := coefficientBound(difficulty)
+	if bound > 9 {
+		bound = 9
+	}
+	factors := []Expr{NewInt(lead)}
+	for i := 0; i < degree; i++ {
+		root := int64(1 + rand.IntN(bound))
+		if difficulty != DifficultyEasy && rand.IntN(2) == 0 {
+			root = -root
+		}
+		factors = append(factors, Sub(NewSym("x"), NewInt(root)))
+	}
+	return Expand(&Mul{Factors: factors}).String()
+}
+
+func rootsEquation(degree int, difficulty string, lead int64) string {
+	return rootsPolynomial(degree, difficulty, lead) + " = 0"
+}
+
+var placeholder = regexp.MustCompile(`\{[a-z]\}`)
+
+// fillTemplate replaces each placeholder of template with a random value.
+// Bases of powers and right operands of * and / stay positive, so that
+// neither -3^2 nor 8/-4 appears.
+func fillTemplate(template, difficulty string) (string, error) {
+	var b strings.Builder
+	last := 0
+	for _, m := range placeholder.FindAllStringIndex(template, -1) {
+		b.WriteString(template[last:m[0]])
+		last = m[1]
+		switch {
+		case template[m[0]:m[1]] == "{n}":
+			b.WriteString(strconv.Itoa(2 + rand.IntN(coefficientBound(difficulty)/3+1)))
+		case strings.HasPrefix(template[m[1]:], "^") || strings.HasSuffix(template[:m[0]], "*") || strings.HasSuffix(template[:m[0]], "/"):
+			b.WriteString(strconv.Itoa(1 + rand.IntN(coefficientBound(difficulty))))
+		default:
+			b.WriteString(strconv.Itoa(randomCoefficient(difficulty)))
+		}
+	}
+	b.WriteString(template[last:])
+	return tidySigns(b.String()), nil
+}
+
+var unitCoefficient = regexp.MustCompile(`(^|[^0-9.])1\*?([a-z(])`)
+
+// tidySigns rewrites "+ -3" as "- 3" and "- -3" as "+ 3" and drops unit
+// coefficients, as a person would write the problem.
+func tidySigns(s string) string {
+	s = strings.NewReplacer("+ -", "- ", "- -", "+ ").Replace(s)
+	return unitCoefficient.ReplaceAllString(s, "$1$2")
+}
+
+func coefficientBound(difficulty string) int {
+	switch difficulty {
+	case DifficultyEasy:
+		return 5
+	case DifficultyHard:
+		return 12
+	}
+	return 9
+}
+
+// randomCoefficient is a nonzero integer within the bound of difficulty,
+// positive on easy problems.
+func randomCoefficient(difficulty string) int {
+	n := 1 + rand.IntN(coefficientBound(difficulty))
+	if difficulty != DifficultyEasy && rand.IntN(3) == 0 {
+		n = -n
+	}
+	return n
+}
+
+// randomizeNumbers copies node with its integer literals replaced by random
+// ones of similar size. Signs, which the syntax tree keeps as operators,
+// plain exponents such as the 2 of x^2, and the literals 0 and 1 stay as
+// they are.
+func randomizeNumbers(node parser.Node, difficulty string) parser.Node {
+	switch n := node.(type) {
+	case *parser.Number:
+		value, err := strconv.Atoi(n.Text)
+		if err != nil || value <= 1 {
+			return n
+		}
+		bound := max(coefficientBound(difficulty), value+3)
+		return &parser.Number{Offset: n.Offset, Text: strconv.Itoa(2 + rand.IntN(bound-1))}
+	case *parser.Unary:
+		return &parser.Unary{Offset: n.Offset, Op: n.Op, X: randomizeNumbers(n.X, difficulty)}
+	case *parser.Binary:
+		y := n.Y
+		if _, plain := n.Y.(*parser.Number); n.Op != "^" || !plain {
+			y = randomizeNumbers(n.Y, difficulty)
+		}
+		return &parser.Binary{Op: n.Op, X: randomizeNumbers(n.X, difficulty), Y: y, Implicit: n.Implicit}
+	case *parser.Call:
+		args := make([]parser.Node, len(n.Args))
+		for i, arg := range n.Args {
+			args[i] = randomizeNumbers(arg, difficulty)
+		}
+		return &parser.Call{Offset: n.Offset, Func: n.Func, Args: args}
+	case *parser.Relation:
+		return &parser.Relation{Op: n.Op, Left: randomizeNumbers(n.Left, difficulty), Right: randomizeNumbers(n.Right, difficulty)}
+	}
+	return node
+}
+
+var (
+	fractionLiteral = regexp.MustCompile(`\d+\s*/\s*(\d+)`)
+	integerLiteral  = regexp.MustCompile(`\d+`)
+)
+
+// niceAnswer accepts answers written with integers up to 1000 and fractions
+// with denominators up to 12, and without decimals or roots. Equations must
+// have integer solutions.
+func niceAnswer(problem parser.Node, final string) bool {
+	command, _, body := splitCommand(problem)
+	if _, ok := body.(*parser.Relation); (ok || command == "solve") && strings.Contains(final, "/") {
+		return false
+	}
+	if strings.Contains(final, ".") || strings.Contains(final, "sqrt") || imaginaryUnit.MatchString(final) {
+		return false
+	}
+	for _, m := range fractionLiteral.FindAllStringSubmatch(final, -1) {
+		if d, _ := strconv.Atoi(m[1]); d > 12 {
+			return false
+		}
+	}
+	for _, literal := range integerLiteral.FindAllString(final, -1) {
+		if n, err := strconv.Atoi(literal); err != nil || n > 1000 {
+			return false
+		}
+	}
+	return true
+}
+
+// imaginaryUnit matches the i of complex roots, but not the letter within
+// a function name such as sin.
+var imaginaryUnit = regexp.MustCompile(`(^|[^a-z])i([^a-z]|$)`)
+
+// GradeAnswer checks a submitted answer to problem (canonical ASCII)
+// independently of reference, then requires the form the problem asks for:
+// expanded for expand, a product for factor, a single number for
+// arithmetic, and no longer than reference for simplification.
+func GradeAnswer(problem, reference, answer string) (bool, *models.Verification) {
+	verification := Verify(problem, answer)
+	if verification.Status != models.VerificationVerified {
+		return false, verification
+	}
+	node, err := parser.Parse(problem)
+	if err != nil {
+		return false, verification
+	}
+	command, _, body := splitCommand(node)
+	if _, ok := body.(*parser.Relation); ok || command == "solve" {
+		return true, verification
+	}
+	if !inFinalForm(command, answer, reference) {
+		verification.Detail = "equal to the problem but not in the form asked for"
+		return false, verification
+	}
+	return true, verification
+}
+
+// rationalConstant reports whether node is a number as it would be written
+// as an answer: a numeral or a fraction of integers in lowest terms, either
+// possibly negated.
+func rationalConstant(node parser.Node) bool {
+	if u, ok := node.(*parser.Unary); ok && u.Op == "-" {
+		node = u.X
+	}
+	switch n := node.(type) {
+	case *parser.Number:
+		return true
+	case *parser.Binary:
+		x := n.X
+		if u, ok := x.(*parser.Unary); ok && u.Op == "-" {
+			x = u.X
+		}
+		num, okNum := x.(*parser.Number)
+		den, okDen := n.Y.(*parser.Number)
+		if n.Op != "/" || !okNum || !okDen {
+			return false
+		}
+		p, okP := new(big.Int).SetString(num.Text, 10)
+		q, okQ := new(big.Int).SetString(den.Text, 10)
+		if !okP || !okQ || q.Cmp(big.NewInt(1)) <= 0 {
+			return false
+		}
+		return new(big.Int).GCD(nil, nil, p, q).Cmp(big.NewInt(1)) == 0
+	}
+	return false
+}
+
+// inFinalForm reports whether answer, an expression equal to the problem,
+// is in the form command asks for.
+func inFinalForm(command, answer, reference string) bool {
+	got, err := Parse(withoutConstant(answer))
+	if err != nil {
+		return false
+	}
+	switch command {
+	case "expand":
+		return Expand(got).String() == got.String()
+	case "factor":
+		switch got.(type) {
+		case *Mul, *Pow, *Num:
+			return true
+		}
+		return false
+	case "diff", "derivative", "differentiate", "integrate", "integral", "antiderivative":
+		return true
+	}
+	if len(FreeSymbols(got)) == 0 {
+		if _, ok := isNum(got); ok {
+			return true
+		}
+		node, err := parser.Parse(withoutConstant(answer))
+		return err == nil && rationalConstant(node)
+	}
+	if reference == "" {
+		return true
+	}
+	want, err := Parse(withoutConstant(reference))
+	if err != nil {
+		return true
+	}
+	return len(got.String()) <= len(want.String())
+}
@@ -0,0 +1,75 @@
+package mathengine
+
+import "testing"
+
+func TestGradeAnswer(t *testing.T) {
+	tests := []struct {
+		problem, reference, answer string
+		correct                    bool
+	}{
+		{"2 - 5", "-3", "-3", true},
+		{"1/4 - 9/4", "-2", "-2", true},
+		{"7/1 - 3/8", "53/8", "53/8", true},
+		{"1/2 - 5/4", "-3/4", "-3/4", true},
+		{"1/2 - 5/4", "-3/4", "-(3/4)", true},
+		{"1/2 + 1", "3/2", "6/4", false},
+		{"1/2 + 1", "3/2", "1 + 1/2", false},
+		{"2 - 5", "-3", "-4", false},
+		{"expand((x + 1)^2)", "x^2 + 2*x + 1", "x^2 + 2*x + 1", true},
+		{"expand((x + 1)^2)", "x^2 + 2*x + 1", "(x + 1)^2", false},
+		{"factor(x^2 - 1)", "(x - 1)*(x + 1)", "(x + 1)*(x - 1)", true},
+		{"factor(x^2 - 1)", "(x - 1)*(x + 1)", "x^2 - 1", false},
+		{"2x + 1 = 5", "x = 2", "x = 2", true},
+	}
+	for _, tt := range tests {
+		if got, v := GradeAnswer(tt.problem, tt.reference, tt.answer); got != tt.correct {
+			t.Errorf("GradeAnswer(%q, %q, %q) = %v (%s: %s), want %v", tt.problem, tt.reference, tt.answer, got, v.Status, v.Detail, tt.correct)
+		}
+	}
+}
+
+// Every generated problem must accept its own answer.
+func TestGradeGeneratedAnswers(t *testing.T) {
+	en := NewEngine()
+	for _, topic := range Topics {
+		for _, difficulty := range []string{DifficultyEasy, DifficultyMedium, DifficultyHard} {
+			for _, nice := range []bool{true, false} {
+				problems, err := en.GeneratePractice(topic, PracticeOptions{Difficulty: difficulty, Count: 20, Nice: nice})
+				if err != nil {
+					t.Errorf("GeneratePractice(%s, %s): %v", topic, difficulty, err)
+					continue
+				}
+				for _, p := range problems {
+					if ok, v := GradeAnswer(p.Problem, p.Answer, p.Answer); !ok {
+						t.Errorf("%s/%s: GradeAnswer(%q) rejected its own answer %q (%s: %s)", topic, difficulty, p.Problem, p.Answer, v.Status, v.Detail)
+					}
+				}
+			}
+		}
+	}
+}
+
+// Topics detected in generated problems must be ones GeneratePractice accepts.
+func TestDetectTopic(t *testing.T) {
+	en := NewEngine()
+	for _, topic := range Topics {
+		for _, difficulty := range []string{DifficultyEasy, DifficultyMedium, DifficultyHard} {
+			problems, err := en.GeneratePractice(topic, PracticeOptions{Difficulty: difficulty, Count: 10})
+			if err != nil {
+				t.Errorf("GeneratePractice(%s, %s): %v", topic, difficulty, err)
+				continue
+			}
+			for _, p := range problems {
+				if got := DetectTopic(p.Problem); got != topic {
+					t.Errorf("DetectTopic(%q) = %s, want %s", p.Problem, got, topic)
+				}
+			}
+		}
+	}
+	if got := DetectTopic("2x^3 - 3x = 1"); got != TopicPolynomial {
+		t.Errorf("DetectTopic(cubic) = %s, want %s", got, TopicPolynomial)
+	}
+	if _, err := en.GeneratePractice(DetectTopic("3x + 2x"), PracticeOptions{Difficulty: DifficultyEasy, Count: 1}); err != nil {
+		t.Errorf("GeneratePractice(simplify): %v", err)
+	}
+}
@@ -0,0 +1,635 @@
+package mathengine
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+// maxExactExponent bounds integer powers evaluated exactly, to keep big.Rat sizes sane.
+const maxExactExponent = 256
+
+// maxExpandTerms bounds the size of expanded products.
+const maxExpandTerms = 4096
+
+// maxExpandPower bounds the integer powers of sums that are multiplied out.
+const maxExpandPower = 16
+
+// Simplify returns the canonical form of e: numbers are folded, like terms
+// and like factors are collected, and terms are ordered by descending degree.
+// It never distributes products over sums; use Expand for that.
+func Simplify(e Expr) Expr {
+	switch e := e.(type) {
+	case *Add:
+		terms := make([]Expr, len(e.Terms))
+		for i, t := range e.Terms {
+			terms[i] = Simplify(t)
+		}
+		return simplifyAdd(terms)
+	case *Mul:
+		factors := make([]Expr, len(e.Factors))
+		for i, f := range e.Factors {
+			factors[i] = Simplify(f)
+		}
+		return simplifyMul(factors)
+	case *Pow:
+		return simplifyPow(Simplify(e.Base), Simplify(e.Exp))
+	case *Func:
+		return simplifyFunc(e.Name, Simplify(e.Arg))
+	}
+	return e
+}
+
+func simplifyAdd(terms []Expr) Expr {
+	var flat []Expr
+	for _, t := range terms {
+		if a, ok := t.(*Add); ok {
+			flat = append(flat, a.Terms...)
+		} else {
+			flat = append(flat, t)
+		}
+	}
+
+	constant := new(big.Rat)
+	coeffs := map[string]*big.Rat{}
+	rests := map[string]Expr{}
+	var order []string
+	for _, t := range flat {
+		coef, rest := splitCoeff(t)
+		if rest == nil {
+			constant.Add(constant, coef)
+			continue
+		}
+		key := rest.String()
+		if _, ok := coeffs[key]; !ok {
+			coeffs[key] = new(big.Rat)
+			rests[key] = rest
+			order = append(order, key)
+		}
+		coeffs[key].Add(coeffs[key], coef)
+	}
+
+	var result []Expr
+	for _, key := range order {
+		if coeffs[key].Sign() != 0 {
+			result = append(result, mulOf(coeffs[key], rests[key]))
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return termLess(result[i], result[j])
+	})
+	if constant.Sign() != 0 {
+		result = append(result, NewNum(constant))
+	}
+
+	switch len(result) {
+	case 0:
+		return NewInt(0)
+	case 1:
+		return result[0]
+	}
+	return &Add{Terms: result}
+}
+
+func simplifyMul(factors []Expr) Expr {
+	for round := 0; ; round++ {
+		coef := big.NewRat(1, 1)
+		exps := map[string][]Expr{}
+		bases := map[string]Expr{}
+		var order []string
+
+		for _, f := range flattenMul(factors) {
+			if r, ok := isNum(f); ok {
+				coef.Mul(coef, r)
+				continue
+			}
+			base, exp := f, Expr(NewInt(1))
+			if p, ok := f.(*Pow); ok {
+				base, exp = p.Base, p.Exp
+			}
+			key := base.String()
+			if _, ok := bases[key]; !ok {
+				bases[key] = base
+				order = append(order, key)
+			}
+			exps[key] = append(exps[key], exp)
+		}
+		// 0/0 is undefined, not 0: keep the division by zero for the caller to see
+		if coef.Sign() == 0 && !dividesByZero(factors) {
+			return NewInt(0)
+		}
+
+		var result []Expr
+		unstable := false
+		for _, key := range order {
+			exp := simplifyAdd(exps[key])
+			f := simplifyPow(bases[key], exp)
+			switch f.(type) {
+			case *Num, *Mul:
+				// Powers such as sqrt(8) = 2*sqrt(2) produce new numeric factors
+				unstable = true
+			}
+			if !isOne(f) {
+				result = append(result, f)
+			}
+		}
+		if unstable && round < 4 {
+			factors = append([]Expr{NewNum(coef)}, result...)
+			continue
+		}
+
+		sort.SliceStable(result, func(i, j int) bool {
+			return factorLess(result[i], result[j])
+		})
+		if coef.Cmp(one) != 0 || len(result) == 0 {
+			result = append([]Expr{NewNum(coef)}, result...)
+		}
+		if len(result) == 1 {
+			return result[0]
+		}
+		return &Mul{Factors: result}
+	}
+}
+
+// dividesByZero reports whether any of factors is a negative power of zero.
+func dividesByZero(factors []Expr) bool {
+	for _, f := range flattenMul(factors) {
+		if p, ok := f.(*Pow); ok && isZero(p.Base) {
+			if e, ok := isNum(p.Exp); ok && e.Sign() < 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkDefined returns ErrUndefined when e divides by zero anywhere, such as
+// 1/0, 0/0 or 1/(2 - 2), even where simplifying would cancel the division,
+// or takes an even root or a function of a constant with no real value.
+func checkDefined(e Expr) error {
+	switch e := e.(type) {
+	case *Add:
+		for _, t := range e.Terms {
+			if err := checkDefined(t); err != nil {
+				return err
+			}
+		}
+	case *Mul:
+		for _, f := range e.Factors {
+			if err := checkFactor(f, e); err != nil {
+				return err
+			}
+		}
+	case *Pow:
+		return checkFactor(e, e)
+	case *Func:
+		if err := checkDefined(e.Arg); err != nil {
+			return err
+		}
+		return checkDomain(e)
+	}
+	return nil
+}
+
+// checkFactor is checkDefined for a factor f of product. A division by zero
+// is reported as the whole product, so that 0/0 reads as 0/0 and not 1/0.
+func checkFactor(f, product Expr) error {
+	p, ok := f.(*Pow)
+	if !ok {
+		return checkDefined(f)
+	}
+	if err := checkDefined(p.Base); err != nil {
+		return err
+	}
+	if err := checkDefined(p.Exp); err != nil {
+		return err
+	}
+	exp, ok := isNum(Simplify(p.Exp))
+	if ok && exp.Sign() < 0 && isZero(Simplify(p.Base)) {
+		return fmt.Errorf("%w: division by zero in %s", ErrUndefined, product.String())
+	}
+	// Even roots of negative numbers are not real
+	if ok && exp.Denom().Bit(0) == 0 && constantSign(p.Base) < 0 {
+		return fmt.Errorf("%w: %s is not a real number", ErrUndefined, p.String())
+	}
+	return nil
+}
+
+// checkDomain returns ErrUndefined when f is applied to a constant outside
+// its real domain: a logarithm of zero or a negative number, tan or sec at
+// an odd multiple of pi/2, cot or csc at a multiple of pi, or asin or acos
+// beyond [-1, 1]. Arguments with variables are not checked.
+func checkDomain(f *Func) error {
+	if len(FreeSymbols(f.Arg)) > 0 {
+		return nil
+	}
+	var undefined bool
+	switch f.Name {
+	case "ln", "log":
+		undefined = constantSign(f.Arg) <= 0
+	case "tan", "sec":
+		undefined = multipleOfPi(Sub(f.Arg, Div(NewSym("pi"), NewInt(2))))
+	case "cot", "csc":
+		undefined = multipleOfPi(f.Arg)
+	case "asin", "acos":
+		x, err := EvalBig(f.Arg, nil, DefaultPrecision)
+		undefined = err == nil && x.Abs(x).Cmp(big.NewFloat(1)) > 0
+	}
+	if undefined {
+		return fmt.Errorf("%w: %s is not a real number", ErrUndefined, f.String())
+	}
+	return nil
+}
+
+// constantSign returns the sign of the constant e, or 1 when e cannot be
+// evaluated.
+func constantSign(e Expr) int {
+	if len(FreeSymbols(e)) > 0 {
+		return 1
+	}
+	if isZero(Simplify(e)) {
+		return 0
+	}
+	x, err := EvalBig(e, nil, DefaultPrecision)
+	if err != nil {
+		return 1
+	}
+	return x.Sign()
+}
+
+// multipleOfPi reports whether e is an integer multiple of pi.
+func multipleOfPi(e Expr) bool {
+	_, ok := isInt(Simplify(Div(e, NewSym("pi"))))
+	return ok
+}
+
+func flattenMul(factors []Expr) []Expr {
+	var flat []Expr
+	for _, f := range factors {
+		if m, ok := f.(*Mul); ok {
+			flat = append(flat, flattenMul(m.Factors)...)
+		} else {
+			flat = append(flat, f)
+		}
+	}
+	return flat
+}
+
+func simplifyPow(base, exp Expr) Expr {
+	if isZero(exp) {
+		return NewInt(1)
+	}
+	if isOne(exp) {
+		return base
+	}
+	if isOne(base) {
+		return NewInt(1)
+	}
+
+	if b, ok := isNum(base); ok {
+		if e, ok := isNum(exp); ok {
+			if v := powRat(b, e); v != nil {
+				return v
+			}
+		}
+	}
+
+	if s, ok := base.(*Sym); ok && s.Name == "e" {
+		// e^(ln(x)) = x
+		if f, ok := exp.(*Func); ok && f.Name == "ln" {
+			return f.Arg
+		}
+	}
+
+	if n, ok := isInt(exp); ok {
+		switch b := base.(type) {
+		case *Pow:
+			// (a^m)^n = a^(m*n) holds for integer n
+			return simplifyPow(b.Base, simplifyMul([]Expr{b.Exp, NewNum(new(big.Rat).SetInt(n))}))
+		case *Mul:
+			factors := make([]Expr, len(b.Factors))
+			for i, f := range b.Factors {
+				factors[i] = simplifyPow(f, exp)
+			}
+			return simplifyMul(factors)
+		}
+	}
+
+	return &Pow{Base: base, Exp: exp}
+}
+
+// powRat evaluates b^e exactly when the result is rational or a simplified
+// surd; it returns nil when the power should stay symbolic.
+func powRat(b, e *big.Rat) Expr {
+	if b.Sign() == 0 {
+		if e.Sign() > 0 {
+			return NewInt(0)
+		}
+		return nil
+	}
+
+	if e.IsInt() {
+		n := e.Num()
+		if !n.IsInt64() || absInt64(n.Int64()) > maxExactExponent {
+			return nil
+		}
+		return NewNum(ratPowInt(b, n.Int64()))
+	}
+
+	// Split e = whole + r/q with 0 < r < q
+	q := e.Denom().Int64()
+	if !e.Denom().IsInt64() || q > 64 {
+		return nil
+	}
+	whole := new(big.Int).Div(e.Num(), e.Denom()) // floor division for positive denominators
+	r := new(big.Int).Sub(e.Num(), new(big.Int).Mul(whole, e.Denom())).Int64()
+	if !whole.IsInt64() || absInt64(whole.Int64()) > maxExactExponent {
+		return nil
+	}
+
+	sign := 1
+	abs := new(big.Rat).Abs(b)
+	if b.Sign() < 0 {
+		if q%2 == 0 {
+			return nil
+		}
+		if r%2 != 0 {
+			sign = -1
+		}
+	}
+
+	// (a/d)^(r/q) = (a^r * d^(r(q-1)))^(1/q) / d^r, then pull out perfect q-th powers
+	a, d := abs.Num(), abs.Denom()
+	radicand := new(big.Int).Exp(a, big.NewInt(r), nil)
+	radicand.Mul(radicand, new(big.Int).Exp(d, big.NewInt(r*(q-1)), nil))
+	if radicand.BitLen() > 256 {
+		return nil
+	}
+	outside, inside := extractPower(radicand, q)
+
+	coef := new(big.Rat).SetInt(outside)
+	coef.Quo(coef, new(big.Rat).SetInt(new(big.Int).Exp(d, big.NewInt(r), nil)))
+	coef.Mul(coef, ratPowInt(b, whole.Int64()))
+	if sign < 0 {
+		coef.Neg(coef)
+	}
+	if inside.Cmp(big.NewInt(1)) == 0 {
+		return NewNum(coef)
+	}
+
+	root := &Pow{Base: NewNum(new(big.Rat).SetInt(inside)), Exp: NewRat(1, q)}
+	if coef.Cmp(one) == 0 {
+		return root
+	}
+	return &Mul{Factors: []Expr{NewNum(coef), root}}
+}
+
+func ratPowInt(b *big.Rat, n int64) *big.Rat {
+	neg := n < 0
+	if neg {
+		n = -n
+	}
+	num := new(big.Int).Exp(b.Num(), big.NewInt(n), nil)
+	den := new(big.Int).Exp(b.Denom(), big.NewInt(n), nil)
+	result := new(big.Rat).SetFrac(num, den)
+	if neg {
+		result.Inv(result)
+	}
+	return result
+}
+
+// extractPower writes n = outside^q * inside with inside free of q-th powers
+// of small primes.
+func extractPower(n *big.Int, q int64) (*big.Int, *big.Int) {
+	outside := big.NewInt(1)
+	inside := new(big.Int).Set(n)
+	for p := int64(2); p < 10000; p++ {
+		pq := new(big.Int).Exp(big.NewInt(p), big.NewInt(q), nil)
+		if pq.Cmp(inside) > 0 {
+			break
+		}
+		for new(big.Int).Rem(inside, pq).Sign() == 0 {
+			inside.Quo(inside, pq)
+			outside.Mul(outside, big.NewInt(p))
+		}
+	}
+	return outside, inside
+}
+
+func absInt64(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func simplifyFunc(name string, arg Expr) Expr {
+	if r, ok := isNum(arg); ok {
+		switch {
+		case r.Sign() == 0:
+			switch name {
+			case "sin", "tan", "asin", "atan", "sinh", "tanh":
+				return NewInt(0)
+			case "cos", "cosh":
+				return NewInt(1)
+			}
+		case r.Cmp(one) == 0:
+			switch name {
+			case "ln", "log":
+				return NewInt(0)
+			}
+		}
+		if name == "abs" {
+			return NewNum(new(big.Rat).Abs(r))
+		}
+		if name == "log" && r.Cmp(big.NewRat(10, 1)) == 0 {
+			return NewInt(1)
+		}
+	}
+	if s, ok := arg.(*Sym); ok {
+		switch {
+		case s.Name == "pi" && name == "sin", s.Name == "pi" && name == "tan":
+			return NewInt(0)
+		case s.Name == "pi" && name == "cos":
+			return NewInt(-1)
+		case s.Name == "e" && name == "ln":
+			return NewInt(1)
+		}
+	}
+	if name == "ln" {
+		// ln(e^x) = x
+		if p, ok := arg.(*Pow); ok {
+			if s, ok := p.Base.(*Sym); ok && s.Name == "e" {
+				return p.Exp
+			}
+		}
+	}
+	return &Func{Name: name, Arg: arg}
+}
+
+// termLess orders terms by descending degree, then lexically.
+func termLess(a, b Expr) bool {
+	da, db := degree(a), degree(b)
+	if da.Cmp(db) != 0 {
+		return da.Cmp(db) > 0
+	}
+	_, ra := splitCoeff(a)
+	_, rb := splitCoeff(b)
+	return ra.String() < rb.String()
+}
+
+// degree is the total degree of a term in its variables; non-polynomial
+// factors count as zero.
+func degree(e Expr) *big.Rat {
+	switch e := e.(type) {
+	case *Sym:
+		if isConstant(e.Name) {
+			return new(big.Rat)
+		}
+		return big.NewRat(1, 1)
+	case *Pow:
+		if r, ok := isNum(e.Exp); ok {
+			return new(big.Rat).Mul(degree(e.Base), r)
+		}
+	case *Mul:
+		total := new(big.Rat)
+		for _, f := range e.Factors {
+			total.Add(total, degree(f))
+		}
+		return total
+	}
+	return new(big.Rat)
+}
+
+func factorRank(e Expr) int {
+	switch e := e.(type) {
+	case *Num:
+		return 0
+	case *Sym:
+		if isConstant(e.Name) {
+			return 1
+		}
+		return 2
+	case *Pow:
+		if _, ok := e.Base.(*Num); ok {
+			return 1
+		}
+		r := factorRank(e.Base)
+		if r == 0 {
+			return 1
+		}
+		return r
+	case *Func:
+		return 3
+	}
+	return 4
+}
+
+func factorLess(a, b Expr) bool {
+	ra, rb := factorRank(a), factorRank(b)
+	if ra != rb {
+		return ra < rb
+	}
+	return baseOf(a).String() < baseOf(b).String()
+}
+
+func baseOf(e Expr) Expr {
+	if p, ok := e.(*Pow); ok {
+		return p.Base
+	}
+	return e
+}
+
+// Expand distributes products over sums and expands small integer powers of
+// sums, then simplifies. Products and powers too large to expand are left
+// as they are.
+func Expand(e Expr) Expr {
+	result, _ := expandWithin(e)
+	return result
+}
+
+// ExpandAll is Expand that fails with ErrUnsupported instead of leaving a
+// product or power unexpanded.
+func ExpandAll(e Expr) (Expr, error) {
+	result, complete := expandWithin(e)
+	if !complete {
+		return nil, fmt.Errorf("%w: expansions beyond power %d or %d terms", ErrUnsupported, maxExpandPower, maxExpandTerms)
+	}
+	return result, nil
+}
+
+// expandWithin expands e and reports whether every product and power could
+// be expanded within the limits.
+func expandWithin(e Expr) (Expr, bool) {
+	complete := true
+	return Simplify(expand(Simplify(e), &complete)), complete
+}
+
+// expand clears *complete when it leaves a product or power unexpanded.
+func expand(e Expr, complete *bool) Expr {
+	switch e := e.(type) {
+	case *Add:
+		terms := make([]Expr, len(e.Terms))
+		for i, t := range e.Terms {
+			terms[i] = expand(t, complete)
+		}
+		return simplifyAdd(terms)
+	case *Mul:
+		product := []Expr{NewInt(1)}
+		for _, f := range e.Factors {
+			f = expand(f, complete)
+			var next []Expr
+			for _, a := range product {
+				for _, b := range termsOf(f) {
+					next = append(next, simplifyMul([]Expr{a, b}))
+				}
+			}
+			if len(next) > maxExpandTerms {
+				*complete = false
+				return e
+			}
+			// Collect like terms as we go, so powers of sums stay small
+			product = termsOf(simplifyAdd(next))
+		}
+		return simplifyAdd(product)
+	case *Pow:
+		base := expand(e.Base, complete)
+		n, ok := isInt(e.Exp)
+		if _, isSum := base.(*Add); ok && isSum {
+			if !n.IsInt64() || absInt64(n.Int64()) > maxExpandPower {
+				*complete = false
+				return simplifyPow(base, e.Exp)
+			}
+			k := n.Int64()
+			factors := make([]Expr, absInt64(k))
+			for i := range factors {
+				factors[i] = base
+			}
+			expanded := expand(&Mul{Factors: factors}, complete)
+			if k < 0 {
+				return simplifyPow(expanded, NewInt(-1))
+			}
+			return expanded
+		}
+		return simplifyPow(base, expand(e.Exp, complete))
+	case *Func:
+		return simplifyFunc(e.Name, expand(e.Arg, complete))
+	}
+	return e
+}
+
+func termsOf(e Expr) []Expr {
+	if a, ok := e.(*Add); ok {
+		return a.Terms
+	}
+	return []Expr{e}
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,156 @@
+package mathengine
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSimplify(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"1 + 2*3", "7"},
+		{"1/2 + 1/3", "5/6"},
+		{"x + x", "2*x"},
+		{"x*x*x", "x^3"},
+		{"2*x + 3 - x - 3", "x"},
+		{"x^2*x^-2", "1"},
+		{"sqrt(8)", "2*sqrt(2)"},
+		{"0*x", "0"},
+		{"(x^2)^3", "x^6"},
+		{"e^(ln(y))", "y"},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.input)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.input, err)
+		}
+		if got := Simplify(e).String(); got != tt.want {
+			t.Errorf("Simplify(%q) = %s, want %s", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestSimplifyKeepsDivisionByZero(t *testing.T) {
+	for _, input := range []string{"0/0", "0*x/0"} {
+		e, err := Parse(input)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", input, err)
+		}
+		if got := Simplify(e); isZero(got) {
+			t.Errorf("Simplify(%q) = 0, want the division by zero kept", input)
+		}
+	}
+}
+
+func TestSolve(t *testing.T) {
+	tests := []struct {
+		input string
+		final string
+	}{
+		{"2 + 3*4", "14"},
+		{"1/4 - 9/4", "-2"},
+		{"x + 1 = 3", "x = 2"},
+		{"x^2 = 4", "x = -2 or x = 2"},
+		{"expand (x + 1)^2", "x^2 + 2*x + 1"},
+		{"diff x^3", "3*x^2"},
+	}
+	en := NewEngine()
+	for _, tt := range tests {
+		result, err := en.Solve(tt.input)
+		if err != nil {
+			t.Errorf("Solve(%q): %v", tt.input, err)
+			continue
+		}
+		if result.Final != tt.final {
+			t.Errorf("Solve(%q) = %s, want %s", tt.input, result.Final, tt.final)
+		}
+	}
+}
+
+func TestSolveDivisionByZero(t *testing.T) {
+	tests := []struct {
+		input    string
+		division string // The division reported
+	}{
+		{"1/0", "1/0"},
+		{"0/0", "0/0"},
+		{"1/(2 - 2)", "1/(2 - 2)"},
+		{"1/0 - 1/0", "1/0"},
+		{"x/0 = 1", "x/0"},
+		{"(x + 1)/0", "(x + 1)/0"},
+		{"3^(1/(1 - 1))", "1/(1 - 1)"},
+	}
+	en := NewEngine()
+	for _, tt := range tests {
+		_, err := en.Solve(tt.input)
+		if !errors.Is(err, ErrUndefined) {
+			t.Errorf("Solve(%q) error = %v, want ErrUndefined", tt.input, err)
+			continue
+		}
+		if want := "division by zero in " + tt.division; !strings.HasSuffix(err.Error(), want) {
+			t.Errorf("Solve(%q) error = %q, want it to end in %q", tt.input, err, want)
+		}
+	}
+}
+
+func TestSolveExpandLimits(t *testing.T) {
+	tests := []struct {
+		input   string
+		wantErr bool
+	}{
+		{"expand (x + 1)^16", false},
+		{"expand (x + 1)^20", true},
+		{"expand (x + 1)^(-20)", true},
+		{"expand (a + b + c + d)^7", false},
+		{"expand (a + b + c + d + f + g + h + k)^16", true},
+		{"expand x*(x + 1)^20", true},
+	}
+	en := NewEngine()
+	for _, tt := range tests {
+		result, err := en.Solve(tt.input)
+		if tt.wantErr {
+			if !errors.Is(err, ErrUnsupported) {
+				t.Errorf("Solve(%q) = %v, %v; want ErrUnsupported", tt.input, result, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Solve(%q): %v", tt.input, err)
+		}
+	}
+}
+
+func TestSolveOutsideDomain(t *testing.T) {
+	tests := []struct {
+		input     string
+		undefined bool
+	}{
+		{"ln(0)", true},
+		{"ln(1 - 1)", true},
+		{"log(-2)", true},
+		{"ln(x) = 0", false},
+		{"ln(1)", false},
+		{"sqrt(-1)", true},
+		{"sqrt(2 - 3)", true},
+		{"(-4)^(1/2)", true},
+		{"root(-16, 4)", true},
+		{"root(-8, 3)", false},
+		{"sqrt(4)", false},
+		{"tan(pi/2)", true},
+		{"tan(3pi/2)", true},
+		{"tan(pi/4)", false},
+		{"cot(0)", true},
+		{"asin(2)", true},
+		{"x + ln(0) = 1", true},
+	}
+	en := NewEngine()
+	for _, tt := range tests {
+		_, err := en.Solve(tt.input)
+		if undefined := errors.Is(err, ErrUndefined); undefined != tt.undefined {
+			t.Errorf("Solve(%q) error = %v, want undefined = %v", tt.input, err, tt.undefined)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package mathengine
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// solveEquation solves left = right for its single variable, recording
+// steps. variable may name the unknown explicitly; it must then be the only
+// variable in the equation.
+func (s *session) solveEquation(left, right Expr, variable string) error {
+	s.step(left.Latex() + " = " + right.Latex())
+
+	diff := Expand(Sub(left, right))
+	vars := FreeSymbols(diff)
+	switch {
+	case variable != "" && !Contains(diff, variable):
+		return fmt.Errorf("%w: %s does not appear in the equation", ErrUnsupported, variable)
+	case len(vars) == 0:
+		return s.checkIdentity(diff)
+	case len(vars) > 1:
+		return fmt.Errorf("%w: equations in several variables (%s)", ErrUnsupported, strings.Join(vars, ", "))
+	}
+
+	if err := s.cancelled(); err != nil {
+		return err
+	}
+
+	v := vars[0]
+	poly, ok := ToPoly(diff, v)
+	if !ok {
+		return fmt.Errorf("%w: %s is not polynomial in %s", ErrUnsupported, diff.String(), v)
+	}
+	if standard := poly.Expr().Latex() + " = 0"; standard != s.steps[len(s.steps)-1].Latex {
+		s.step(standard)
+	}
+
+	switch poly.Degree() {
+	case -1, 0:
+		return s.checkIdentity(poly.Expr())
+	case 1:
+		s.solveLinear(poly)
+		return nil
+	case 2:
+		s.solveQuadratic(poly)
+		return nil
+	}
+	return s.solveByRationalRoots(poly)
+}
+
+// checkIdentity handles equations with no variable left after simplification.
+func (s *session) checkIdentity(diff Expr) error {
+	if isZero(diff) {
+		s.step(`0 = 0 \quad \text{(always true)}`)
+		s.final = "all real numbers"
+		return nil
+	}
+	if _, ok := isNum(diff); ok {
+		s.step(diff.Latex() + ` = 0 \quad \text{(never true)}`)
+		s.final = "no solution"
+		return nil
+	}
+	return fmt.Errorf("%w: cannot decide %s = 0", ErrUnsupported, diff.String())
+}
+
+func (s *session) solveLinear(p *Poly) {
+	x := NewSym(p.Var)
+	a, b := p.Coeff(1), p.Coeff(0)
+	rhs := new(big.Rat).Neg(b)
+	if b.Sign() != 0 {
+		s.step(simplifyMul([]Expr{NewNum(a), x}).Latex() + " = " + latexRat(rhs))
+	}
+	root := new(big.Rat).Quo(rhs, a)
+	s.step(x.Latex() + " = " + latexRat(root))
+	s.roots(p.Var, []Expr{NewNum(root)})
+}
+
+func (s *session) solveQuadratic(p *Poly) {
+	a, b, c := p.Coeff(2), p.Coeff(1), p.Coeff(0)
+	x := NewSym(p.Var).Latex()
+
+	// Prefer factoring when the roots are rational
+	roots, _ := p.RationalRoots()
+	if len(roots) == 2 {
+		s.step(Factor(p.Expr()).Latex() + " = 0")
+		if roots[0].Cmp(roots[1]) == 0 {
+			s.step(x + " = " + latexRat(roots[0]))
+			s.roots(p.Var, []Expr{NewNum(roots[0])})
+			return
+		}
+		s.step(x + " = " + latexRat(roots[0]) + `\quad \text{or} \quad ` + x + " = " + latexRat(roots[1]))
+		s.roots(p.Var, []Expr{NewNum(roots[0]), NewNum(roots[1])})
+		return
+	}
+
+	s.step(`a = ` + latexRat(a) + `,\; b = ` + latexRat(b) + `,\; c = ` + latexRat(c))
+	disc := new(big.Rat).Mul(b, b)
+	disc.Sub(disc, new(big.Rat).Mul(big.NewRat(4, 1), new(big.Rat).Mul(a, c)))
+	s.step(`\Delta = b^{2} - 4ac = ` + latexRat(disc))
+
+	if disc.Sign() < 0 {
+		s.step(`\Delta < 0 \Rightarrow \text{no real solutions}`)
+		s.final = "no real solution"
+		return
+	}
+
+	twoA := new(big.Rat).Mul(big.NewRat(2, 1), a)
+	negB := new(big.Rat).Neg(b)
+	s.step(x + ` = \frac{-b \pm \sqrt{\Delta}}{2a} = \frac{` + latexRat(negB) + ` \pm ` + Sqrt(NewNum(disc)).Latex() + `}{` + latexRat(twoA) + `}`)
+
+	sqrtDisc := Simplify(Sqrt(NewNum(disc)))
+	var results []Expr
+	for _, sign := range []int64{-1, 1} {
+		numerator := simplifyAdd([]Expr{NewNum(negB), simplifyMul([]Expr{NewInt(sign), sqrtDisc})})
+		results = append(results, niceQuotient(numerator, twoA))
+	}
+
+	parts := make([]string, len(results))
+	for i, r := range results {
+		approx, _ := s.approx(r)
+		parts[i] = fmt.Sprintf(`%s_{%d} = %s \approx %s`, x, i+1, r.Latex(), approx)
+	}
+	s.step(strings.Join(parts, `,\quad `))
+	s.roots(p.Var, results)
+}
+
+// niceQuotient divides numerator by d, distributing the division when every
+// resulting coefficient is an integer, e.g. (-2 + 2*sqrt(2))/2 = -1 + sqrt(2).
+func niceQuotient(numerator Expr, d *big.Rat) Expr {
+	inv := new(big.Rat).Inv(d)
+	expanded := Expand(simplifyMul([]Expr{NewNum(inv), numerator}))
+	for _, t := range termsOf(expanded) {
+		if c, _ := splitCoeff(t); !c.IsInt() {
+			return simplifyMul([]Expr{NewNum(inv), numerator})
+		}
+	}
+	return expanded
+}
+
+func (s *session) solveByRationalRoots(p *Poly) error {
+	roots, rest := p.RationalRoots()
+	if err := s.cancelled(); err != nil {
+		return err
+	}
+	if len(roots) == 0 || rest.Degree() > 2 {
+		return fmt.Errorf("%w: polynomial equations of degree %d without enough rational roots", ErrUnsupported, p.Degree())
+	}
+	s.step(Factor(p.Expr()).Latex() + " = 0")
+
+	var results []Expr
+	for _, r := range roots {
+		results = append(results, NewNum(r))
+	}
+	if rest.Degree() == 2 {
+		sub := &session{ctx: s.ctx, digits: s.digits}
+		sub.solveQuadratic(rest)
+		for _, st := range sub.steps {
+			s.step(st.Latex)
+		}
+		results = append(results, sub.rootExprs...)
+	}
+	s.roots(p.Var, results)
+	return nil
+}
+
+// roots records the final answer, deduplicated and in ascending order.
+func (s *session) roots(v string, values []Expr) {
+	seen := map[string]bool{}
+	var unique []Expr
+	for _, r := range values {
+		if !seen[r.String()] {
+			seen[r.String()] = true
+			unique = append(unique, r)
+		}
+	}
+	sortByValue(unique)
+	s.rootExprs = unique
+
+	parts := make([]string, len(unique))
+	for i, r := range unique {
+		parts[i] = v + " = " + r.String()
+		// Problems written with decimals get decimal answers
+		if decimal, ok := s.approx(r); ok && s.decimal {
+			parts[i] = v + " = " + decimal
+		}
+	}
+	s.final = strings.Join(parts, " or ")
+	if len(unique) == 0 {
+		s.final = "no real solution"
+	}
+}
+
+func sortByValue(values []Expr) {
+	for i := 1; i < len(values); i++ {
+		for j := i; j > 0; j-- {
+			a, errA := Eval(values[j-1], nil)
+			b, errB := Eval(values[j], nil)
+			if errA != nil || errB != nil || a <= b {
+				break
+			}
+			values[j-1], values[j] = values[j], values[j-1]
+		}
+	}
+}
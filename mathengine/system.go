@@ -0,0 +1,265 @@
+package mathengine
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"maths-solution-backend/parser"
+)
+
+// Rule names reported on linear algebra steps.
+const (
+	RuleAugmentedMatrix = "Augmented matrix"
+	RuleSwapRows        = "Swap rows"
+	RuleScaleRow        = "Scale row"
+	RuleEliminate       = "Eliminate"
+	RuleReadSolution    = "Read off solution"
+	RuleRank            = "Rank"
+	RuleDeterminant     = "Determinant"
+	RuleInverse         = "Inverse"
+	RuleCharacteristic  = "Characteristic polynomial"
+	RuleEigenvalues     = "Eigenvalues"
+)
+
+// SystemResult is a worked linear system or matrix analysis. Determinant is
+// nil unless the (coefficient) matrix is square, Inverse unless it is also
+// invertible.
+type SystemResult struct {
+	Result
+	Variables   []string
+	Rank        int
+	Determinant *big.Rat
+	Inverse     Matrix
+	Eigenvalues []Expr
+}
+
+// SolveSystem solves a system of linear equations in canonical ASCII by
+// Gauss-Jordan elimination on its augmented matrix. Malformed equations
+// yield a *parser.Error; non-linear ones ErrUnsupported.
+func (en *Engine) SolveSystem(equations []string) (*SystemResult, error) {
+	if len(equations) == 0 {
+		return nil, fmt.Errorf("system has no equations")
+	}
+	if len(equations) > maxMatrixSize {
+		return nil, fmt.Errorf("%w: systems of more than %d equations", ErrUnsupported, maxMatrixSize)
+	}
+
+	rows := make([]linearForm, len(equations))
+	seen := map[string]bool{}
+	latex := make([]string, len(equations))
+	for i, eq := range equations {
+		left, right, err := parseEquation(eq)
+		if err != nil {
+			return nil, err
+		}
+		latex[i] = left.Latex() + " = " + right.Latex()
+		if rows[i], err = linearFormOf(Sub(left, right)); err != nil {
+			return nil, err
+		}
+		for v := range rows[i].coeffs {
+			seen[v] = true
+		}
+	}
+	vars := sortedKeys(seen)
+	if len(vars) == 0 {
+		return nil, fmt.Errorf("%w: system has no variables", ErrUnsupported)
+	}
+	if len(vars) > maxMatrixSize {
+		return nil, fmt.Errorf("%w: systems in more than %d variables", ErrUnsupported, maxMatrixSize)
+	}
+
+	// Each row is a_1 x_1 + ... + a_n x_n | b with b = -constant
+	augmented := make(Matrix, len(rows))
+	for i, row := range rows {
+		augmented[i] = make([]*big.Rat, len(vars)+1)
+		for j, v := range vars {
+			augmented[i][j] = new(big.Rat)
+			if c, ok := row.coeffs[v]; ok {
+				augmented[i][j].Set(c)
+			}
+		}
+		augmented[i][len(vars)] = new(big.Rat).Neg(row.constant)
+	}
+
+	s := &session{}
+	s.step(`\begin{cases} ` + strings.Join(latex, ` \\ `) + ` \end{cases}`)
+	s.ruleStep(RuleAugmentedMatrix, augmented.Latex(1))
+	reduced, pivots, det := s.rowReduce(augmented, 1)
+	s.readSolution(reduced, pivots, vars)
+
+	result := &SystemResult{Variables: vars, Rank: len(pivots)}
+	coefficients := make(Matrix, len(augmented))
+	for i := range augmented {
+		coefficients[i] = augmented[i][:len(vars)]
+	}
+	if coefficients.IsSquare() {
+		result.Determinant = det
+		s.ruleStep(RuleDeterminant, `\det(A) = `+latexRat(det))
+		if det.Sign() != 0 {
+			result.Inverse, _ = coefficients.Inverse()
+			s.ruleStep(RuleInverse, `A^{-1} = `+result.Inverse.Latex(0))
+		}
+	}
+	result.Result = Result{Steps: s.steps, Final: s.final}
+	return result, nil
+}
+
+// AnalyzeMatrix row-reduces m and reports its rank and, when square, its
+// determinant, inverse and (up to 4x4) exact eigenvalues.
+func (en *Engine) AnalyzeMatrix(m Matrix) (*SystemResult, error) {
+	s := &session{}
+	s.step(`A = ` + m.Latex(0))
+	_, pivots, det := s.rowReduce(m, 0)
+
+	result := &SystemResult{Rank: len(pivots)}
+	s.ruleStep(RuleRank, fmt.Sprintf(`\operatorname{rank}(A) = %d`, result.Rank))
+	final := []string{fmt.Sprintf("rank(A) = %d", result.Rank)}
+
+	if m.IsSquare() {
+		result.Determinant = det
+		s.ruleStep(RuleDeterminant, `\det(A) = `+latexRat(det))
+		final = append(final, "det(A) = "+det.RatString())
+		if det.Sign() != 0 {
+			result.Inverse, _ = m.Inverse()
+			s.ruleStep(RuleInverse, `A^{-1} = `+result.Inverse.Latex(0))
+		}
+		if m.Rows() <= maxEigenSize {
+			result.Eigenvalues = s.eigenvalues(m)
+			if len(result.Eigenvalues) > 0 {
+				final = append(final, "eigenvalues: "+joinExprs(result.Eigenvalues, ", ", Expr.String))
+			}
+		}
+	}
+
+	s.final = strings.Join(final, "; ")
+	result.Result = Result{Steps: s.steps, Final: s.final}
+	return result, nil
+}
+
+// eigenvalues records the characteristic polynomial and its exact roots.
+func (s *session) eigenvalues(m Matrix) []Expr {
+	p := m.CharacteristicPolynomial()
+	s.ruleStep(RuleCharacteristic, `\det(\lambda I - A) = `+p.Expr().Latex())
+	values, unresolved := m.Eigenvalues()
+	if len(values) > 0 {
+		s.ruleStep(RuleEigenvalues, `\lambda = `+joinExprs(values, `,\; `, Expr.Latex))
+	}
+	if unresolved != nil {
+		s.ruleStep(RuleEigenvalues, unresolved.Expr().Latex()+` = 0 \quad \text{(no exact roots found)}`)
+	}
+	return values
+}
+
+// readSolution interprets the reduced augmented matrix: an inconsistent
+// row means no solution, a pivot in every column a unique one, and free
+// columns a parametric family.
+func (s *session) readSolution(reduced Matrix, pivots []int, vars []string) {
+	n := len(vars)
+	for i := len(pivots); i < reduced.Rows(); i++ {
+		if reduced[i][n].Sign() != 0 {
+			s.ruleStep(RuleReadSolution, `0 = `+latexRat(reduced[i][n])+` \quad \text{(inconsistent)}`)
+			s.final = "no solution"
+			return
+		}
+	}
+
+	isPivot := map[int]bool{}
+	for _, col := range pivots {
+		isPivot[col] = true
+	}
+	var free []string
+	for j, v := range vars {
+		if !isPivot[j] {
+			free = append(free, v)
+		}
+	}
+
+	var latex, ascii []string
+	for i, col := range pivots {
+		terms := []Expr{NewNum(reduced[i][n])}
+		for j, v := range vars {
+			if !isPivot[j] && reduced[i][j].Sign() != 0 {
+				terms = append(terms, simplifyMul([]Expr{NewNum(new(big.Rat).Neg(reduced[i][j])), NewSym(v)}))
+			}
+		}
+		value := Simplify(&Add{Terms: terms})
+		latex = append(latex, NewSym(vars[col]).Latex()+" = "+value.Latex())
+		ascii = append(ascii, vars[col]+" = "+value.String())
+	}
+
+	if len(free) == 0 {
+		s.ruleStep(RuleReadSolution, strings.Join(latex, `,\; `))
+		s.final = strings.Join(ascii, ", ")
+		return
+	}
+	s.ruleStep(RuleReadSolution, strings.Join(latex, `,\; `)+` \quad \text{(`+strings.Join(free, ", ")+` free)}`)
+	s.final = "infinitely many solutions: " + strings.Join(ascii, ", ") + " (" + strings.Join(free, ", ") + " free)"
+}
+
+// linearForm is Σ coeffs[v]·v + constant.
+type linearForm struct {
+	coeffs   map[string]*big.Rat
+	constant *big.Rat
+}
+
+func linearFormOf(e Expr) (linearForm, error) {
+	form := linearForm{coeffs: map[string]*big.Rat{}, constant: new(big.Rat)}
+	expanded := Expand(e)
+	for _, term := range termsOf(expanded) {
+		coef, rest := splitCoeff(term)
+		switch rest := rest.(type) {
+		case nil:
+			form.constant.Add(form.constant, coef)
+		case *Sym:
+			if isConstant(rest.Name) {
+				return form, fmt.Errorf("%w: irrational coefficient in %s", ErrUnsupported, expanded.String())
+			}
+			if form.coeffs[rest.Name] == nil {
+				form.coeffs[rest.Name] = new(big.Rat)
+			}
+			form.coeffs[rest.Name].Add(form.coeffs[rest.Name], coef)
+		default:
+			return form, fmt.Errorf("%w: %s is not linear", ErrUnsupported, expanded.String())
+		}
+	}
+	for v, c := range form.coeffs {
+		if c.Sign() == 0 {
+			delete(form.coeffs, v)
+		}
+	}
+	return form, nil
+}
+
+// parseEquation reads "left = right"; a bare expression means expr = 0.
+func parseEquation(input string) (Expr, Expr, error) {
+	node, err := parser.Parse(input)
+	if err != nil {
+		return nil, nil, err
+	}
+	rel, ok := node.(*parser.Relation)
+	if !ok {
+		e, err := FromNode(node)
+		return e, NewInt(0), err
+	}
+	if rel.Op != "=" {
+		return nil, nil, fmt.Errorf("%w: inequalities in a system", ErrUnsupported)
+	}
+	left, err := FromNode(rel.Left)
+	if err != nil {
+		return nil, nil, err
+	}
+	right, err := FromNode(rel.Right)
+	if err != nil {
+		return nil, nil, err
+	}
+	return left, right, nil
+}
+
+func joinExprs(es []Expr, sep string, format func(Expr) string) string {
+	parts := make([]string, len(es))
+	for i, e := range es {
+		parts[i] = format(e)
+	}
+	return strings.Join(parts, sep)
+}
@@ -0,0 +1,386 @@
+package mathengine
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"maths-solution-backend/models"
+	"maths-solution-backend/parser"
+)
+
+const (
+	MethodSymbolic = "symbolic"
+	MethodNumeric  = "numeric"
+)
+
+// relativeTolerance bounds numeric residuals of answers given exactly.
+const relativeTolerance = 1e-9
+
+// samplePoints are the deterministic values substituted when comparing two
+// expressions numerically; they avoid 0 and ±1 where identities hide.
+var samplePoints = []float64{0.5, 1.7, -0.3, 2.9, -1.6, 0.9, 3.7, -2.2}
+
+// minSamples is how many sample points must be defined before two
+// expressions are declared numerically equal.
+const minSamples = 3
+
+type rootCheck int
+
+const (
+	rootValid rootCheck = iota
+	rootInvalid
+	rootUnknown
+)
+
+// Verify checks final, the answer a solver gave for problem (canonical
+// ASCII), without trusting the solver: claimed roots are substituted into the
+// equation and claimed results are compared with the original expression,
+// symbolically first and numerically otherwise. It never fails; answers it
+// cannot check are reported unverifiable with the reason in Detail.
+func Verify(problem, final string) *models.Verification {
+	node, err := parser.Parse(problem)
+	if err != nil {
+		return unverifiable("problem does not parse: %v", err)
+	}
+	command, variable, body := splitCommand(node)
+
+	if rel, ok := body.(*parser.Relation); ok {
+		if rel.Op != "=" {
+			return unverifiable("inequalities are not verified")
+		}
+		left, err := FromNode(rel.Left)
+		if err != nil {
+			return unverifiable("%v", err)
+		}
+		right, err := FromNode(rel.Right)
+		if err != nil {
+			return unverifiable("%v", err)
+		}
+		return verifyEquation(left, right, variable, final)
+	}
+
+	e, err := FromNode(body)
+	if err != nil {
+		return unverifiable("%v", err)
+	}
+	switch command {
+	case "solve":
+		return verifyEquation(e, NewInt(0), variable, final)
+	case "diff", "derivative", "differentiate":
+		if variable, err = unknownOf(e, variable, command); err != nil {
+			return unverifiable("%v", err)
+		}
+		derivative, err := Diff(e, variable)
+		if err != nil {
+			return unverifiable("%v", err)
+		}
+		e = derivative
+	case "integrate", "integral", "antiderivative":
+		if variable, err = unknownOf(e, variable, command); err != nil {
+			return unverifiable("%v", err)
+		}
+		return verifyAntiderivative(e, variable, final)
+	}
+	return verifyExpression(e, final)
+}
+
+func unverifiable(format string, args ...interface{}) *models.Verification {
+	return &models.Verification{
+		Status: models.VerificationUnverifiable,
+		Detail: fmt.Sprintf(format, args...),
+	}
+}
+
+func verifyEquation(left, right Expr, variable, final string) *models.Verification {
+	answer, err := ParseAnswer(final)
+	if err != nil {
+		return unverifiable("%v", err)
+	}
+
+	diff := Expand(Sub(left, right))
+	vars := FreeSymbols(diff)
+	if variable == "" && len(vars) == 1 {
+		variable = vars[0]
+	}
+	if len(vars) > 1 {
+		return unverifiable("equations in several variables (%s) are not verified", strings.Join(vars, ", "))
+	}
+
+	// The local engine's exact solution, when it has one, catches answers
+	// that are correct but incomplete
+	ref := &session{}
+	solved := ref.solveEquation(left, right, variable) == nil
+
+	switch {
+	case answer.NoSolution:
+		if !solved {
+			return unverifiable("cannot confirm that the equation has no solution")
+		}
+		if len(ref.rootExprs) == 0 && ref.final != "all real numbers" {
+			return &models.Verification{Status: models.VerificationVerified, Method: MethodSymbolic}
+		}
+		return refuted(MethodSymbolic, nil, "the equation has solutions: %s", ref.final)
+	case answer.AllReals:
+		if solved {
+			if ref.final == "all real numbers" {
+				return &models.Verification{Status: models.VerificationVerified, Method: MethodSymbolic}
+			}
+			return refuted(MethodSymbolic, nil, "the equation only holds for %s", ref.final)
+		}
+		return compareExpressions(diff, NewInt(0), -1)
+	}
+
+	if variable == "" {
+		if answer.Var != "" {
+			return unverifiable("answer is given for %s, but the equation has no unknown once simplified", answer.Var)
+		}
+		return unverifiable("the equation has no unknown")
+	}
+	if answer.Var != "" && answer.Var != variable {
+		return unverifiable("answer is given for %s, the unknown is %s", answer.Var, variable)
+	}
+
+	v := &models.Verification{Method: MethodSymbolic}
+	var invalid, unknown []string
+	for i, value := range answer.Values {
+		residual, check := checkRoot(diff, variable, value, answer.Decimals[i])
+		v.Residuals = append(v.Residuals, residual)
+		if !residual.Exact {
+			v.Method = MethodNumeric
+		}
+		switch check {
+		case rootInvalid:
+			invalid = append(invalid, residual.Candidate)
+		case rootUnknown:
+			unknown = append(unknown, residual.Candidate)
+		}
+	}
+
+	switch {
+	case len(invalid) > 0:
+		v.Status = models.VerificationRefuted
+		v.Detail = "not a solution: " + strings.Join(invalid, ", ")
+	case len(unknown) > 0:
+		v.Status = models.VerificationUnverifiable
+		v.Detail = "cannot evaluate " + strings.Join(unknown, ", ")
+	case solved:
+		if missing := missingRoots(ref.rootExprs, answer, variable); len(missing) > 0 {
+			v.Status = models.VerificationRefuted
+			v.Detail = "missing solution: " + strings.Join(missing, ", ")
+		} else if len(ref.rootExprs) == 0 {
+			v.Status = models.VerificationRefuted
+			v.Detail = "every real number is a solution"
+		} else {
+			v.Status = models.VerificationVerified
+		}
+	default:
+		v.Status = models.VerificationVerified
+	}
+	return v
+}
+
+func refuted(method string, residuals []models.Residual, format string, args ...interface{}) *models.Verification {
+	return &models.Verification{
+		Status:    models.VerificationRefuted,
+		Method:    method,
+		Residuals: residuals,
+		Detail:    fmt.Sprintf(format, args...),
+	}
+}
+
+// checkRoot substitutes value for variable in diff (left - right). A value
+// written with decimals is accepted when Newton's estimate of its distance
+// to the true root is within the precision it was written with.
+func checkRoot(diff Expr, variable string, value Expr, decimals int) (models.Residual, rootCheck) {
+	residual := models.Residual{Candidate: variable + " = " + value.String()}
+	if len(FreeSymbols(value)) > 0 {
+		return residual, rootUnknown
+	}
+
+	if isZero(Simplify(Expand(Substitute(diff, variable, value)))) {
+		zero := 0.0
+		residual.Value = &zero
+		residual.Exact = true
+		return residual, rootValid
+	}
+
+	x, err := Eval(value, nil)
+	if err != nil || !finite(x) {
+		return residual, rootUnknown
+	}
+	env := map[string]float64{variable: x}
+	f, err := Eval(diff, env)
+	if err != nil || !finite(f) {
+		// Undefined at the candidate, e.g. a zero denominator
+		return residual, rootInvalid
+	}
+	residual.Value = &f
+
+	if decimals >= 0 {
+		residual.Candidate = variable + " = " + formatFloat(x)
+		distance := math.Abs(f)
+		if slope := derivativeAt(diff, variable, x); slope != 0 && finite(slope) {
+			distance = math.Abs(f / slope)
+		}
+		if distance <= roundingTolerance(decimals) {
+			return residual, rootValid
+		}
+		return residual, rootInvalid
+	}
+	if math.Abs(f) <= relativeTolerance*math.Max(1, magnitude(diff, env)) {
+		return residual, rootValid
+	}
+	return residual, rootInvalid
+}
+
+// derivativeAt estimates d(e)/d(variable) at x by central differences.
+func derivativeAt(e Expr, variable string, x float64) float64 {
+	h := 1e-6 * math.Max(1, math.Abs(x))
+	above, errA := Eval(e, map[string]float64{variable: x + h})
+	below, errB := Eval(e, map[string]float64{variable: x - h})
+	if errA != nil || errB != nil {
+		return 0
+	}
+	return (above - below) / (2 * h)
+}
+
+// magnitude is the sum of the absolute values of e's terms, the scale
+// against which cancellation error in e is measured.
+func magnitude(e Expr, env map[string]float64) float64 {
+	total := 0.0
+	for _, t := range termsOf(e) {
+		if v, err := Eval(t, env); err == nil && finite(v) {
+			total += math.Abs(v)
+		}
+	}
+	return total
+}
+
+// missingRoots lists the exact roots no claimed value matches.
+func missingRoots(roots []Expr, answer *Answer, variable string) []string {
+	var missing []string
+	for _, root := range roots {
+		r, err := Eval(root, nil)
+		if err != nil {
+			continue
+		}
+		found := false
+		for i, value := range answer.Values {
+			x, err := Eval(value, nil)
+			if err != nil {
+				continue
+			}
+			tolerance := relativeTolerance * math.Max(1, math.Abs(r))
+			if answer.Decimals[i] >= 0 {
+				tolerance = roundingTolerance(answer.Decimals[i])
+			}
+			if math.Abs(x-r) <= tolerance {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, variable+" = "+root.String())
+		}
+	}
+	return missing
+}
+
+func verifyExpression(e Expr, final string) *models.Verification {
+	answer, err := ParseAnswer(final)
+	if err != nil {
+		return unverifiable("%v", err)
+	}
+	if answer.NoSolution || answer.AllReals || len(answer.Values) != 1 {
+		return unverifiable("expected a single expression as the answer, got %q", final)
+	}
+	return compareExpressions(e, answer.Values[0], answer.Decimals[0])
+}
+
+// verifyAntiderivative differentiates the claimed antiderivative, whose
+// constant of integration differentiates away, and compares it with e.
+func verifyAntiderivative(e Expr, variable, final string) *models.Verification {
+	answer, err := ParseAnswer(final)
+	if err != nil {
+		return unverifiable("%v", err)
+	}
+	if answer.NoSolution || answer.AllReals || len(answer.Values) != 1 {
+		return unverifiable("expected a single expression as the answer, got %q", final)
+	}
+	derivative, err := Diff(answer.Values[0], variable)
+	if err != nil {
+		return unverifiable("%v", err)
+	}
+	return compareExpressions(e, derivative, -1)
+}
+
+// compareExpressions checks that claim equals e, exactly when possible and
+// otherwise at sample points for every free variable.
+func compareExpressions(e, claim Expr, decimals int) *models.Verification {
+	candidate := claim.String()
+	difference := Sub(e, claim)
+	if isZero(Simplify(Expand(difference))) {
+		zero := 0.0
+		return &models.Verification{
+			Status:    models.VerificationVerified,
+			Method:    MethodSymbolic,
+			Residuals: []models.Residual{{Candidate: candidate, Value: &zero, Exact: true}},
+		}
+	}
+
+	vars := FreeSymbols(difference)
+	if len(vars) == 0 {
+		expected, errE := Eval(e, nil)
+		got, errC := Eval(claim, nil)
+		if errE != nil || errC != nil || !finite(expected) || !finite(got) {
+			return unverifiable("cannot evaluate %s numerically", candidate)
+		}
+		delta := got - expected
+		tolerance := relativeTolerance * math.Max(1, math.Abs(expected))
+		if decimals >= 0 {
+			tolerance = roundingTolerance(decimals)
+			candidate = formatFloat(got)
+		}
+		residuals := []models.Residual{{Candidate: candidate, Value: &delta}}
+		if math.Abs(delta) > tolerance {
+			return refuted(MethodNumeric, residuals, "expected approximately %s", formatFloat(expected))
+		}
+		return &models.Verification{Status: models.VerificationVerified, Method: MethodNumeric, Residuals: residuals}
+	}
+
+	var residuals []models.Residual
+	for k := range samplePoints {
+		env := map[string]float64{}
+		var point []string
+		for j, name := range vars {
+			x := samplePoints[(k+3*j)%len(samplePoints)]
+			env[name] = x
+			point = append(point, name+" = "+formatFloat(x))
+		}
+		expected, errE := Eval(e, env)
+		got, errC := Eval(claim, env)
+		if errE != nil || errC != nil || !finite(expected) || !finite(got) {
+			continue
+		}
+		delta := got - expected
+		residuals = append(residuals, models.Residual{Candidate: strings.Join(point, ", "), Value: &delta})
+		if math.Abs(delta) > relativeTolerance*math.Max(1, math.Max(math.Abs(expected), math.Abs(got))) {
+			return refuted(MethodNumeric, residuals, "differs from the problem at %s", strings.Join(point, ", "))
+		}
+	}
+	if len(residuals) < minSamples {
+		return unverifiable("cannot evaluate %s at enough sample points", candidate)
+	}
+	return &models.Verification{Status: models.VerificationVerified, Method: MethodNumeric, Residuals: residuals}
+}
+
+// roundingTolerance is the largest error of a value correctly rounded to
+// the given number of decimals.
+func roundingTolerance(decimals int) float64 {
+	return 0.5 * math.Pow(10, -float64(decimals)) * (1 + relativeTolerance)
+}
+
+func finite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
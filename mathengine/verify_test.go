@@ -0,0 +1,39 @@
+package mathengine
+
+import (
+	"strings"
+	"testing"
+
+	"maths-solution-backend/models"
+)
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		problem string
+		final   string
+		status  string
+		detail  string // Substring of Detail, when set
+	}{
+		{"x + 1 = 3", "x = 2", models.VerificationVerified, ""},
+		{"x + 1 = 3", "x = 3", models.VerificationRefuted, "not a solution"},
+		{"x^2 = 4", "x = 2", models.VerificationRefuted, "missing solution"},
+		{"x^2 = 4", "x = -2 or x = 2", models.VerificationVerified, ""},
+		{"x^2 = -1", "no solution", models.VerificationVerified, ""},
+		{"1/2 + 1/3", "5/6", models.VerificationVerified, ""},
+		{"1/2 + 1/3", "0.8333333333", models.VerificationVerified, ""},
+		{"1/2 + 1/3", "1", models.VerificationRefuted, ""},
+		{"diff x^2", "2*x", models.VerificationVerified, ""},
+		{"y + 1 = 3", "x = 2", models.VerificationUnverifiable, "the unknown is y"},
+		{"x/x = 1", "x = 0", models.VerificationUnverifiable, "no unknown once simplified"},
+		{"2 = 2", "x = 1", models.VerificationUnverifiable, "no unknown once simplified"},
+	}
+	for _, tt := range tests {
+		v := Verify(tt.problem, tt.final)
+		if v.Status != tt.status || !strings.Contains(v.Detail, tt.detail) {
+			t.Errorf("Verify(%q, %q) = %s (%s), want %s (%s)", tt.problem, tt.final, v.Status, v.Detail, tt.status, tt.detail)
+		}
+		if strings.HasSuffix(v.Detail, " ") {
+			t.Errorf("Verify(%q, %q) detail %q ends in a blank name", tt.problem, tt.final, v.Detail)
+		}
+	}
+}
@@ -1,16 +1,22 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
 	"maths-solution-backend/auth"
 	"maths-solution-backend/config"
+	"maths-solution-backend/database"
+	"maths-solution-backend/models"
+	"maths-solution-backend/services"
 
 	"github.com/gin-gonic/gin"
 )
 
-func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
+// AuthMiddleware accepts requests bearing a valid access token that has not
+// been revoked.
+func AuthMiddleware(cfg *config.Config, revocations *services.TokenRevocations) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -34,9 +40,30 @@ func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
-		// Skip database existence check; trust JWT for lightweight dev flow
+		// Reject tokens revoked by logout and tokens of deleted users
+		token := services.AccessToken{
+			ID:        claims.ID,
+			UserID:    claims.UserID,
+			Version:   claims.TokenVersion,
+			ExpiresAt: claims.ExpiresAt.Time,
+		}
+		switch err := revocations.Check(c.Request.Context(), token); {
+		case errors.Is(err, services.ErrTokenRevoked):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+			c.Abort()
+			return
+		case errors.Is(err, services.ErrUserInactive):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User no longer exists"})
+			c.Abort()
+			return
+		case err != nil:
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Failed to check token"})
+			c.Abort()
+			return
+		}
 
 		// Set user info in context
+		c.Set("access_token", token)
 		c.Set("user_id", claims.UserID)
 		c.Set("user_email", claims.Email)
 		c.Set("user_full_name", claims.FullName)
@@ -44,3 +71,32 @@ func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// AdminMiddleware follows AuthMiddleware on endpoints that affect every
+// user, such as cache invalidation. It admits users whose verified email is
+// listed in ADMIN_EMAILS; with none listed, nobody is admitted.
+func AdminMiddleware(cfg *config.Config) gin.HandlerFunc {
+	admins := map[string]bool{}
+	for _, email := range strings.Split(cfg.Server.AdminEmails, ",") {
+		if email = strings.ToLower(strings.TrimSpace(email)); email != "" {
+			admins[email] = true
+		}
+	}
+
+	return func(c *gin.Context) {
+		userID, _ := c.Get("user_id")
+		var user models.User
+		if database.DB == nil || database.DB.Where("id = ?", userID).First(&user).Error != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+			c.Abort()
+			return
+		}
+		// Unverified addresses do not count: anyone could register one
+		if user.EmailVerifiedAt == nil || !admins[strings.ToLower(user.Email)] {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
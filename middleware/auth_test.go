@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"maths-solution-backend/auth"
+	"maths-solution-backend/config"
+	"maths-solution-backend/models"
+	"maths-solution-backend/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestAuthMiddlewareRevocation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{JWT: config.JWTConfig{Secret: "test-secret", AccessTTL: time.Minute}}
+	revocations := services.NewTokenRevocations(nil, time.Minute)
+
+	issue := func() string {
+		token, err := auth.GenerateToken(&models.User{ID: 1}, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	revoked := issue()
+	claims, err := auth.ValidateToken(revoked, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = revocations.Revoke(context.Background(), services.AccessToken{ID: claims.ID, UserID: 1, ExpiresAt: claims.ExpiresAt.Time})
+	if err != nil {
+		t.Fatal(err)
+	}
+	noJTI, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &auth.Claims{
+		UserID:           1,
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))},
+	}).SignedString([]byte(cfg.JWT.Secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		header string
+		status int
+	}{
+		{"valid", "Bearer " + issue(), http.StatusOK},
+		{"revoked", "Bearer " + revoked, http.StatusUnauthorized},
+		{"without jti", "Bearer " + noJTI, http.StatusUnauthorized},
+		{"missing", "", http.StatusUnauthorized},
+		{"not bearer", "Token " + issue(), http.StatusUnauthorized},
+	}
+	router := gin.New()
+	router.GET("/", AuthMiddleware(cfg, revocations), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+		router.ServeHTTP(w, req)
+		if w.Code != tt.status {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, w.Code, tt.status, w.Body.String())
+		}
+	}
+}
@@ -1,31 +1,39 @@
 package models
 
 import (
+	"encoding/json"
+	"fmt"
 	"time"
 
 	"gorm.io/gorm"
 )
 
 type User struct {
-	ID        uint           `json:"id" gorm:"primaryKey"`
-	Email     string         `json:"email" gorm:"not null"`
-	FullName  string         `json:"full_name" gorm:"not null"`
-	Password  string         `json:"-" gorm:"not null"` // Hidden from JSON
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+	ID              uint           `json:"id" gorm:"primaryKey"`
+	Email           string         `json:"email" gorm:"not null;uniqueIndex:idx_users_email_lower,expression:lower(email),where:deleted_at IS NULL"` // Unique ignoring case among live accounts
+	FullName        string         `json:"full_name" gorm:"not null"`
+	Password        string         `json:"-" gorm:"not null"`           // Hidden from JSON
+	TokenVersion    int            `json:"-" gorm:"not null;default:0"` // Copied into access tokens; logout-all increments it
+	EmailVerifiedAt *time.Time     `json:"email_verified_at"`           // Nil until the emailed link is opened
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
 type Solution struct {
-	ID          uint           `json:"id" gorm:"primaryKey"`
-	UserID      uint           `json:"user_id" gorm:"not null"`
-	User        User           `json:"user" gorm:"foreignKey:UserID"`
-	Expression  string         `json:"expression" gorm:"not null"`
-	StepsJSON   string         `json:"steps_json" gorm:"type:text"`
-	FinalAnswer string         `json:"final_answer" gorm:"type:text"`
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+	ID                 uint           `json:"id" gorm:"primaryKey"`
+	UserID             uint           `json:"user_id" gorm:"not null"`
+	User               User           `json:"user" gorm:"foreignKey:UserID"`
+	Expression         string         `json:"expression" gorm:"not null"`
+	StepsJSON          string         `json:"steps_json" gorm:"type:text"`
+	FinalAnswer        string         `json:"final_answer" gorm:"type:text"`
+	VerificationStatus string         `json:"verification_status" gorm:"size:16"` // verified, refuted or unverifiable
+	VerificationJSON   string         `json:"verification_json" gorm:"type:text"`
+	Mode               string         `json:"mode" gorm:"size:16"`             // full, hints or next_step; empty on older rows means full
+	RevealedSteps      int            `json:"revealed_steps" gorm:"default:0"` // Steps shown to the user so far
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+	DeletedAt          gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
 type UsageLimit struct {
@@ -38,6 +46,136 @@ type UsageLimit struct {
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// RefreshToken is an opaque, single-use token exchanged for a new access
+// token and a new refresh token. Only its SHA-256 hash is stored. The tokens
+// rotated from one login share a family, which is revoked as a whole when a
+// token that was already used is presented again.
+type RefreshToken struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	User      User       `json:"-" gorm:"foreignKey:UserID"`
+	TokenHash string     `json:"-" gorm:"size:64;not null;uniqueIndex"`
+	FamilyID  string     `json:"family_id" gorm:"size:32;not null;index"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at"` // Set once rotated
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// RevokedToken is an access token revoked by logout before it expired,
+// identified by its jti. Rows are useless once the token would have expired.
+type RevokedToken struct {
+	JTI       string    `json:"jti" gorm:"column:jti;primaryKey;size:32"`
+	UserID    uint      `json:"user_id" gorm:"not null;index"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"not null;index"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PasswordResetToken is a single-use token emailed to reset a forgotten
+// password. Only its SHA-256 hash is stored.
+type PasswordResetToken struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	User      User       `json:"-" gorm:"foreignKey:UserID"`
+	TokenHash string     `json:"-" gorm:"size:64;not null;uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at"` // Set once redeemed or replaced by a newer token
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// EmailVerificationToken is a single-use token emailed to confirm that a
+// user owns their address. Only its SHA-256 hash is stored.
+type EmailVerificationToken struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	User      User       `json:"-" gorm:"foreignKey:UserID"`
+	TokenHash string     `json:"-" gorm:"size:64;not null;uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at"` // Set once redeemed or replaced by a newer token
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// CachedSolution is the shared, Postgres-backed tier of the solution cache.
+type CachedSolution struct {
+	Key        string    `json:"key" gorm:"primaryKey;size:64"` // SHA-256 of solver, options and expression
+	Expression string    `json:"expression" gorm:"not null;index"`
+	Solver     string    `json:"solver" gorm:"size:32"`
+	StepsJSON  string    `json:"steps_json" gorm:"type:text"`
+	Final      string    `json:"final" gorm:"type:text"`
+	ExpiresAt  time.Time `json:"expires_at" gorm:"index"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// PracticeSet is a batch of generated practice problems, on a topic or like
+// one of the user's solutions.
+type PracticeSet struct {
+	ID               uint              `json:"id" gorm:"primaryKey"`
+	UserID           uint              `json:"user_id" gorm:"not null;index"`
+	User             User              `json:"-" gorm:"foreignKey:UserID"`
+	SourceSolutionID *uint             `json:"source_solution_id"`
+	Topic            string            `json:"topic" gorm:"size:32"`
+	Difficulty       string            `json:"difficulty" gorm:"size:16"`
+	Problems         []PracticeProblem `json:"problems" gorm:"foreignKey:PracticeSetID"`
+	CreatedAt        time.Time         `json:"created_at"`
+	UpdatedAt        time.Time         `json:"updated_at"`
+}
+
+type PracticeProblem struct {
+	ID              uint       `json:"id" gorm:"primaryKey"`
+	PracticeSetID   uint       `json:"practice_set_id" gorm:"not null;index"`
+	Position        int        `json:"position"`
+	Problem         string     `json:"problem" gorm:"not null"` // Canonical ASCII
+	ReferenceAnswer string     `json:"-" gorm:"type:text"`      // Hidden until the problem is graded
+	SubmittedAnswer string     `json:"submitted_answer" gorm:"type:text"`
+	Correct         *bool      `json:"correct"` // Nil until graded
+	Attempts        int        `json:"attempts" gorm:"default:0"`
+	GradedAt        *time.Time `json:"graded_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// Statuses of a SolveJob
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// Delivery statuses of a SolveJob's webhook
+const (
+	WebhookPending   = "pending"
+	WebhookDelivered = "delivered"
+	WebhookFailed    = "failed"
+)
+
+// SolveJob is a solve queued for the worker pool. The table is the queue:
+// workers claim due rows with SELECT ... FOR UPDATE SKIP LOCKED, and rows
+// locked longer than the lease are reclaimed after a crash or restart.
+type SolveJob struct {
+	ID              uint       `json:"id" gorm:"primaryKey"`
+	UserID          uint       `json:"user_id" gorm:"not null;index"`
+	User            User       `json:"-" gorm:"foreignKey:UserID"`
+	Expression      string     `json:"expression" gorm:"not null"` // Canonical ASCII
+	Solver          string     `json:"solver" gorm:"size:32"`
+	Precision       int        `json:"precision"`
+	Mode            string     `json:"mode" gorm:"size:16"`
+	Status          string     `json:"status" gorm:"size:16;not null;index"` // queued, running, succeeded or failed
+	Attempts        int        `json:"attempts" gorm:"default:0"`
+	Error           string     `json:"error" gorm:"type:text"`
+	ResponseJSON    string     `json:"response_json" gorm:"type:text"` // SolveMathResponse once succeeded
+	WebhookURL      string     `json:"webhook_url" gorm:"type:text"`
+	WebhookStatus   string     `json:"webhook_status" gorm:"size:16;index"` // Empty without a webhook
+	WebhookAttempts int        `json:"webhook_attempts" gorm:"default:0"`
+	UsageDate       string     `json:"-" gorm:"size:10"`             // Day a solve was reserved from at submission; empty for jobs charged on success
+	RunAt           time.Time  `json:"run_at" gorm:"not null;index"` // Not claimed before this time
+	LockedAt        *time.Time `json:"locked_at"`                    // Set while a worker holds the job
+	StartedAt       *time.Time `json:"started_at"`
+	FinishedAt      *time.Time `json:"finished_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
 // Request/Response DTOs
 type LoginRequest struct {
 	Email    string `json:"email" binding:"required,email"`
@@ -51,22 +189,302 @@ type RegisterRequest struct {
 }
 
 type AuthResponse struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
+	Token        string `json:"token"`         // Short-lived access token
+	ExpiresIn    int    `json:"expires_in"`    // Seconds until Token expires
+	RefreshToken string `json:"refresh_token"` // Single use; exchange at /auth/refresh
+	User         User   `json:"user"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
 }
 
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6"`
+}
+
+type VerifyEmailResponse struct {
+	Email           string    `json:"email"`
+	EmailVerifiedAt time.Time `json:"email_verified_at"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token,omitempty"` // Also revoke this session's refresh tokens
+}
+
+// Modes of a solve request
+const (
+	SolveModeFull     = "full"      // All steps and the answer
+	SolveModeHints    = "hints"     // Conceptual hints only; steps are revealed on request
+	SolveModeNextStep = "next_step" // The first step; the rest are revealed on request
+)
+
 type SolveMathRequest struct {
-	Expression string `json:"expression" binding:"required"`
+	Expression  string `json:"expression" binding:"required,max=10000"`                                       // Long enough for MathML
+	InputFormat string `json:"input_format,omitempty" binding:"omitempty,oneof=ascii latex mathml asciimath"` // Defaults to ascii
+	Solver      string `json:"solver,omitempty"`                                                              // Optional solver name, defaults to SOLVER_DEFAULT
+	Precision   int    `json:"precision,omitempty" binding:"omitempty,min=1,max=100"`                         // Significant digits of decimal results, defaults to 10
+	Mode        string `json:"mode,omitempty" binding:"omitempty,oneof=full hints next_step"`                 // Defaults to full
 }
 
 type SolveMathResponse struct {
-	Steps []SolutionStep `json:"steps"`
-	Final string         `json:"final"`
+	SolutionID    uint           `json:"solution_id,omitempty"` // Saved Solution, when the database is available
+	Expression    string         `json:"expression"`            // Canonical form of the submitted problem
+	Mode          string         `json:"mode"`
+	Steps         []SolutionStep `json:"steps"`           // Only the revealed steps outside full mode
+	Final         string         `json:"final,omitempty"` // Withheld until every step is revealed
+	Hints         []string       `json:"hints,omitempty"`
+	RevealedSteps int            `json:"revealed_steps"`
+	TotalSteps    int            `json:"total_steps"`
+	Solver        string         `json:"solver,omitempty"`
+	Verification  *Verification  `json:"verification,omitempty"`
+	Cached        bool           `json:"cached"` // Answer served from the solution cache
+}
+
+type RevealStepResponse struct {
+	SolutionID    uint           `json:"solution_id"`
+	Mode          string         `json:"mode"`
+	Step          *SolutionStep  `json:"step,omitempty"` // Newly revealed, missing once all are shown
+	Steps         []SolutionStep `json:"steps"`          // Every step revealed so far
+	RevealedSteps int            `json:"revealed_steps"`
+	TotalSteps    int            `json:"total_steps"`
+	Done          bool           `json:"done"`
+	Final         string         `json:"final,omitempty"` // Once done
+	Verification  *Verification  `json:"verification,omitempty"`
+}
+
+// Quota modes of a batch solve
+const (
+	BatchModeReject  = "reject"  // Solve nothing unless the whole batch fits in the remaining quota
+	BatchModePartial = "partial" // Solve as many items, in order, as the quota allows
+)
+
+type SolveBatchRequest struct {
+	Expressions []string `json:"expressions" binding:"required,min=1,max=50,dive,max=10000"`
+	InputFormat string   `json:"input_format,omitempty" binding:"omitempty,oneof=ascii latex mathml asciimath"`
+	Solver      string   `json:"solver,omitempty"`
+	Precision   int      `json:"precision,omitempty" binding:"omitempty,min=1,max=100"`
+	Mode        string   `json:"mode,omitempty" binding:"omitempty,oneof=reject partial"` // Defaults to reject
+}
+
+// Outcomes of one batch item
+const (
+	BatchItemSolved  = "solved"
+	BatchItemFailed  = "failed"
+	BatchItemSkipped = "skipped" // Not attempted for lack of quota
+)
+
+type SolveBatchItem struct {
+	Index      int                `json:"index"`
+	Status     string             `json:"status"` // solved, failed or skipped
+	Result     *SolveMathResponse `json:"result,omitempty"`
+	Error      string             `json:"error,omitempty"`
+	StatusCode int                `json:"status_code,omitempty"` // HTTP status the item would have had on its own
+}
+
+type SolveBatchResponse struct {
+	Mode    string              `json:"mode"`
+	Solved  int                 `json:"solved"`
+	Results []SolveBatchItem    `json:"results"` // In request order
+	Usage   *UsageLimitResponse `json:"usage,omitempty"`
+}
+
+type SolveJobRequest struct {
+	SolveMathRequest
+	WebhookURL string `json:"webhook_url,omitempty" binding:"omitempty,url"` // POSTed the job once it finishes
+}
+
+type SolveJobResponse struct {
+	ID            uint               `json:"id"`
+	Status        string             `json:"status"`
+	Expression    string             `json:"expression"`
+	Solver        string             `json:"solver,omitempty"`
+	Attempts      int                `json:"attempts"`
+	Error         string             `json:"error,omitempty"`
+	Result        *SolveMathResponse `json:"result,omitempty"`
+	WebhookStatus string             `json:"webhook_status,omitempty"`
+	CreatedAt     time.Time          `json:"created_at"`
+	StartedAt     *time.Time         `json:"started_at,omitempty"`
+	FinishedAt    *time.Time         `json:"finished_at,omitempty"`
+}
+
+type CheckWorkRequest struct {
+	Problem     string   `json:"problem" binding:"required,max=10000"`
+	Steps       []string `json:"steps" binding:"required,min=1,max=50,dive,max=10000"` // ASCII or LaTeX, in order
+	InputFormat string   `json:"input_format,omitempty" binding:"omitempty,oneof=ascii latex mathml asciimath"`
+	Solver      string   `json:"solver,omitempty"` // Produces the reference answer, defaults to SOLVER_DEFAULT
+}
+
+// Outcomes of checking one student step
+const (
+	StepValid        = "valid"
+	StepInvalid      = "invalid"
+	StepUnverifiable = "unverifiable" // Could not be read or compared; skipped over
+	StepUnchecked    = "unchecked"    // After the first invalid step
+)
+
+type StepCheck struct {
+	Index       int    `json:"index"`
+	Input       string `json:"input"`
+	Canonical   string `json:"canonical,omitempty"` // How the step was read
+	Status      string `json:"status"`              // valid, invalid, unverifiable or unchecked
+	Method      string `json:"method,omitempty"`    // symbolic or numeric
+	Explanation string `json:"explanation,omitempty"`
+}
+
+type CheckWorkResponse struct {
+	Problem          string      `json:"problem"` // Canonical form
+	Valid            bool        `json:"valid"`   // No step is invalid
+	Complete         bool        `json:"complete"`
+	FirstInvalidStep *int        `json:"first_invalid_step,omitempty"`
+	Steps            []StepCheck `json:"steps"`
+	Hints            []string    `json:"hints,omitempty"` // When a step is invalid
+}
+
+type CompareAnswersRequest struct {
+	Expected  string   `json:"expected" binding:"required,max=10000"` // ASCII or LaTeX
+	Answer    string   `json:"answer" binding:"required,max=10000"`
+	Tolerance *float64 `json:"tolerance,omitempty" binding:"omitempty,gte=0"` // Absolute, for numeric comparisons; omitted means exact up to float noise
+}
+
+type CompareAnswersResponse struct {
+	Equivalent bool     `json:"equivalent"`
+	Kind       string   `json:"kind"`             // expression, values or intervals
+	Method     string   `json:"method,omitempty"` // symbolic or numeric
+	Expected   string   `json:"expected"`         // How each answer was read
+	Answer     string   `json:"answer"`
+	Reasoning  []string `json:"reasoning"`
+}
+
+// PracticeRequest asks for problems on a topic or like a saved solution;
+// exactly one of Topic and SolutionID is required.
+type PracticeRequest struct {
+	Topic       string `json:"topic,omitempty"`
+	SolutionID  uint   `json:"solution_id,omitempty"`
+	Count       int    `json:"count,omitempty" binding:"omitempty,min=1,max=20"`                // Defaults to 5
+	Difficulty  string `json:"difficulty,omitempty" binding:"omitempty,oneof=easy medium hard"` // Defaults to medium
+	NiceAnswers *bool  `json:"nice_answers,omitempty"`                                          // Integer and small-fraction answers only, defaults to true
+}
+
+type PracticeProblemResponse struct {
+	ID       uint   `json:"id"`
+	Position int    `json:"position"`
+	Problem  string `json:"problem"`
+	Answer   string `json:"answer,omitempty"` // Reference answer, once graded
+	Correct  *bool  `json:"correct,omitempty"`
+	Attempts int    `json:"attempts"`
+}
+
+type PracticeSetResponse struct {
+	ID               uint                      `json:"id"`
+	Topic            string                    `json:"topic"`
+	Difficulty       string                    `json:"difficulty"`
+	SourceSolutionID *uint                     `json:"source_solution_id,omitempty"`
+	Problems         []PracticeProblemResponse `json:"problems"`
+}
+
+type PracticeAnswer struct {
+	ProblemID uint   `json:"problem_id" binding:"required"`
+	Answer    string `json:"answer" binding:"required,max=10000"`
+}
+
+type GradePracticeRequest struct {
+	Answers []PracticeAnswer `json:"answers" binding:"required,min=1,max=20,dive"`
+}
+
+type PracticeGrade struct {
+	ProblemID       uint          `json:"problem_id"`
+	Problem         string        `json:"problem"`
+	Answer          string        `json:"answer"`
+	Correct         bool          `json:"correct"`
+	ReferenceAnswer string        `json:"reference_answer"`
+	Verification    *Verification `json:"verification,omitempty"`
+}
+
+type GradePracticeResponse struct {
+	PracticeSetID uint            `json:"practice_set_id"`
+	Correct       int             `json:"correct"`
+	Results       []PracticeGrade `json:"results"`
+}
+
+type InvalidateCacheRequest struct {
+	Expression  string `json:"expression" binding:"required,max=10000"`
+	InputFormat string `json:"input_format,omitempty" binding:"omitempty,oneof=ascii latex mathml asciimath"`
+}
+
+// SolveSystemRequest carries either a list of linear equations or a matrix
+type SolveSystemRequest struct {
+	Equations   []string        `json:"equations,omitempty" binding:"omitempty,max=10,dive,max=10000"`
+	Matrix      [][]MatrixEntry `json:"matrix,omitempty" binding:"omitempty,max=10,dive,max=10"`
+	InputFormat string          `json:"input_format,omitempty" binding:"omitempty,oneof=ascii latex mathml asciimath"` // Applies to equations and string entries
+}
+
+// MatrixEntry is a matrix element sent either as a JSON number or as a string
+// holding an exact value such as "-2/3". Numbers are kept as written, e.g.
+// "2.5E-3", with Number set.
+type MatrixEntry struct {
+	Text   string
+	Number bool
+}
+
+func (m *MatrixEntry) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*m = MatrixEntry{Text: s}
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("matrix entries must be numbers or strings")
+	}
+	*m = MatrixEntry{Text: n.String(), Number: true}
+	return nil
+}
+
+type SolveSystemResponse struct {
+	Expression  string         `json:"expression"`          // Canonical form of the submitted system or matrix
+	Variables   []string       `json:"variables,omitempty"` // Unknowns in column order, for equations
+	Steps       []SolutionStep `json:"steps"`
+	Final       string         `json:"final"`
+	Rank        int            `json:"rank"`
+	Determinant string         `json:"determinant,omitempty"` // Square matrices only
+	Inverse     [][]string     `json:"inverse,omitempty"`     // Invertible matrices only
+	Eigenvalues []string       `json:"eigenvalues,omitempty"` // Square matrices up to 4x4
 }
 
 type SolutionStep struct {
 	Index int    `json:"index"`
 	Latex string `json:"latex"`
+	Rule  string `json:"rule,omitempty"` // Human-readable rule applied in this step, e.g. "Chain rule"
+}
+
+// Verification statuses of a final answer checked against the problem
+const (
+	VerificationVerified     = "verified"
+	VerificationRefuted      = "refuted"
+	VerificationUnverifiable = "unverifiable"
+)
+
+// Verification is the outcome of independently checking a final answer by
+// substituting it back into the problem.
+type Verification struct {
+	Status    string     `json:"status"`           // verified, refuted or unverifiable
+	Method    string     `json:"method,omitempty"` // symbolic or numeric
+	Residuals []Residual `json:"residuals,omitempty"`
+	Detail    string     `json:"detail,omitempty"`
+}
+
+// Residual is what is left of the problem once a claimed value is substituted:
+// zero for a correct root, or the difference at a sample point for identities.
+type Residual struct {
+	Candidate string   `json:"candidate"`
+	Value     *float64 `json:"value,omitempty"` // Missing when the problem is undefined at the candidate
+	Exact     bool     `json:"exact"`           // Residual proven zero symbolically
 }
 
 type HistoryResponse struct {
@@ -75,8 +493,9 @@ type HistoryResponse struct {
 }
 
 type UsageLimitResponse struct {
-	Count     int    `json:"count"`
-	Limit     int    `json:"limit"`
-	Exceeded  bool   `json:"exceeded"`
-	ResetTime string `json:"reset_time"` // ISO string for next reset
+	Count      int    `json:"count"`
+	Limit      int    `json:"limit"`
+	Exceeded   bool   `json:"exceeded"`
+	ResetTime  string `json:"reset_time"`           // ISO string for next reset
+	Unverified bool   `json:"unverified,omitempty"` // Limit reduced until the email is verified
 }
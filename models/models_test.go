@@ -0,0 +1,63 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin/binding"
+)
+
+func TestMatrixEntryUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		json string
+		want MatrixEntry
+	}{
+		{`3`, MatrixEntry{Text: "3", Number: true}},
+		{`1e5`, MatrixEntry{Text: "1e5", Number: true}},
+		{`2.5E-3`, MatrixEntry{Text: "2.5E-3", Number: true}},
+		{`"-2/3"`, MatrixEntry{Text: "-2/3"}},
+		{`"\\frac{1}{2}"`, MatrixEntry{Text: `\frac{1}{2}`}},
+	}
+	for _, tt := range tests {
+		var got MatrixEntry
+		if err := json.Unmarshal([]byte(tt.json), &got); err != nil {
+			t.Errorf("Unmarshal(%s): %v", tt.json, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Unmarshal(%s) = %+v, want %+v", tt.json, got, tt.want)
+		}
+	}
+
+	var entry MatrixEntry
+	if err := json.Unmarshal([]byte(`[1]`), &entry); err == nil {
+		t.Errorf("Unmarshal([1]) succeeded, want an error")
+	}
+}
+
+func TestSolveSystemRequestLimits(t *testing.T) {
+	row := func(n int) []MatrixEntry {
+		entries := make([]MatrixEntry, n)
+		for i := range entries {
+			entries[i] = MatrixEntry{Text: "1", Number: true}
+		}
+		return entries
+	}
+	tests := []struct {
+		name  string
+		req   SolveSystemRequest
+		valid bool
+	}{
+		{"equations", SolveSystemRequest{Equations: []string{"x + y = 1", "x - y = 0"}}, true},
+		{"long equation", SolveSystemRequest{Equations: []string{strings.Repeat("x+", 5001) + "1 = 0"}}, false},
+		{"ten columns", SolveSystemRequest{Matrix: [][]MatrixEntry{row(10), row(10)}}, true},
+		{"wide row", SolveSystemRequest{Matrix: [][]MatrixEntry{row(2), row(11)}}, false},
+	}
+	for _, tt := range tests {
+		err := binding.Validator.ValidateStruct(&tt.req)
+		if (err == nil) != tt.valid {
+			t.Errorf("%s: ValidateStruct error = %v, want valid = %v", tt.name, err, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package normalizer
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"maths-solution-backend/parser"
+)
+
+// asciiMathSymbols maps AsciiMath symbols onto the parser's syntax, longest first.
+var asciiMathSymbols = []struct{ from, to string }{
+	{"{:", "("}, {":}", ")"}, {"(:", "("}, {":)", ")"},
+	{"**", "*"}, {"xx", "*"}, {"-:", "/"}, {"cdot", "*"}, {"div", "/"},
+	{"<=", "<="}, {">=", ">="}, {"!=", "!="}, {"leq", "<="}, {"geq", ">="},
+	{"le", "<="}, {"ge", ">="}, {"ne", "!="}, {"lt", "<"}, {"gt", ">"},
+	{"{", "("}, {"}", ")"}, {"[", "("}, {"]", ")"},
+}
+
+type asciiMathConverter struct {
+	input string
+	pos   int
+}
+
+func asciiMathToASCII(input string) (string, error) {
+	c := &asciiMathConverter{input: input}
+	out, err := c.sequence()
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(strings.Fields(out), " "), nil
+}
+
+func (c *asciiMathConverter) errorAt(offset int, message string) error {
+	return &parser.Error{
+		Offset:  offset,
+		Column:  utf8.RuneCountInString(c.input[:offset]) + 1,
+		Message: message,
+	}
+}
+
+// sequence converts the whole input; groups are converted recursively by group.
+func (c *asciiMathConverter) sequence() (string, error) {
+	var b strings.Builder
+	for c.pos < len(c.input) {
+		rest := c.input[c.pos:]
+
+		switch {
+		case strings.HasPrefix(rest, "frac"):
+			c.pos += len("frac")
+			num, err := c.group()
+			if err != nil {
+				return "", err
+			}
+			den, err := c.group()
+			if err != nil {
+				return "", err
+			}
+			b.WriteString("((" + num + ")/(" + den + "))")
+			continue
+		case strings.HasPrefix(rest, "root"):
+			c.pos += len("root")
+			index, err := c.group()
+			if err != nil {
+				return "", err
+			}
+			radicand, err := c.group()
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(" root(" + radicand + ", " + index + ") ")
+			continue
+		case strings.HasPrefix(rest, "sqrt"), strings.HasPrefix(rest, "abs"):
+			// Only the braced form needs rewriting; sqrt(x) and sqrt x are already valid
+			name := "sqrt"
+			if strings.HasPrefix(rest, "abs") {
+				name = "abs"
+			}
+			c.pos += len(name)
+			c.skipSpaces()
+			if c.pos < len(c.input) && c.input[c.pos] == '{' {
+				arg, err := c.group()
+				if err != nil {
+					return "", err
+				}
+				b.WriteString(" " + name + "(" + arg + ") ")
+				continue
+			}
+			b.WriteString(" " + name + " ")
+			continue
+		}
+
+		matched := false
+		for _, sym := range asciiMathSymbols {
+			if strings.HasPrefix(rest, sym.from) && !c.insideWord(sym.from) {
+				c.pos += len(sym.from)
+				b.WriteString(" " + sym.to + " ")
+				matched = true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+
+		r, w := utf8.DecodeRuneInString(rest)
+		c.pos += w
+		b.WriteRune(r)
+	}
+	return b.String(), nil
+}
+
+// insideWord reports whether a letter symbol such as "le" or "xx" at the
+// current position is really part of a longer word like "sqrt" or "exp".
+func (c *asciiMathConverter) insideWord(sym string) bool {
+	if !isASCIILetter(sym[0]) {
+		return false
+	}
+	if c.pos > 0 && isASCIILetter(c.input[c.pos-1]) {
+		return true
+	}
+	end := c.pos + len(sym)
+	return end < len(c.input) && isASCIILetter(c.input[end]) && sym != "xx"
+}
+
+// group reads a bracketed argument: {..}, (..) or [..].
+func (c *asciiMathConverter) group() (string, error) {
+	c.skipSpaces()
+	if c.pos >= len(c.input) {
+		return "", c.errorAt(c.pos, "missing argument")
+	}
+	open := c.input[c.pos]
+	var close byte
+	switch open {
+	case '{':
+		close = '}'
+	case '(':
+		close = ')'
+	case '[':
+		close = ']'
+	default:
+		// A single symbol argument, as in frac1 2
+		start := c.pos
+		_, w := utf8.DecodeRuneInString(c.input[c.pos:])
+		c.pos += w
+		return c.input[start:c.pos], nil
+	}
+
+	start := c.pos
+	depth := 0
+	for i := c.pos; i < len(c.input); i++ {
+		switch c.input[i] {
+		case '{', '(', '[':
+			depth++
+		case '}', ')', ']':
+			depth--
+			if depth == 0 {
+				if c.input[i] != close {
+					return "", c.errorAt(i, "mismatched bracket")
+				}
+				inner := &asciiMathConverter{input: c.input[start+1 : i]}
+				out, err := inner.sequence()
+				if err != nil {
+					return "", c.errorAt(start+1, err.Error())
+				}
+				c.pos = i + 1
+				return out, nil
+			}
+		}
+	}
+	return "", c.errorAt(len(c.input), "missing closing bracket")
+}
+
+func (c *asciiMathConverter) skipSpaces() {
+	for c.pos < len(c.input) && c.input[c.pos] == ' ' {
+		c.pos++
+	}
+}
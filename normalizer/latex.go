@@ -0,0 +1,348 @@
+package normalizer
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"maths-solution-backend/parser"
+)
+
+var latexSymbols = map[string]string{
+	`\cdot`: "*", `\times`: "*", `\ast`: "*", `\div`: "/",
+	`\le`: "<=", `\leq`: "<=", `\leqslant`: "<=",
+	`\ge`: ">=", `\geq`: ">=", `\geqslant`: ">=",
+	`\ne`: "!=", `\neq`: "!=", `\lt`: "<", `\gt`: ">",
+	`\{`: "(", `\}`: ")", `\lvert`: "|", `\rvert`: "|", `\vert`: "|", `\mid`: "|",
+	`\,`: " ", `\;`: " ", `\:`: " ", `\!`: "", `\ `: " ", `\quad`: " ", `\qquad`: " ",
+	`\displaystyle`: "", `\limits`: "", `\int`: "∫", `\intop`: "∫", `\(`: "", `\)`: "", `\[`: "", `\]`: "",
+}
+
+var latexNames = map[string]bool{
+	"sin": true, "cos": true, "tan": true, "sec": true, "csc": true, "cot": true,
+	"arcsin": true, "arccos": true, "arctan": true, "sinh": true, "cosh": true, "tanh": true,
+	"ln": true, "exp": true,
+	"pi": true, "alpha": true, "beta": true, "gamma": true, "delta": true, "theta": true,
+	"lambda": true, "mu": true, "sigma": true, "phi": true, "omega": true,
+}
+
+// latexInverses maps the functions whose ^{-1} names the inverse function
+// rather than the reciprocal.
+var latexInverses = map[string]string{"sin": "asin", "cos": "acos", "tan": "atan"}
+
+type latexToken struct {
+	text   string // a command such as \frac, or a single character
+	offset int
+}
+
+type latexConverter struct {
+	input  string
+	tokens []latexToken
+	pos    int
+}
+
+func latexToASCII(input string) (string, error) {
+	c := &latexConverter{input: input, tokens: tokenizeLatex(input)}
+	out, err := c.sequence("")
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(strings.Fields(out), " "), nil
+}
+
+func tokenizeLatex(input string) []latexToken {
+	var tokens []latexToken
+	for i := 0; i < len(input); {
+		if input[i] == '\\' && i+1 < len(input) {
+			j := i + 1
+			for j < len(input) && isASCIILetter(input[j]) {
+				j++
+			}
+			if j == i+1 {
+				// Control symbol such as \, or \{
+				_, w := utf8.DecodeRuneInString(input[j:])
+				j += w
+			}
+			tokens = append(tokens, latexToken{text: input[i:j], offset: i})
+			i = j
+			continue
+		}
+		_, w := utf8.DecodeRuneInString(input[i:])
+		tokens = append(tokens, latexToken{text: input[i : i+w], offset: i})
+		i += w
+	}
+	return tokens
+}
+
+func (c *latexConverter) errorAt(offset int, format string, args ...interface{}) error {
+	return &parser.Error{
+		Offset:  offset,
+		Column:  utf8.RuneCountInString(c.input[:offset]) + 1,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+func (c *latexConverter) peek() (latexToken, bool) {
+	if c.pos < len(c.tokens) {
+		return c.tokens[c.pos], true
+	}
+	return latexToken{offset: len(c.input)}, false
+}
+
+func (c *latexConverter) skipSpaces() {
+	for {
+		t, ok := c.peek()
+		if !ok || !isSpace(t.text) {
+			return
+		}
+		c.pos++
+	}
+}
+
+// sequence converts tokens until the closing delimiter (or end of input when
+// closing is empty), consuming the delimiter.
+func (c *latexConverter) sequence(closing string) (string, error) {
+	var b strings.Builder
+	for {
+		t, ok := c.peek()
+		if !ok {
+			if closing != "" {
+				return "", c.errorAt(len(c.input), "missing %q", closing)
+			}
+			return b.String(), nil
+		}
+		if t.text == closing {
+			c.pos++
+			return b.String(), nil
+		}
+		if t.text == "}" {
+			return "", c.errorAt(t.offset, "unbalanced '}'")
+		}
+		s, err := c.element()
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(s)
+	}
+}
+
+// element converts one token, together with any arguments it takes.
+func (c *latexConverter) element() (string, error) {
+	t, _ := c.peek()
+	c.pos++
+
+	switch t.text {
+	case "{":
+		inner, err := c.sequence("}")
+		return "(" + inner + ")", err
+	case "[":
+		inner, err := c.sequence("]")
+		return "(" + inner + ")", err
+	case "^":
+		arg, err := c.argument(t)
+		return "^(" + arg + ")", err
+	case "_":
+		arg, err := c.argument(t)
+		if err != nil {
+			return "", err
+		}
+		arg = strings.TrimSpace(arg)
+		if strings.HasPrefix(arg, "(") && strings.HasSuffix(arg, ")") {
+			arg = arg[1 : len(arg)-1]
+		}
+		if !isSubscript(arg) {
+			return "", c.errorAt(t.offset, "unsupported subscript %q", arg)
+		}
+		return "_" + arg, nil
+	case "$", "&":
+		if t.text == "$" {
+			return " ", nil
+		}
+		return "", c.errorAt(t.offset, "alignment '&' is not supported")
+	}
+
+	if !strings.HasPrefix(t.text, `\`) {
+		return t.text, nil
+	}
+	if s, ok := latexSymbols[t.text]; ok {
+		return s, nil
+	}
+	name := t.text[1:]
+	if latexNames[name] {
+		if c.inversePower() {
+			inverse, ok := latexInverses[name]
+			if !ok {
+				return "", c.errorAt(t.offset, `unsupported inverse %s^{-1}`, t.text)
+			}
+			name = inverse
+		}
+		return " " + name + " ", nil
+	}
+
+	switch name {
+	case "frac", "dfrac", "tfrac":
+		num, err := c.argument(t)
+		if err != nil {
+			return "", err
+		}
+		den, err := c.argument(t)
+		if err != nil {
+			return "", err
+		}
+		return "((" + num + ")/(" + den + "))", nil
+	case "sqrt":
+		c.skipSpaces()
+		index := ""
+		if next, ok := c.peek(); ok && next.text == "[" {
+			c.pos++
+			var err error
+			if index, err = c.sequence("]"); err != nil {
+				return "", err
+			}
+		}
+		radicand, err := c.argument(t)
+		if err != nil {
+			return "", err
+		}
+		if index != "" {
+			return " root(" + radicand + ", " + index + ") ", nil
+		}
+		return " sqrt(" + radicand + ") ", nil
+	case "log":
+		return c.logarithm(t)
+	case "left", "right", "bigl", "bigr", "Bigl", "Bigr":
+		c.skipSpaces()
+		delim, ok := c.peek()
+		if !ok {
+			return "", c.errorAt(t.offset, `missing delimiter after \%s`, name)
+		}
+		c.pos++
+		switch delim.text {
+		case "(", "[", `\{`, `\lbrack`:
+			return "(", nil
+		case ")", "]", `\}`, `\rbrack`:
+			return ")", nil
+		case "|", `\vert`, `\lvert`, `\rvert`:
+			return "|", nil
+		case ".":
+			return "", nil
+		}
+		return "", c.errorAt(delim.offset, `unsupported delimiter %q after \%s`, delim.text, name)
+	case "operatorname", "mathrm", "mathit", "mathbf", "text", "textrm":
+		arg, err := c.argument(t)
+		if err != nil {
+			return "", err
+		}
+		return " " + strings.Trim(arg, "()") + " ", nil
+	}
+	return "", c.errorAt(t.offset, "unsupported LaTeX command %s", t.text)
+}
+
+// argument reads a macro argument: a braced group or a single token.
+func (c *latexConverter) argument(owner latexToken) (string, error) {
+	c.skipSpaces()
+	t, ok := c.peek()
+	if !ok {
+		return "", c.errorAt(len(c.input), "missing argument for %s", owner.text)
+	}
+	if t.text == "{" {
+		c.pos++
+		return c.sequence("}")
+	}
+	if t.text == "}" {
+		return "", c.errorAt(t.offset, "missing argument for %s", owner.text)
+	}
+	return c.element()
+}
+
+// inversePower consumes a ^{-1} following a function name, as in \sin^{-1} x,
+// and reports whether there was one.
+func (c *latexConverter) inversePower() bool {
+	start := c.pos
+	c.skipSpaces()
+	if next, ok := c.peek(); ok && next.text == "^" {
+		c.pos++
+		if arg, err := c.argument(next); err == nil && strings.Join(strings.Fields(arg), "") == "-1" {
+			return true
+		}
+	}
+	c.pos = start
+	return false
+}
+
+// logarithm handles \log x, \log(x) and \log_{b} x.
+func (c *latexConverter) logarithm(t latexToken) (string, error) {
+	c.skipSpaces()
+	next, ok := c.peek()
+	if !ok || next.text != "_" {
+		return " log ", nil
+	}
+	c.pos++
+	base, err := c.argument(next)
+	if err != nil {
+		return "", err
+	}
+
+	c.skipSpaces()
+	operand, err := c.operand(t)
+	if err != nil {
+		return "", err
+	}
+	return " log(" + operand + ", " + base + ") ", nil
+}
+
+// operand reads the argument of a function written without braces:
+// a parenthesised group, \left(...\right), a braced group or one token.
+func (c *latexConverter) operand(owner latexToken) (string, error) {
+	t, ok := c.peek()
+	if !ok {
+		return "", c.errorAt(len(c.input), "missing argument for %s", owner.text)
+	}
+	if t.text != "(" && t.text != `\left` {
+		return c.argument(owner)
+	}
+
+	var b strings.Builder
+	depth := 0
+	for {
+		s, err := c.element()
+		if err != nil {
+			return "", err
+		}
+		depth += strings.Count(s, "(") - strings.Count(s, ")")
+		b.WriteString(s)
+		if depth <= 0 {
+			return b.String(), nil
+		}
+		if _, ok := c.peek(); !ok {
+			return "", c.errorAt(len(c.input), "missing ')'")
+		}
+	}
+}
+
+func isSpace(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return unicode.IsSpace(r)
+}
+
+func isASCIILetter(b byte) bool {
+	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+// isSubscript accepts the subscripts the parser understands: digits, or a
+// single letter optionally followed by digits (x_1, a_n, t_0).
+func isSubscript(s string) bool {
+	if s == "" {
+		return false
+	}
+	if isASCIILetter(s[0]) {
+		s = s[1:]
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,148 @@
+package normalizer
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// mathMLNode is a generic element of a presentation MathML tree.
+type mathMLNode struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr   `xml:",any,attr"`
+	Children []mathMLNode `xml:",any"`
+	Text     string       `xml:",chardata"`
+}
+
+var mathMLOperators = map[string]string{
+	"×": "*", "⋅": "*", "·": "*", "∗": "*", "⁢": "*", "÷": "/", "∕": "/",
+	"−": "-", "≤": "<=", "≥": ">=", "≠": "!=", "⁡": "",
+}
+
+func mathMLToASCII(input string) (string, error) {
+	var root mathMLNode
+	if err := xml.Unmarshal([]byte(input), &root); err != nil {
+		return "", fmt.Errorf("invalid MathML: %w", err)
+	}
+	out, err := convertMathML(root)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(strings.Fields(out), " "), nil
+}
+
+func convertMathML(n mathMLNode) (string, error) {
+	switch n.XMLName.Local {
+	case "math", "mrow", "mstyle", "semantics", "mpadded":
+		return convertChildren(n.Children, "")
+	case "annotation", "annotation-xml", "mspace":
+		return "", nil
+	case "mi":
+		name := strings.TrimSpace(n.Text)
+		if name == "π" {
+			name = "pi"
+		}
+		return " " + name + " ", nil
+	case "mn":
+		return " " + strings.TrimSpace(n.Text) + " ", nil
+	case "mo":
+		op := strings.TrimSpace(n.Text)
+		if mapped, ok := mathMLOperators[op]; ok {
+			op = mapped
+		}
+		return " " + op + " ", nil
+	case "mfenced":
+		open, close := attr(n, "open", "("), attr(n, "close", ")")
+		inner, err := convertChildren(n.Children, attr(n, "separators", ","))
+		return open + inner + close, err
+	case "mfrac":
+		args, err := convertArgs(n, 2)
+		if err != nil {
+			return "", err
+		}
+		return "((" + args[0] + ")/(" + args[1] + "))", nil
+	case "msup":
+		args, err := convertArgs(n, 2)
+		if err != nil {
+			return "", err
+		}
+		return "(" + args[0] + ")^(" + args[1] + ")", nil
+	case "msub":
+		args, err := convertArgs(n, 2)
+		if err != nil {
+			return "", err
+		}
+		base, sub := strings.TrimSpace(args[0]), strings.TrimSpace(args[1])
+		if base == "log" {
+			// Leaves "log_b" for the following operand; handled in convertChildren
+			return " log_" + sub + " ", nil
+		}
+		return " " + base + "_" + sub + " ", nil
+	case "msubsup":
+		args, err := convertArgs(n, 3)
+		if err != nil {
+			return "", err
+		}
+		return "(" + strings.TrimSpace(args[0]) + "_" + strings.TrimSpace(args[1]) + ")^(" + args[2] + ")", nil
+	case "msqrt":
+		inner, err := convertChildren(n.Children, "")
+		return " sqrt(" + inner + ") ", err
+	case "mroot":
+		args, err := convertArgs(n, 2)
+		if err != nil {
+			return "", err
+		}
+		return " root(" + args[0] + ", " + args[1] + ") ", nil
+	case "apply", "ci", "cn", "csymbol":
+		return "", fmt.Errorf("content MathML is not supported, send presentation MathML")
+	}
+	return "", fmt.Errorf("unsupported MathML element <%s>", n.XMLName.Local)
+}
+
+func convertChildren(children []mathMLNode, separator string) (string, error) {
+	var parts []string
+	for i := 0; i < len(children); i++ {
+		s, err := convertMathML(children[i])
+		if err != nil {
+			return "", err
+		}
+		// <msub><mi>log</mi><mn>2</mn></msub><mi>x</mi> means log(x, 2)
+		if base, ok := strings.CutPrefix(strings.TrimSpace(s), "log_"); ok && i+1 < len(children) {
+			i++
+			operand, err := convertMathML(children[i])
+			if err != nil {
+				return "", err
+			}
+			s = " log(" + operand + ", " + base + ") "
+		}
+		parts = append(parts, s)
+	}
+	if separator != "" {
+		return strings.Join(parts, separator[:1]), nil
+	}
+	return strings.Join(parts, ""), nil
+}
+
+func convertArgs(n mathMLNode, count int) ([]string, error) {
+	if len(n.Children) != count {
+		return nil, fmt.Errorf("<%s> needs %d children, got %d", n.XMLName.Local, count, len(n.Children))
+	}
+	args := make([]string, count)
+	for i, child := range n.Children {
+		s, err := convertMathML(child)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = s
+	}
+	return args, nil
+}
+
+func attr(n mathMLNode, name, fallback string) string {
+	for _, a := range n.Attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return fallback
+}
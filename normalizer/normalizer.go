@@ -0,0 +1,97 @@
+// Package normalizer converts problems written in LaTeX, MathML, AsciiMath
+// or plain ASCII into one canonical ASCII form, so that solvers, storage and
+// caching all see the same text for the same problem.
+package normalizer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"maths-solution-backend/parser"
+)
+
+const (
+	FormatASCII     = "ascii"
+	FormatLatex     = "latex"
+	FormatMathML    = "mathml"
+	FormatAsciiMath = "asciimath"
+)
+
+// Normalize converts input written in format (empty means ascii) into
+// canonical ASCII. Errors locating a problem in the input are *parser.Error,
+// possibly wrapped when the position refers to the converted text.
+func Normalize(input, format string) (string, error) {
+	node, err := Parse(input, format)
+	if err != nil {
+		return "", err
+	}
+	return parser.Format(node), nil
+}
+
+// Parse converts input to ASCII and parses it.
+func Parse(input, format string) (parser.Node, error) {
+	ascii, err := ToASCII(input, format)
+	if err != nil {
+		return nil, err
+	}
+
+	node, err := parser.Parse(ascii)
+	if err != nil {
+		if ascii != input {
+			return nil, fmt.Errorf("%s input converted to %q: %w", format, ascii, err)
+		}
+		return nil, err
+	}
+	return node, nil
+}
+
+// ToASCII converts input into the parser's ASCII syntax without validating it.
+func ToASCII(input, format string) (string, error) {
+	var ascii string
+	var err error
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "", FormatASCII:
+		ascii = input
+	case FormatLatex:
+		ascii, err = latexToASCII(input)
+	case FormatMathML:
+		ascii, err = mathMLToASCII(input)
+	case FormatAsciiMath:
+		ascii, err = asciiMathToASCII(input)
+	default:
+		return "", fmt.Errorf("unsupported input format %q", format)
+	}
+	if err != nil {
+		return "", err
+	}
+	return rewriteIntegral(rewriteLeibniz(ascii)), nil
+}
+
+// leibniz matches a leading d/dx, as typed or as converted from \frac{d}{dx}.
+var leibniz = regexp.MustCompile(`^\s*\(*\s*d\s*\)*\s*/\s*\(*\s*d\s*([a-zA-Z])\s*\)*\s*`)
+
+// integralSign matches "∫ f dx" as typed or as converted from \int f \, dx.
+var integralSign = regexp.MustCompile(`^\s*∫\s*(.+?)\s*\*?\s*d\s*([a-zA-Z])\s*$`)
+
+// rewriteIntegral turns "∫ f dx" into the parser's integrate(f, x) command.
+func rewriteIntegral(ascii string) string {
+	m := integralSign.FindStringSubmatch(ascii)
+	if m == nil {
+		return ascii
+	}
+	return "integrate(" + m[1] + ", " + m[2] + ")"
+}
+
+// rewriteLeibniz turns "d/dx f" into the parser's diff(f, x) command.
+func rewriteLeibniz(ascii string) string {
+	m := leibniz.FindStringSubmatch(ascii)
+	if m == nil {
+		return ascii
+	}
+	rest := strings.TrimSpace(ascii[len(m[0]):])
+	if rest == "" {
+		return ascii
+	}
+	return "diff(" + rest + ", " + m[1] + ")"
+}
@@ -0,0 +1,71 @@
+package normalizer
+
+import (
+	"errors"
+	"testing"
+
+	"maths-solution-backend/parser"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		input, format, want string
+	}{
+		{"2x+3", "", "2*x + 3"},
+		{"2 × 3 − 1", FormatASCII, "2*3 - 1"},
+		{`\frac{1}{2} + \sqrt{x}`, FormatLatex, "1/2 + sqrt(x)"},
+		{`x^{2} - 3x \cdot 2 = 0`, FormatLatex, "x^2 - 3*x*2 = 0"},
+		{`\sin^2 x + \cos^{2}(x)`, FormatLatex, "sin(x)^2 + cos(x)^2"},
+		{`\sin^{-1}(x) + \cos^{-1} x`, FormatLatex, "asin(x) + acos(x)"},
+		{`\tan^{ - 1}\left(2x\right)`, FormatLatex, "atan(2*x)"},
+		{`\sin^{2}(x)^{-1}`, FormatLatex, "(sin(x)^2)^(-1)"},
+		{`\left( x + 1 \right)^2`, FormatLatex, "(x + 1)^2"},
+		{`\sqrt[3]{8}`, FormatLatex, "root(8, 3)"},
+		{`\ln x + \log_{2} 8`, FormatLatex, "ln(x) + log(8, 2)"},
+		{`2 \times 3 \div 4`, FormatLatex, "2*3/4"},
+		{`\int x^2 \, dx`, FormatLatex, "integrate(x^2, x)"},
+		{`\frac{d}{dx} x^3`, FormatLatex, "diff(x^3, x)"},
+		{`<math><mfrac><mn>1</mn><mi>x</mi></mfrac></math>`, FormatMathML, "1/x"},
+		{`<math><msup><mi>x</mi><mn>2</mn></msup><mo>+</mo><mn>1</mn></math>`, FormatMathML, "x^2 + 1"},
+		{`<math><msqrt><mi>x</mi></msqrt></math>`, FormatMathML, "sqrt(x)"},
+		{"x^(2) + sin x", FormatAsciiMath, "x^2 + sin(x)"},
+		{"frac{1}{2}", FormatAsciiMath, "1/2"},
+	}
+	for _, tt := range tests {
+		got, err := Normalize(tt.input, tt.format)
+		if err != nil {
+			t.Errorf("Normalize(%q, %q): %v", tt.input, tt.format, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Normalize(%q, %q) = %q, want %q", tt.input, tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeErrors(t *testing.T) {
+	tests := []struct {
+		input, format string
+		parseError    bool // A *parser.Error locating the problem
+	}{
+		{"1 +", "", true},
+		{`\frac{1}{`, FormatLatex, true},
+		{`\sec^{-1} x`, FormatLatex, true},
+		{`\ln^{-1}(x)`, FormatLatex, true},
+		{`\sec^{-1} x`, FormatLatex, true},
+		{`\ln^{-1}(x)`, FormatLatex, true},
+		{`<math><mfrac><mn>1</mn></mfrac></math>`, FormatMathML, false},
+		{"x", "word", false},
+	}
+	for _, tt := range tests {
+		_, err := Normalize(tt.input, tt.format)
+		if err == nil {
+			t.Errorf("Normalize(%q, %q) succeeded, want an error", tt.input, tt.format)
+			continue
+		}
+		var parseErr *parser.Error
+		if errors.As(err, &parseErr) != tt.parseError {
+			t.Errorf("Normalize(%q, %q) error = %v, want *parser.Error %v", tt.input, tt.format, err, tt.parseError)
+		}
+	}
+}
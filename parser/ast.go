@@ -0,0 +1,71 @@
+// Package parser turns infix ASCII math such as "2x^2 + 3x - 5 = 0" into a
+// typed syntax tree, reporting malformed input with exact positions.
+package parser
+
+// Node is any element of the syntax tree. Pos is the byte offset of the
+// node's first character in the original input.
+type Node interface {
+	Pos() int
+	node()
+}
+
+// Number is a numeric literal, kept as written (e.g. "0.25").
+type Number struct {
+	Offset int
+	Text   string
+}
+
+// Ident is a variable or constant name such as x, pi or theta.
+type Ident struct {
+	Offset int
+	Name   string
+}
+
+// Unary is a prefix sign: "-" or "+".
+type Unary struct {
+	Offset int
+	Op     string
+	X      Node
+}
+
+// Binary is an arithmetic operation: "+", "-", "*", "/" or "^". Implicit is
+// set for multiplication written by juxtaposition, as in 2x or (x+1)(x-1).
+type Binary struct {
+	Op       string
+	X, Y     Node
+	Implicit bool
+}
+
+// Call is a function application such as sin(x), log(8, 2), or a command
+// such as expand((x+1)^2).
+type Call struct {
+	Offset int
+	Func   string
+	Args   []Node
+}
+
+// Relation is an equation or inequality: "=", "!=", "<", "<=", ">" or ">=".
+type Relation struct {
+	Op          string
+	Left, Right Node
+}
+
+func (n *Number) Pos() int   { return n.Offset }
+func (n *Ident) Pos() int    { return n.Offset }
+func (n *Unary) Pos() int    { return n.Offset }
+func (n *Binary) Pos() int   { return n.X.Pos() }
+func (n *Call) Pos() int     { return n.Offset }
+func (n *Relation) Pos() int { return n.Left.Pos() }
+
+func (*Number) node()   {}
+func (*Ident) node()    {}
+func (*Unary) node()    {}
+func (*Binary) node()   {}
+func (*Call) node()     {}
+func (*Relation) node() {}
+
+// IsRelation reports whether n is an equation or inequality.
+func IsRelation(n Node) bool {
+	_, ok := n.(*Relation)
+	return ok
+}
@@ -0,0 +1,37 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Error describes why input could not be parsed and where. Offset is a byte
+// offset and Column a 1-based character column, both pointing at the
+// offending token.
+type Error struct {
+	Offset   int      `json:"offset"`
+	Column   int      `json:"column"`
+	Message  string   `json:"message"`
+	Found    string   `json:"found,omitempty"`
+	Expected []string `json:"expected,omitempty"`
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "column %d: %s", e.Column, e.Message)
+	if len(e.Expected) > 0 {
+		b.WriteString(", expected ")
+		b.WriteString(joinAlternatives(e.Expected))
+	}
+	return b.String()
+}
+
+func joinAlternatives(items []string) string {
+	switch len(items) {
+	case 0:
+		return ""
+	case 1:
+		return items[0]
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " or " + items[len(items)-1]
+}
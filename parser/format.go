@@ -0,0 +1,122 @@
+package parser
+
+import "strings"
+
+const (
+	precRelation = iota
+	precSum
+	precProduct
+	precUnary
+	precPower
+	precAtom
+)
+
+func nodePrec(n Node) int {
+	switch n := n.(type) {
+	case *Relation:
+		return precRelation
+	case *Binary:
+		switch n.Op {
+		case "+", "-":
+			return precSum
+		case "*", "/":
+			return precProduct
+		case "^":
+			return precPower
+		}
+	case *Unary:
+		return precUnary
+	}
+	return precAtom
+}
+
+// Format prints n in canonical ASCII: explicit "*" for every product, single
+// spaces around "+", "-" and relations, and only the parentheses needed.
+// Parsing the output yields the same tree.
+func Format(n Node) string {
+	var b strings.Builder
+	format(&b, n)
+	return b.String()
+}
+
+func format(b *strings.Builder, n Node) {
+	switch n := n.(type) {
+	case *Number:
+		b.WriteString(canonicalNumber(n.Text))
+	case *Ident:
+		b.WriteString(n.Name)
+	case *Unary:
+		b.WriteString(n.Op)
+		formatOperand(b, n.X, nodePrec(n.X) < precUnary)
+	case *Binary:
+		prec := nodePrec(n)
+		switch n.Op {
+		case "^":
+			// Right associative: only the base needs parentheses at equal precedence
+			formatOperand(b, n.X, nodePrec(n.X) <= prec)
+			b.WriteString("^")
+			formatOperand(b, n.Y, nodePrec(n.Y) < precAtom)
+		case "+", "-":
+			formatOperand(b, n.X, nodePrec(n.X) < prec)
+			b.WriteString(" " + n.Op + " ")
+			formatOperand(b, n.Y, nodePrec(n.Y) <= prec)
+		default:
+			formatOperand(b, n.X, nodePrec(n.X) < prec)
+			b.WriteString(n.Op)
+			formatOperand(b, n.Y, nodePrec(n.Y) <= prec)
+		}
+	case *Call:
+		b.WriteString(n.Func)
+		b.WriteString("(")
+		for i, arg := range n.Args {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			format(b, arg)
+		}
+		b.WriteString(")")
+	case *Relation:
+		format(b, n.Left)
+		b.WriteString(" " + n.Op + " ")
+		format(b, n.Right)
+	}
+}
+
+func formatOperand(b *strings.Builder, n Node, parens bool) {
+	if parens {
+		b.WriteString("(")
+	}
+	format(b, n)
+	if parens {
+		b.WriteString(")")
+	}
+}
+
+// canonicalNumber drops redundant zeros and signs: ".50" becomes "0.5",
+// "007" becomes "7" and "1.50E+05" becomes "1.5e5".
+func canonicalNumber(text string) string {
+	mantissa, exponent, hasExp := strings.Cut(strings.ToLower(text), "e")
+	intPart, frac, hasDot := strings.Cut(mantissa, ".")
+	intPart = strings.TrimLeft(intPart, "0")
+	if intPart == "" {
+		intPart = "0"
+	}
+	frac = strings.TrimRight(frac, "0")
+	if hasDot && frac != "" {
+		mantissa = intPart + "." + frac
+	} else {
+		mantissa = intPart
+	}
+	if !hasExp {
+		return mantissa
+	}
+	sign := ""
+	if strings.HasPrefix(exponent, "-") {
+		sign = "-"
+	}
+	exponent = strings.TrimLeft(strings.TrimLeft(exponent, "+-"), "0")
+	if exponent == "" {
+		return mantissa
+	}
+	return mantissa + "e" + sign + exponent
+}
@@ -0,0 +1,252 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenIdent
+	tokenOp
+)
+
+type token struct {
+	kind   tokenKind
+	text   string
+	offset int
+	column int
+}
+
+func (t token) describe() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of input"
+	case tokenNumber:
+		return "number " + t.text
+	case tokenIdent:
+		return "identifier " + t.text
+	}
+	return "'" + t.text + "'"
+}
+
+// Functions lists the recognised function names and how many arguments each accepts.
+var Functions = map[string][2]int{
+	"sin": {1, 1}, "cos": {1, 1}, "tan": {1, 1},
+	"sec": {1, 1}, "csc": {1, 1}, "cot": {1, 1},
+	"asin": {1, 1}, "acos": {1, 1}, "atan": {1, 1},
+	"arcsin": {1, 1}, "arccos": {1, 1}, "arctan": {1, 1},
+	"sinh": {1, 1}, "cosh": {1, 1}, "tanh": {1, 1},
+	"ln": {1, 1}, "log": {1, 2}, "exp": {1, 1},
+	"sqrt": {1, 1}, "root": {2, 2}, "abs": {1, 1},
+}
+
+// Commands are leading words that ask for a specific operation, written
+// either as a call, expand((x+1)^2), or as a prefix, expand (x+1)^2.
+var Commands = map[string][2]int{
+	"expand": {1, 1}, "factor": {1, 1}, "simplify": {1, 1},
+	"evaluate": {1, 1}, "solve": {1, 2},
+	"diff": {1, 2}, "derivative": {1, 2}, "differentiate": {1, 2},
+	"integrate": {1, 2}, "integral": {1, 2}, "antiderivative": {1, 2},
+}
+
+// namedSymbols are multi-letter identifiers that stand for one symbol.
+var namedSymbols = map[string]bool{
+	"pi": true, "alpha": true, "beta": true, "gamma": true, "delta": true,
+	"theta": true, "lambda": true, "mu": true, "sigma": true, "phi": true, "omega": true,
+}
+
+const superscripts = "⁰¹²³⁴⁵⁶⁷⁸⁹"
+
+var operators = []string{"<=", ">=", "!=", "+", "-", "*", "/", "^", "(", ")", ",", "=", "<", ">", "|"}
+
+// unicodeOperators maps typographic symbols onto their ASCII equivalents.
+var unicodeOperators = map[rune]string{
+	'×': "*", '·': "*", '÷': "/", '−': "-", '≤': "<=", '≥': ">=", '≠': "!=",
+}
+
+func lex(input string) ([]token, error) {
+	var tokens []token
+	column := 1
+	for offset := 0; offset < len(input); {
+		r, size := utf8.DecodeRuneInString(input[offset:])
+		start, startColumn := offset, column
+		emit := func(kind tokenKind, text string, width, runes int) {
+			tokens = append(tokens, token{kind: kind, text: text, offset: start, column: startColumn})
+			offset += width
+			column += runes
+		}
+
+		switch {
+		case r == utf8.RuneError && size == 1:
+			return nil, &Error{Offset: offset, Column: column, Message: "invalid UTF-8 in input"}
+		case unicode.IsSpace(r):
+			offset += size
+			column++
+		case unicode.IsDigit(r) || (r == '.' && offset+1 < len(input) && isDigit(input[offset+1])):
+			end := offset
+			seenDot := false
+			for end < len(input) && (isDigit(input[end]) || (input[end] == '.' && !seenDot)) {
+				if input[end] == '.' {
+					seenDot = true
+				}
+				end++
+			}
+			if n := exponentLength(input[end:]); n > 0 {
+				exponent, err := strconv.Atoi(strings.TrimLeft(input[end+1:end+n], "+"))
+				if err != nil || exponent > MaxExponent || exponent < -MaxExponent {
+					return nil, &Error{Offset: offset, Column: column, Message: fmt.Sprintf("exponent of %s exceeds %d", input[offset:end+n], MaxExponent)}
+				}
+				end += n
+			}
+			emit(tokenNumber, input[offset:end], end-offset, end-offset)
+		case unicode.IsLetter(r):
+			end := offset
+			for end < len(input) {
+				next, w := utf8.DecodeRuneInString(input[end:])
+				if !unicode.IsLetter(next) {
+					break
+				}
+				end += w
+			}
+			// Subscripted variables: x_1, a_n
+			if end+1 < len(input) && input[end] == '_' && (isDigit(input[end+1]) || isASCIILetter(input[end+1])) {
+				end += 2
+				for end < len(input) && isDigit(input[end]) {
+					end++
+				}
+				name := input[offset:end]
+				emit(tokenIdent, name, end-offset, utf8.RuneCountInString(name))
+				continue
+			}
+			for _, part := range splitWord(input[offset:end]) {
+				start, startColumn = offset, column
+				name := part
+				if lower := strings.ToLower(part); isKnownWord(lower) {
+					name = lower
+				}
+				emit(tokenIdent, name, len(part), utf8.RuneCountInString(part))
+			}
+		case r == 'π':
+			emit(tokenIdent, "pi", size, 1)
+		case strings.ContainsRune(superscripts, r):
+			// x² is shorthand for x^2
+			for i, sup := range []rune(superscripts) {
+				if sup == r {
+					tokens = append(tokens, token{kind: tokenOp, text: "^", offset: start, column: startColumn})
+					emit(tokenNumber, string(rune('0'+i)), size, 1)
+					break
+				}
+			}
+		default:
+			if op, ok := unicodeOperators[r]; ok {
+				emit(tokenOp, op, size, 1)
+				continue
+			}
+			matched := ""
+			for _, op := range operators {
+				if strings.HasPrefix(input[offset:], op) {
+					matched = op
+					break
+				}
+			}
+			if matched == "" {
+				return nil, &Error{
+					Offset:  offset,
+					Column:  column,
+					Message: fmt.Sprintf("unexpected character %q", r),
+					Found:   string(r),
+				}
+			}
+			emit(tokenOp, matched, len(matched), len(matched))
+		}
+	}
+	tokens = append(tokens, token{kind: tokenEOF, offset: len(input), column: column})
+	return tokens, nil
+}
+
+// knownWords is every multi-letter name, longest first, used to split runs of
+// letters such as "sinx" into "sin" "x" and "xy" into "x" "y".
+var knownWords = func() []string {
+	var words []string
+	for name := range Functions {
+		words = append(words, name)
+	}
+	for name := range Commands {
+		words = append(words, name)
+	}
+	for name := range namedSymbols {
+		words = append(words, name)
+	}
+	sort.Slice(words, func(i, j int) bool {
+		if len(words[i]) != len(words[j]) {
+			return len(words[i]) > len(words[j])
+		}
+		return words[i] < words[j]
+	})
+	return words
+}()
+
+func splitWord(word string) []string {
+	var parts []string
+	for len(word) > 0 {
+		matched := ""
+		lower := strings.ToLower(word)
+		for _, known := range knownWords {
+			if strings.HasPrefix(lower, known) {
+				matched = word[:len(known)]
+				break
+			}
+		}
+		if matched == "" {
+			_, w := utf8.DecodeRuneInString(word)
+			matched = word[:w]
+		}
+		parts = append(parts, matched)
+		word = word[len(matched):]
+	}
+	return parts
+}
+
+func isKnownWord(word string) bool {
+	_, isFunc := Functions[word]
+	_, isCommand := Commands[word]
+	return isFunc || isCommand || namedSymbols[word]
+}
+
+// exponentLength is the length of the exponent that s, following the digits
+// of a number, starts with: e or E, an optional sign and at least one digit,
+// as in 1e-5 or 2.5E3. Anything else, such as the 2e of 2e^x, leaves the e
+// to be read as a name.
+func exponentLength(s string) int {
+	if len(s) < 2 || (s[0] != 'e' && s[0] != 'E') {
+		return 0
+	}
+	n := 1
+	if s[n] == '+' || s[n] == '-' {
+		n++
+	}
+	start := n
+	for n < len(s) && isDigit(s[n]) {
+		n++
+	}
+	if n == start {
+		return 0
+	}
+	return n
+}
+
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+func isASCIILetter(b byte) bool {
+	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
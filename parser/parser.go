@@ -0,0 +1,384 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+var relationOps = map[string]bool{"=": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true}
+
+var (
+	expectedOperand  = []string{"number", "variable", "function", "'('", "'|'"}
+	expectedOperator = []string{"operator", "end of input"}
+)
+
+// MaxDepth bounds how deeply parentheses, signs and exponents may nest, so
+// hostile input such as thousands of unary minus signs fails fast instead of
+// recursing through every later stage.
+const MaxDepth = 100
+
+// MaxExponent bounds the exponent of numbers written like 1e-5, which are
+// read exactly: 1e1000000000 would otherwise take a billion digits.
+const MaxExponent = 10000
+
+type parser struct {
+	tokens []token
+	pos    int
+	depth  int
+}
+
+// Parse parses a complete problem: an expression, an equation or inequality,
+// or a command such as "factor x^2 - 1". On failure the error is a *Error.
+func Parse(input string) (Node, error) {
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	if p.peek().kind == tokenEOF {
+		return nil, p.errorf(p.peek(), nil, "empty expression")
+	}
+
+	node, err := p.parseStatement()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, p.unexpected(t, expectedOperator)
+	}
+	return node, nil
+}
+
+// ParseExpression parses input that must be a plain expression, without
+// relations or commands.
+func ParseExpression(input string) (Node, error) {
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	if p.peek().kind == tokenEOF {
+		return nil, p.errorf(p.peek(), nil, "empty expression")
+	}
+
+	node, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, p.unexpected(t, []string{"'+'", "'-'", "'*'", "'/'", "'^'", "end of input"})
+	}
+	return node, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isOp(text string) bool {
+	t := p.peek()
+	return t.kind == tokenOp && t.text == text
+}
+
+func (p *parser) expectOp(text string) error {
+	if !p.isOp(text) {
+		return p.unexpected(p.peek(), []string{"'" + text + "'"})
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) errorf(t token, expected []string, format string, args ...interface{}) *Error {
+	found := t.text
+	if t.kind == tokenEOF {
+		found = ""
+	}
+	return &Error{
+		Offset:   t.offset,
+		Column:   t.column,
+		Message:  fmt.Sprintf(format, args...),
+		Found:    found,
+		Expected: expected,
+	}
+}
+
+func (p *parser) unexpected(t token, expected []string) *Error {
+	return p.errorf(t, expected, "unexpected %s", t.describe())
+}
+
+func (p *parser) parseStatement() (Node, error) {
+	if t := p.peek(); t.kind == tokenIdent {
+		if _, ok := Commands[t.text]; ok {
+			return p.parseCommand()
+		}
+	}
+	return p.parseRelation()
+}
+
+// parseCommand accepts both expand((x+1)^2) and expand (x+1)^2.
+func (p *parser) parseCommand() (Node, error) {
+	name := p.next()
+	if p.isOp("(") {
+		start := p.pos
+		call, err := p.parseArgs(name, Commands[name.text], true)
+		if err == nil && p.peek().kind == tokenEOF {
+			return call, nil
+		}
+		// Not a call spanning the whole input, e.g. "expand (x+1)^2"
+		p.pos = start
+	}
+
+	body, err := p.parseRelation()
+	if err != nil {
+		return nil, err
+	}
+	args := []Node{body}
+	if p.isOp(",") {
+		p.next()
+		v := p.next()
+		if v.kind != tokenIdent {
+			return nil, p.unexpected(v, []string{"variable"})
+		}
+		args = append(args, &Ident{Offset: v.offset, Name: v.text})
+	}
+	if arity := Commands[name.text]; len(args) > arity[1] {
+		return nil, p.errorf(name, nil, "%s takes at most %d argument(s)", name.text, arity[1])
+	}
+	return &Call{Offset: name.offset, Func: name.text, Args: args}, nil
+}
+
+func (p *parser) parseRelation() (Node, error) {
+	left, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	if t.kind != tokenOp || !relationOps[t.text] {
+		return left, nil
+	}
+	p.next()
+	right, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if next := p.peek(); next.kind == tokenOp && relationOps[next.text] {
+		return nil, p.errorf(next, nil, "chained comparisons are not supported")
+	}
+	return &Relation{Op: t.text, Left: left, Right: right}, nil
+}
+
+func (p *parser) parseExpr() (Node, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("+") || p.isOp("-") {
+		op := p.next().text
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		left = &Binary{Op: op, X: left, Y: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseTerm() (Node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		switch {
+		case p.isOp("*") || p.isOp("/"):
+			p.next()
+			right, err := p.parseUnary()
+			if err != nil {
+				return nil, err
+			}
+			left = &Binary{Op: t.text, X: left, Y: right}
+		case t.kind == tokenIdent || p.isOp("("):
+			// Implicit multiplication: 2x, 3(x+1), (x+1)(x-1), x sin(x)
+			right, err := p.parsePower()
+			if err != nil {
+				return nil, err
+			}
+			left = &Binary{Op: "*", X: left, Y: right, Implicit: true}
+		case t.kind == tokenNumber:
+			return nil, p.errorf(t, []string{"operator"}, "missing operator before number %s", t.text)
+		default:
+			return left, nil
+		}
+	}
+}
+
+func (p *parser) parseUnary() (Node, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > MaxDepth {
+		return nil, p.errorf(p.peek(), nil, "expression nested more than %d levels deep", MaxDepth)
+	}
+
+	if p.isOp("-") || p.isOp("+") {
+		t := p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &Unary{Offset: t.offset, Op: t.text, X: x}, nil
+	}
+	return p.parsePower()
+}
+
+func (p *parser) parsePower() (Node, error) {
+	base, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if p.isOp("^") {
+		p.next()
+		// Right associative, and the exponent may carry a sign: 2^-x
+		exp, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &Binary{Op: "^", X: base, Y: exp}, nil
+	}
+	return base, nil
+}
+
+func (p *parser) parsePrimary() (Node, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokenNumber:
+		p.next()
+		if strings.HasSuffix(t.text, ".") {
+			return nil, p.errorf(t, []string{"digit"}, "malformed number %s", t.text)
+		}
+		return &Number{Offset: t.offset, Text: t.text}, nil
+	case tokenIdent:
+		p.next()
+		if arity, ok := Functions[t.text]; ok {
+			return p.parseFunction(t, arity)
+		}
+		if _, ok := Commands[t.text]; ok {
+			return nil, p.errorf(t, nil, "%s can only appear at the start of the problem", t.text)
+		}
+		return &Ident{Offset: t.offset, Name: t.text}, nil
+	case tokenOp:
+		switch t.text {
+		case "(":
+			p.next()
+			x, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectOp(")"); err != nil {
+				return nil, err
+			}
+			return x, nil
+		case "|":
+			p.next()
+			x, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectOp("|"); err != nil {
+				return nil, err
+			}
+			return &Call{Offset: t.offset, Func: "abs", Args: []Node{x}}, nil
+		}
+	}
+	if t.kind == tokenEOF {
+		return nil, p.errorf(t, expectedOperand, "unexpected end of input")
+	}
+	return nil, p.unexpected(t, expectedOperand)
+}
+
+// parseFunction handles sin(x), sin x and sin^2(x).
+func (p *parser) parseFunction(name token, arity [2]int) (Node, error) {
+	var power Node
+	if p.isOp("^") {
+		p.next()
+		exp, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		power = exp
+	}
+
+	var call Node
+	if p.isOp("(") {
+		c, err := p.parseArgs(name, arity, false)
+		if err != nil {
+			return nil, err
+		}
+		call = c
+	} else {
+		if arity[0] > 1 {
+			return nil, p.unexpected(p.peek(), []string{"'('"})
+		}
+		arg, err := p.parsePower()
+		if err != nil {
+			return nil, err
+		}
+		call = &Call{Offset: name.offset, Func: name.text, Args: []Node{arg}}
+	}
+
+	if power != nil {
+		return &Binary{Op: "^", X: call, Y: power}, nil
+	}
+	return call, nil
+}
+
+// parseArgs parses a parenthesised argument list. Relations are only allowed
+// as command arguments, as in solve(x^2 = 4, x).
+func (p *parser) parseArgs(name token, arity [2]int, allowRelations bool) (*Call, error) {
+	if err := p.expectOp("("); err != nil {
+		return nil, err
+	}
+	var args []Node
+	for {
+		var arg Node
+		var err error
+		if allowRelations {
+			arg, err = p.parseRelation()
+		} else {
+			arg, err = p.parseExpr()
+		}
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if !p.isOp(",") {
+			break
+		}
+		p.next()
+	}
+	if !p.isOp(")") {
+		expected := []string{"')'"}
+		if len(args) < arity[1] {
+			expected = []string{"','", "')'"}
+		}
+		return nil, p.unexpected(p.peek(), expected)
+	}
+	p.next()
+
+	if len(args) < arity[0] || len(args) > arity[1] {
+		want := fmt.Sprintf("%d", arity[0])
+		if arity[0] != arity[1] {
+			want = fmt.Sprintf("%d to %d", arity[0], arity[1])
+		}
+		return nil, p.errorf(name, nil, "%s takes %s argument(s), got %d", name.text, want, len(args))
+	}
+	return &Call{Offset: name.offset, Func: name.text, Args: args}, nil
+}
@@ -0,0 +1,84 @@
+package parser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"2x + 3", "2*x + 3"},
+		{"(x+1)(x-1)", "(x + 1)*(x - 1)"},
+		{"2^-x", "2^(-x)"},
+		{"x^2 = 4", "x^2 = 4"},
+		{"sin x", "sin(x)"},
+		{"|x - 1|", "abs(x - 1)"},
+		{"factor x^2 - 1", "factor(x^2 - 1)"},
+		{"1e-5", "1e-5"},
+		{"2.50E+03 + x", "2.5e3 + x"},
+		{".5e-03", "0.5e-3"},
+		{"1e0", "1"},
+		{"2e^x", "2*e^x"},
+		{"3e - 5", "3*e - 5"},
+		{"2e", "2*e"},
+	}
+	for _, tt := range tests {
+		n, err := Parse(tt.input)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.input, err)
+			continue
+		}
+		if got := Format(n); got != tt.want {
+			t.Errorf("Format(Parse(%q)) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		input  string
+		column int
+	}{
+		{"", 1},
+		{"2 +", 4},
+		{"(x + 1", 7},
+		{"1 < x < 2", 7},
+		{"2 3", 3},
+		{"x + 1e10001", 5},
+		{"1e-99999999999999999999", 1},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.input)
+		var parseErr *Error
+		if !errors.As(err, &parseErr) {
+			t.Errorf("Parse(%q) error = %v, want *Error", tt.input, err)
+			continue
+		}
+		if parseErr.Column != tt.column {
+			t.Errorf("Parse(%q) error at column %d, want %d: %v", tt.input, parseErr.Column, tt.column, err)
+		}
+	}
+}
+
+func TestParseDepthLimit(t *testing.T) {
+	tests := []struct {
+		input string
+		ok    bool
+	}{
+		{strings.Repeat("-", MaxDepth-1) + "1", true},
+		{strings.Repeat("(", MaxDepth-1) + "1" + strings.Repeat(")", MaxDepth-1), true},
+		{strings.Repeat("-", 200000) + "1", false},
+		{strings.Repeat("(", MaxDepth+1) + "1" + strings.Repeat(")", MaxDepth+1), false},
+		{strings.Repeat("2^", MaxDepth+1) + "2", false},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.input)
+		if (err == nil) != tt.ok {
+			t.Errorf("Parse(%.20q...) error = %v, want ok = %v", tt.input, err, tt.ok)
+		}
+	}
+}
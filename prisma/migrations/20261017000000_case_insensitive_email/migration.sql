@@ -0,0 +1,5 @@
+-- Emails are unique ignoring case. Merge existing duplicates with
+-- `go run ./cmd/merge-users -apply` before applying this migration.
+
+-- CreateIndex
+CREATE UNIQUE INDEX "User_email_lower_key" ON "public"."User"(lower("email"));
@@ -1,6 +1,8 @@
 package routes
 
 import (
+	"context"
+
 	"maths-solution-backend/config"
 	"maths-solution-backend/database"
 	"maths-solution-backend/handlers"
@@ -10,7 +12,9 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRoutes(cfg *config.Config) *gin.Engine {
+// SetupRoutes builds the router and starts the job workers, which run until
+// ctx is cancelled. The returned function waits for them to stop.
+func SetupRoutes(ctx context.Context, cfg *config.Config) (*gin.Engine, func()) {
 	// Set Gin mode
 	gin.SetMode(cfg.Server.GinMode)
 
@@ -22,13 +26,41 @@ func SetupRoutes(cfg *config.Config) *gin.Engine {
 	r.Use(middleware.CORSMiddleware(cfg))
 
 	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(cfg)
-	mathHandler := handlers.NewMathHandler(cfg)
-	usageHandler := handlers.NewUsageHandler(services.NewUsageService(database.DB))
+	revocations := services.NewTokenRevocations(database.DB, cfg.JWT.RevocationCacheTTL)
+	authHandler := handlers.NewAuthHandler(cfg, revocations, services.NewMailer(cfg.Mail))
+	solvers := services.NewDefaultSolverRegistry(cfg)
+	if cfg.Cache.Size > 0 {
+		// The shared tier needs a database; without one the cache stays in memory
+		cacheDB := database.DB
+		if !cfg.Cache.Shared {
+			cacheDB = nil
+		}
+		solvers.SetCache(services.NewSolutionCache(cfg.Cache.Size, cfg.Cache.TTL, cacheDB))
+	}
+	mathHandler := handlers.NewMathHandler(cfg, solvers)
+	usageService := services.NewUsageService(database.DB, cfg.Usage)
+	usageHandler := handlers.NewUsageHandler(usageService)
+
+	// Jobs live in Postgres, so there is no queue without a database
+	var jobQueue *services.JobQueue
+	waitWorkers := func() {}
+	if database.DB != nil {
+		jobQueue = services.NewJobQueue(database.DB, cfg.Jobs, mathHandler.RunJob, usageService)
+		waitWorkers = jobQueue.Start(ctx)
+	}
+	jobHandler := handlers.NewJobHandler(mathHandler, jobQueue)
 
-	// Health check
+	// Health check; an open circuit breaker degrades but does not fail it,
+	// since solves still succeed through the fallback solver
 	r.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{"status": "ok"})
+		status := "ok"
+		breakers := solvers.BreakerStatuses()
+		for _, breaker := range breakers {
+			if breaker.State != services.BreakerClosed {
+				status = "degraded"
+			}
+		}
+		c.JSON(200, gin.H{"status": status, "circuit_breakers": breakers})
 	})
 
 	// Auth routes (public)
@@ -36,20 +68,39 @@ func SetupRoutes(cfg *config.Config) *gin.Engine {
 	{
 		auth.POST("/register", authHandler.Register)
 		auth.POST("/login", authHandler.Login)
+		auth.POST("/refresh", authHandler.Refresh)
+		auth.POST("/password/forgot", authHandler.ForgotPassword)
+		auth.POST("/password/reset", authHandler.ResetPassword)
+		auth.GET("/verify", authHandler.VerifyEmail)
+		auth.POST("/verify/resend", middleware.AuthMiddleware(cfg, revocations), authHandler.ResendVerification)
+		auth.POST("/logout", middleware.AuthMiddleware(cfg, revocations), authHandler.Logout)
+		auth.POST("/logout-all", middleware.AuthMiddleware(cfg, revocations), authHandler.LogoutAll)
 	}
 
 	// Protected routes
 	api := r.Group("/api")
-	api.Use(middleware.AuthMiddleware(cfg))
+	api.Use(middleware.AuthMiddleware(cfg, revocations))
 	{
 		api.POST("/solve-math", mathHandler.SolveMath)
+		api.POST("/solve-math/stream", mathHandler.SolveMathStream)
+		api.POST("/solve-system", mathHandler.SolveSystem)
+		api.POST("/solve-batch", mathHandler.SolveBatch)
+		api.POST("/check-work", mathHandler.CheckWork)
+		api.POST("/answers/compare", mathHandler.CompareAnswers)
+		api.POST("/practice", mathHandler.CreatePractice)
+		api.GET("/practice/:id", mathHandler.GetPractice)
+		api.POST("/practice/:id/grade", mathHandler.GradePractice)
+		api.POST("/jobs/solve", jobHandler.SubmitJob)
+		api.GET("/jobs/:id", jobHandler.GetJob)
+		api.POST("/cache/invalidate", middleware.AdminMiddleware(cfg), mathHandler.InvalidateCache)
+		api.POST("/solutions/:id/reveal", mathHandler.RevealStep)
 		api.GET("/history", mathHandler.GetHistory)
 		api.GET("/usage", usageHandler.GetUsageStats)
 		api.GET("/usage/check", usageHandler.CheckUsageLimit)
 	}
 
 	// Legacy route for backward compatibility
-	r.POST("/solve-math", middleware.AuthMiddleware(cfg), mathHandler.SolveMath)
+	r.POST("/solve-math", middleware.AuthMiddleware(cfg, revocations), mathHandler.SolveMath)
 
-	return r
+	return r, waitWorkers
 }
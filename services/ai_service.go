@@ -2,17 +2,25 @@ package services
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
+	"math/rand"
 	"maths-solution-backend/config"
 	"maths-solution-backend/models"
+	"net"
 	"net/http"
+	"strings"
+	"time"
 )
 
 type AIService struct {
-	config *config.Config
-	client *http.Client
+	config  *config.Config
+	client  *http.Client
+	breaker *CircuitBreaker
 }
 
 func NewAIService(cfg *config.Config) *AIService {
@@ -21,11 +29,19 @@ func NewAIService(cfg *config.Config) *AIService {
 		client: &http.Client{
 			Timeout: cfg.AI.Timeout,
 		},
+		breaker: NewCircuitBreaker(cfg.AI.BreakerThreshold, cfg.AI.BreakerCooldown),
 	}
 }
 
+// BreakerStatus reports the circuit breaker guarding the AI service.
+func (s *AIService) BreakerStatus() BreakerStatus {
+	return s.breaker.Status()
+}
+
 type AIRequest struct {
 	Expression string `json:"expression"`
+	Precision  int    `json:"precision,omitempty"`
+	Stream     bool   `json:"stream,omitempty"` // Ask for newline-delimited JSON events
 }
 
 type AIResponse struct {
@@ -33,8 +49,109 @@ type AIResponse struct {
 	Final string                `json:"final"`
 }
 
-func (s *AIService) SolveMath(expression string) (*AIResponse, error) {
-	reqBody := AIRequest{Expression: expression}
+// AIStreamEvent is one line of a streamed AI service response; exactly one
+// field is set.
+type AIStreamEvent struct {
+	Step  *models.SolutionStep `json:"step,omitempty"`
+	Final *string              `json:"final,omitempty"`
+	Error string               `json:"error,omitempty"`
+}
+
+func (s *AIService) Name() string {
+	return "remote"
+}
+
+func (s *AIService) Capabilities() []Capability {
+	return []Capability{CapabilityGeneral}
+}
+
+// Solve implements Solver by forwarding the expression to the remote AI service.
+func (s *AIService) Solve(ctx context.Context, req SolveRequest) (*SolveResult, error) {
+	aiResp, err := s.SolveMath(ctx, req.Expression, req.Precision)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SolveResult{
+		Steps:  aiResp.Steps,
+		Final:  aiResp.Final,
+		Solver: s.Name(),
+	}, nil
+}
+
+// SolveStream implements StreamingSolver. The AI service answers a stream
+// request with application/x-ndjson events; a plain JSON response from an
+// older service is replayed step by step.
+func (s *AIService) SolveStream(ctx context.Context, req SolveRequest, onStep StepFunc) (*SolveResult, error) {
+	resp, err := s.post(ctx, AIRequest{Expression: req.Expression, Precision: req.Precision, Stream: true})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	result := &SolveResult{Solver: s.Name()}
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/x-ndjson") {
+		var aiResp AIResponse
+		if err := json.NewDecoder(resp.Body).Decode(&aiResp); err != nil {
+			return nil, fmt.Errorf("failed to decode AI service response: %w", err)
+		}
+		for _, step := range aiResp.Steps {
+			onStep(step)
+		}
+		result.Steps, result.Final = aiResp.Steps, aiResp.Final
+		return result, nil
+	}
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var event AIStreamEvent
+		if err := decoder.Decode(&event); err == io.EOF {
+			break
+		} else if ctx.Err() != nil {
+			return nil, ctx.Err()
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to decode AI service stream: %w", err)
+		}
+		switch {
+		case event.Error != "":
+			return nil, fmt.Errorf("AI service failed: %s", event.Error)
+		case event.Step != nil:
+			result.Steps = append(result.Steps, *event.Step)
+			onStep(*event.Step)
+		case event.Final != nil:
+			result.Final = *event.Final
+		}
+	}
+	if result.Final == "" {
+		return nil, fmt.Errorf("AI service stream ended without a final answer")
+	}
+	return result, nil
+}
+
+// SolveMath asks the AI service to solve expression. Cancelling ctx aborts
+// the call, including any retries.
+func (s *AIService) SolveMath(ctx context.Context, expression string, precision int) (*AIResponse, error) {
+	resp, err := s.post(ctx, AIRequest{Expression: expression, Precision: precision})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var aiResp AIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&aiResp); err != nil {
+		return nil, fmt.Errorf("failed to decode AI service response: %w", err)
+	}
+
+	return &aiResp, nil
+}
+
+// post sends reqBody to the AI service's /solve endpoint and returns the
+// response of a successful call; the caller closes its body. Connection
+// errors and 502/503/504 are retried with jittered exponential backoff, and
+// calls are short-circuited while the circuit breaker is open. Transport
+// errors, timeouts and every 5xx count as breaker failures; only a 4xx shows
+// that the service is up even though the call failed.
+func (s *AIService) post(ctx context.Context, reqBody AIRequest) (*http.Response, error) {
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
@@ -51,7 +168,63 @@ func (s *AIService) SolveMath(expression string) (*AIResponse, error) {
 	fullURL := serviceURL + "/solve"
 	fmt.Printf("DEBUG: Calling AI service at: %s\n", fullURL)
 
-	req, err := http.NewRequest("POST", fullURL, bytes.NewBuffer(jsonData))
+	if err := s.breaker.Allow(); err != nil {
+		return nil, fmt.Errorf("AI service unavailable: %w", err)
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := s.do(ctx, fullURL, jsonData)
+		if err == nil {
+			s.breaker.Success()
+			return resp, nil
+		}
+		if ctx.Err() != nil {
+			// Cancelled by the caller; says nothing about the service's health
+			s.breaker.Release()
+			return nil, ctx.Err()
+		}
+
+		var callErr *aiCallError
+		if !errors.As(err, &callErr) {
+			// The request could not be built; the service was never reached
+			s.breaker.Release()
+			return nil, err
+		}
+		if callErr.healthy {
+			s.breaker.Success()
+			return nil, err
+		}
+		if !callErr.retryable || attempt >= s.config.AI.MaxRetries {
+			s.breaker.Failure()
+			return nil, err
+		}
+
+		delay := s.backoff(attempt)
+		log.Printf("[warn] AI service call failed (attempt %d), retrying in %s: %v", attempt+1, delay, err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			s.breaker.Release()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// aiCallError is a failed call that reached, or tried to reach, the AI
+// service.
+type aiCallError struct {
+	err       error
+	retryable bool // Worth another attempt
+	healthy   bool // The service answered sensibly, e.g. with a 4xx
+}
+
+func (e *aiCallError) Error() string { return e.err.Error() }
+
+func (e *aiCallError) Unwrap() error { return e.err }
+
+// do makes a single call. Failures of the call itself are *aiCallError.
+func (s *AIService) do(ctx context.Context, fullURL string, jsonData []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", fullURL, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -60,19 +233,36 @@ func (s *AIService) SolveMath(expression string) (*AIResponse, error) {
 
 	resp, err := s.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to call AI service: %w", err)
+		// Timeouts are not retried: the client already waited the full AI_SERVICE_TIMEOUT
+		var netErr net.Error
+		retryable := !(errors.As(err, &netErr) && netErr.Timeout())
+		return nil, &aiCallError{err: fmt.Errorf("failed to call AI service: %w", err), retryable: retryable}
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("AI service returned status %d: %s", resp.StatusCode, string(body))
+		return nil, &aiCallError{
+			err: fmt.Errorf("AI service returned status %d: %s", resp.StatusCode, string(body)),
+			retryable: resp.StatusCode == http.StatusBadGateway ||
+				resp.StatusCode == http.StatusServiceUnavailable ||
+				resp.StatusCode == http.StatusGatewayTimeout,
+			healthy: resp.StatusCode < http.StatusInternalServerError,
+		}
 	}
 
-	var aiResp AIResponse
-	if err := json.NewDecoder(resp.Body).Decode(&aiResp); err != nil {
-		return nil, fmt.Errorf("failed to decode AI service response: %w", err)
-	}
+	return resp, nil
+}
 
-	return &aiResp, nil
+// backoff returns the delay before retry attempt+1: the base delay doubled
+// per attempt, capped, with the upper half randomised.
+func (s *AIService) backoff(attempt int) time.Duration {
+	delay := s.config.AI.RetryBaseDelay << attempt
+	if delay > s.config.AI.RetryMaxDelay || delay <= 0 {
+		delay = s.config.AI.RetryMaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
 }
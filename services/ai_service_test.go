@@ -0,0 +1,173 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"maths-solution-backend/config"
+	"maths-solution-backend/models"
+)
+
+func TestAIServiceBreakerOutcomes(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		state   string // Breaker state after two failed calls with a threshold of two
+	}{
+		{"500 opens", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}, BreakerOpen},
+		{"503 opens after retries", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}, BreakerOpen},
+		{"timeout opens", func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(200 * time.Millisecond)
+		}, BreakerOpen},
+		{"400 keeps it closed", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+		}, BreakerClosed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+
+			s := NewAIService(&config.Config{AI: config.AIConfig{
+				ServiceURL:       server.URL,
+				Timeout:          50 * time.Millisecond,
+				MaxRetries:       1,
+				BreakerThreshold: 2,
+				BreakerCooldown:  time.Minute,
+			}})
+			for i := 0; i < 2; i++ {
+				if _, err := s.Solve(context.Background(), SolveRequest{Expression: "1 + 1"}); err == nil {
+					t.Fatalf("call %d succeeded", i+1)
+				}
+			}
+			if state := s.BreakerStatus().State; state != tt.state {
+				t.Errorf("breaker %s, want %s", state, tt.state)
+			}
+		})
+	}
+}
+
+func TestAIServiceSolveStream(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		steps       int
+		final       string
+		wantErr     bool
+	}{
+		{"ndjson", "application/x-ndjson",
+			`{"step":{"index":1,"latex":"x = 2"}}` + "\n" + `{"step":{"index":2,"latex":"x = 2"}}` + "\n" + `{"final":"2"}` + "\n",
+			2, "2", false},
+		{"plain json", "application/json", `{"steps":[{"index":1,"latex":"x = 2"}],"final":"2"}`, 1, "2", false},
+		{"error event", "application/x-ndjson", `{"step":{"index":1,"latex":"x"}}` + "\n" + `{"error":"overloaded"}` + "\n", 1, "", true},
+		{"no final", "application/x-ndjson", `{"step":{"index":1,"latex":"x"}}` + "\n", 1, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			s := NewAIService(&config.Config{AI: config.AIConfig{
+				ServiceURL:       server.URL,
+				Timeout:          time.Second,
+				BreakerThreshold: 5,
+				BreakerCooldown:  time.Minute,
+			}})
+			streamed := 0
+			result, err := s.SolveStream(context.Background(), SolveRequest{Expression: "x - 2 = 0"}, func(models.SolutionStep) {
+				streamed++
+			})
+			if streamed != tt.steps {
+				t.Errorf("streamed %d steps, want %d", streamed, tt.steps)
+			}
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SolveStream: %v", err)
+			}
+			if result.Final != tt.final || len(result.Steps) != tt.steps {
+				t.Errorf("result = %d steps, final %q; want %d steps, final %q", len(result.Steps), result.Final, tt.steps, tt.final)
+			}
+		})
+	}
+}
+
+func TestAIServiceCancelled(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		cancel  time.Duration // After the call starts; 0 cancels before it
+	}{
+		{"before the call", func(w http.ResponseWriter, r *http.Request) {}, 0},
+		{"while waiting for the service", func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(300 * time.Millisecond):
+			}
+		}, 20 * time.Millisecond},
+		{"while backing off", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}, 20 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+
+			s := NewAIService(&config.Config{AI: config.AIConfig{
+				ServiceURL:       server.URL,
+				Timeout:          5 * time.Second,
+				MaxRetries:       3,
+				RetryBaseDelay:   time.Second,
+				RetryMaxDelay:    time.Second,
+				BreakerThreshold: 1,
+				BreakerCooldown:  time.Minute,
+			}})
+			ctx, cancel := context.WithCancel(context.Background())
+			if tt.cancel == 0 {
+				cancel()
+			} else {
+				time.AfterFunc(tt.cancel, cancel)
+			}
+			defer cancel()
+
+			start := time.Now()
+			_, err := s.Solve(ctx, SolveRequest{Expression: "1 + 1"})
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("error = %v, want context.Canceled", err)
+			}
+			if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+				t.Errorf("returned after %s, want promptly", elapsed)
+			}
+			if state := s.BreakerStatus().State; state != BreakerClosed {
+				t.Errorf("breaker %s after cancellation, want %s", state, BreakerClosed)
+			}
+		})
+	}
+}
+
+func TestLocalSolverCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, expression := range []string{"2 + 2", "x^2 - 4 = 0", "d/dx x^3"} {
+		if _, err := NewLocalSolver().Solve(ctx, SolveRequest{Expression: expression}); !errors.Is(err, context.Canceled) {
+			t.Errorf("Solve(%q) error = %v, want context.Canceled", expression, err)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := NewCircuitBreaker(2, time.Minute)
+	b.now = func() time.Time { return now }
+
+	steps := []struct {
+		name    string
+		advance time.Duration
+		outcome func()
+		allowed bool
+		state   string
+	}{
+		{"first failure", 0, b.Failure, true, BreakerClosed},
+		{"second failure opens", 0, b.Failure, true, BreakerOpen},
+		{"rejected while open", 30 * time.Second, nil, false, BreakerOpen},
+		{"trial after cooldown fails", 31 * time.Second, b.Failure, true, BreakerOpen},
+		{"trial after cooldown succeeds", time.Minute, b.Success, true, BreakerClosed},
+		{"success resets the count", 0, b.Failure, true, BreakerClosed},
+	}
+	for _, st := range steps {
+		now = now.Add(st.advance)
+		err := b.Allow()
+		if allowed := err == nil; allowed != st.allowed {
+			t.Fatalf("%s: Allow() = %v, want allowed = %v", st.name, err, st.allowed)
+		}
+		if err != nil && !errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("%s: Allow() = %v, want ErrCircuitOpen", st.name, err)
+		}
+		if st.outcome != nil {
+			st.outcome()
+		}
+		if state := b.Status().State; state != st.state {
+			t.Fatalf("%s: state %s, want %s", st.name, state, st.state)
+		}
+	}
+}
+
+func TestCircuitBreakerSingleTrial(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := NewCircuitBreaker(1, time.Minute)
+	b.now = func() time.Time { return now }
+	_ = b.Allow()
+	b.Failure()
+
+	now = now.Add(time.Minute)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("trial Allow() = %v", err)
+	}
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("second Allow() during the trial = %v, want ErrCircuitOpen", err)
+	}
+	b.Release()
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Allow() after a released trial = %v", err)
+	}
+}
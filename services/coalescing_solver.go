@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"sync"
+
+	"maths-solution-backend/models"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Coalescer lets concurrent identical solves share one in-flight call. The
+// shared call runs on its own context, cancelled only once every caller
+// waiting on it has gone away, so one impatient client cannot fail the rest.
+type Coalescer struct {
+	group   singleflight.Group
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+type flight struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	waiters int
+}
+
+func NewCoalescer() *Coalescer {
+	return &Coalescer{flights: make(map[string]*flight)}
+}
+
+// Do runs fn once for all concurrent callers with the same key and hands each
+// of them its own copy of the result.
+func (c *Coalescer) Do(ctx context.Context, key string, fn func(ctx context.Context) (*SolveResult, error)) (*SolveResult, error) {
+	c.mu.Lock()
+	f, ok := c.flights[key]
+	if !ok {
+		callCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		f = &flight{ctx: callCtx, cancel: cancel}
+		c.flights[key] = f
+	}
+	f.waiters++
+	ch := c.group.DoChan(key, func() (interface{}, error) {
+		return fn(f.ctx)
+	})
+	c.mu.Unlock()
+	defer c.leave(key, f)
+
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return copyResult(res.Val.(*SolveResult)), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// leave drops a caller from f, cancelling the shared call when it was the
+// last one so that a later caller starts afresh.
+func (c *Coalescer) leave(key string, f *flight) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f.waiters--
+	if f.waiters > 0 {
+		return
+	}
+	f.cancel()
+	if c.flights[key] == f {
+		delete(c.flights, key)
+		c.group.Forget(key)
+	}
+}
+
+// copyResult keeps callers from seeing each other's edits to a shared result.
+func copyResult(result *SolveResult) *SolveResult {
+	out := *result
+	out.Steps = append([]models.SolutionStep(nil), result.Steps...)
+	return &out
+}
+
+// CoalescingSolver deduplicates concurrent identical solves through a
+// Coalescer. Streaming solves are passed through, since each stream needs its
+// steps as they are produced.
+type CoalescingSolver struct {
+	solver    Solver
+	coalescer *Coalescer
+}
+
+func NewCoalescingSolver(solver Solver, coalescer *Coalescer) *CoalescingSolver {
+	return &CoalescingSolver{solver: solver, coalescer: coalescer}
+}
+
+func (s *CoalescingSolver) Name() string {
+	return s.solver.Name()
+}
+
+func (s *CoalescingSolver) Capabilities() []Capability {
+	return s.solver.Capabilities()
+}
+
+func (s *CoalescingSolver) Solve(ctx context.Context, req SolveRequest) (*SolveResult, error) {
+	return s.coalescer.Do(ctx, CacheKey(s.Name(), req), func(ctx context.Context) (*SolveResult, error) {
+		return s.solver.Solve(ctx, req)
+	})
+}
+
+func (s *CoalescingSolver) SolveStream(ctx context.Context, req SolveRequest, onStep StepFunc) (*SolveResult, error) {
+	return SolveStream(ctx, s.solver, req, onStep)
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"maths-solution-backend/models"
+)
+
+func TestCoalescer(t *testing.T) {
+	tests := []struct {
+		name      string
+		keys      []string // One concurrent caller per key
+		cancelled int      // Callers that give up before the call finishes
+		calls     int32
+	}{
+		{"identical callers share a call", []string{"a", "a", "a"}, 0, 1},
+		{"different keys do not", []string{"a", "b", "a"}, 0, 2},
+		{"one caller leaving does not fail the rest", []string{"a", "a", "a"}, 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCoalescer()
+			var calls atomic.Int32
+			release := make(chan struct{})
+			fn := func(ctx context.Context) (*SolveResult, error) {
+				calls.Add(1)
+				select {
+				case <-release:
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				}
+				return &SolveResult{Steps: []models.SolutionStep{{Index: 1}}, Final: "2"}, nil
+			}
+
+			var wg sync.WaitGroup
+			results := make([]*SolveResult, len(tt.keys))
+			errs := make([]error, len(tt.keys))
+			cancels := make([]context.CancelFunc, len(tt.keys))
+			for i, key := range tt.keys {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancels[i] = cancel
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					results[i], errs[i] = c.Do(ctx, key, fn)
+				}()
+			}
+			for calls.Load() < tt.calls {
+				time.Sleep(time.Millisecond)
+			}
+			time.Sleep(10 * time.Millisecond) // Let every caller join its flight
+			for i := 0; i < tt.cancelled; i++ {
+				cancels[i]()
+			}
+			time.Sleep(10 * time.Millisecond)
+			close(release)
+			wg.Wait()
+			for _, cancel := range cancels {
+				cancel()
+			}
+
+			if got := calls.Load(); got != tt.calls {
+				t.Errorf("fn ran %d times, want %d", got, tt.calls)
+			}
+			for i := range tt.keys {
+				if i < tt.cancelled {
+					if !errors.Is(errs[i], context.Canceled) {
+						t.Errorf("cancelled caller %d: error = %v, want context.Canceled", i, errs[i])
+					}
+					continue
+				}
+				if errs[i] != nil || results[i].Final != "2" {
+					t.Errorf("caller %d: result %v, error %v", i, results[i], errs[i])
+				}
+			}
+			// Each caller owns its result
+			results[len(results)-1].Steps[0].Index = 99
+			for i := tt.cancelled; i < len(results)-1; i++ {
+				if results[i].Steps[0].Index != 1 {
+					t.Errorf("caller %d sees another caller's edit", i)
+				}
+			}
+		})
+	}
+}
+
+func TestCoalescerCancelsAbandonedCall(t *testing.T) {
+	c := NewCoalescer()
+	ctx, cancel := context.WithCancel(context.Background())
+	callErr := make(chan error, 1)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	_, err := c.Do(ctx, "a", func(ctx context.Context) (*SolveResult, error) {
+		<-ctx.Done()
+		callErr <- ctx.Err()
+		return nil, ctx.Err()
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want context.Canceled", err)
+	}
+	select {
+	case err := <-callErr:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("shared call ended with %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("shared call kept running after its only caller left")
+	}
+
+	// A later caller starts afresh instead of joining the abandoned call
+	result, err := c.Do(context.Background(), "a", func(ctx context.Context) (*SolveResult, error) {
+		return &SolveResult{Final: "1"}, nil
+	})
+	if err != nil || result.Final != "1" {
+		t.Errorf("later call: result %v, error %v", result, err)
+	}
+}
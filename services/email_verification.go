@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"maths-solution-backend/config"
+	"maths-solution-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrVerificationTokenInvalid is returned for unknown, used or expired
+	// email verification tokens.
+	ErrVerificationTokenInvalid = errors.New("invalid or expired verification token")
+	// ErrEmailAlreadyVerified is returned when asking to verify a verified
+	// address again.
+	ErrEmailAlreadyVerified = errors.New("email already verified")
+)
+
+// ResendThrottledError is returned when a verification or password reset
+// email was asked for too recently to send another.
+type ResendThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ResendThrottledError) Error() string {
+	return fmt.Sprintf("email sent recently; retry in %s", e.RetryAfter.Round(time.Second))
+}
+
+// EmailVerificationService emails single-use verification links, stored
+// only as SHA-256 hashes, and marks addresses verified when one is opened.
+type EmailVerificationService struct {
+	db             *gorm.DB
+	mailer         Mailer
+	ttl            time.Duration
+	resendInterval time.Duration
+	apiURL         string
+	now            func() time.Time
+}
+
+func NewEmailVerificationService(db *gorm.DB, mailer Mailer, cfg config.MailConfig) *EmailVerificationService {
+	return &EmailVerificationService{
+		db:             db,
+		mailer:         mailer,
+		ttl:            cfg.VerificationTTL,
+		resendInterval: cfg.ResendInterval,
+		apiURL:         strings.TrimRight(cfg.APIURL, "/"),
+		now:            time.Now,
+	}
+}
+
+// Send emails user a verification link, replacing any earlier one. The
+// email is sent in the background.
+func (s *EmailVerificationService) Send(user *models.User) error {
+	raw, err := randomToken(32)
+	if err != nil {
+		return err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		now := s.now()
+		if err := tx.Model(&models.EmailVerificationToken{}).
+			Where("user_id = ? AND used_at IS NULL", user.ID).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.EmailVerificationToken{
+			UserID:    user.ID,
+			TokenHash: hashToken(token),
+			ExpiresAt: now.Add(s.ttl),
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	link := s.apiURL + "/auth/verify?token=" + url.QueryEscape(token)
+	msg := Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hello %s,\n\nPlease confirm that this is your email address by opening\n\n%s\n\nThe link expires in %s. Until then your account has a reduced daily limit. If you did not create an account, ignore this email.\n",
+			user.FullName, link, s.ttl.Round(time.Minute)),
+	}
+	userID := user.ID
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if err := s.mailer.Send(ctx, msg); err != nil {
+			log.Printf("[warn] Failed to send verification email to user %d: %v", userID, err)
+		}
+	}()
+	return nil
+}
+
+// Resend sends userID a new verification link unless their email is
+// already verified or a link was sent less than the resend interval ago,
+// in which case it returns a *ResendThrottledError.
+func (s *EmailVerificationService) Resend(userID uint) error {
+	var user models.User
+	if err := s.db.Where("id = ?", userID).First(&user).Error; err != nil {
+		return err
+	}
+	if user.EmailVerifiedAt != nil {
+		return ErrEmailAlreadyVerified
+	}
+
+	var last models.EmailVerificationToken
+	res := s.db.Where("user_id = ?", userID).Order("created_at DESC").Limit(1).Find(&last)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected > 0 {
+		if wait := last.CreatedAt.Add(s.resendInterval).Sub(s.now()); wait > 0 {
+			return &ResendThrottledError{RetryAfter: wait}
+		}
+	}
+	return s.Send(&user)
+}
+
+// Verify redeems token and marks the address of its user verified. A link
+// already used to verify the address keeps working.
+func (s *EmailVerificationService) Verify(token string) (*models.User, error) {
+	var user models.User
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var verification models.EmailVerificationToken
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", hashToken(token)).
+			First(&verification).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrVerificationTokenInvalid
+		}
+		if err != nil {
+			return err
+		}
+		res := tx.Where("id = ?", verification.UserID).Limit(1).Find(&user)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrVerificationTokenInvalid
+		}
+
+		// Opening a used link again, as mail scanners do before the user
+		// does, still confirms the address
+		now := s.now()
+		if verification.UsedAt != nil && user.EmailVerifiedAt != nil {
+			return nil
+		}
+		if verification.UsedAt != nil || !now.Before(verification.ExpiresAt) {
+			return ErrVerificationTokenInvalid
+		}
+
+		if err := tx.Model(&verification).Update("used_at", now).Error; err != nil {
+			return err
+		}
+		if user.EmailVerifiedAt == nil {
+			user.EmailVerifiedAt = &now
+			return tx.Model(&user).Update("email_verified_at", now).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
@@ -0,0 +1,321 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"maths-solution-backend/config"
+	"maths-solution-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrJobRetryable marks job failures worth another attempt, such as an
+// unavailable solver. Other errors fail the job at once.
+var ErrJobRetryable = errors.New("retryable")
+
+// JobProcessor solves a claimed job, saving and charging it like a
+// synchronous solve.
+type JobProcessor func(ctx context.Context, job *models.SolveJob) (*models.SolveMathResponse, error)
+
+// JobQueue runs SolveJobs stored in Postgres on a pool of workers. Every
+// state change is written back to the job row, so queued jobs, jobs
+// interrupted mid-solve and undelivered webhooks are all picked up again
+// after a restart.
+type JobQueue struct {
+	db      *gorm.DB
+	config  config.JobsConfig
+	process JobProcessor
+	usage   *UsageService // Refunds the solve reserved for jobs that fail
+	webhook *WebhookSender
+	wake    chan struct{}
+	now     func() time.Time
+}
+
+func NewJobQueue(db *gorm.DB, cfg config.JobsConfig, process JobProcessor, usage *UsageService) *JobQueue {
+	return &JobQueue{
+		db:      db,
+		config:  cfg,
+		process: process,
+		usage:   usage,
+		webhook: NewWebhookSender(cfg.WebhookSecret, cfg.WebhookTimeout, cfg.WebhookAllowedHosts),
+		wake:    make(chan struct{}, 1),
+		now:     time.Now,
+	}
+}
+
+// WebhooksEnabled reports whether jobs may ask for a webhook; unsigned
+// callbacks are never sent.
+func (q *JobQueue) WebhooksEnabled() bool {
+	return q.config.WebhookSecret != ""
+}
+
+// CheckWebhookURL rejects webhook URLs the workers would refuse to call.
+func (q *JobQueue) CheckWebhookURL(raw string) error {
+	return q.webhook.CheckURL(raw)
+}
+
+// Enqueue stores job as queued and wakes an idle worker.
+func (q *JobQueue) Enqueue(ctx context.Context, job *models.SolveJob) error {
+	job.Status = models.JobQueued
+	job.RunAt = q.now()
+	if job.WebhookURL != "" {
+		job.WebhookStatus = models.WebhookPending
+	}
+	if err := q.db.WithContext(ctx).Create(job).Error; err != nil {
+		return err
+	}
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Get returns the job id of userID.
+func (q *JobQueue) Get(ctx context.Context, userID, id uint) (*models.SolveJob, error) {
+	var job models.SolveJob
+	if err := q.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).First(&job).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Start runs the workers until ctx is cancelled and returns a function that
+// waits for them to stop. Jobs interrupted by the cancellation are put back
+// on the queue.
+func (q *JobQueue) Start(ctx context.Context) (wait func()) {
+	var wg sync.WaitGroup
+	for i := 0; i < q.config.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work(ctx)
+		}()
+	}
+	if q.config.Workers > 0 {
+		log.Printf("[info] Started %d job workers", q.config.Workers)
+	}
+	return wg.Wait
+}
+
+func (q *JobQueue) work(ctx context.Context) {
+	for ctx.Err() == nil {
+		job, err := q.claim(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("[warn] Failed to claim job: %v", err)
+		}
+		if job == nil {
+			select {
+			case <-ctx.Done():
+			case <-q.wake:
+			case <-time.After(q.config.PollInterval):
+			}
+			continue
+		}
+		q.run(ctx, job)
+	}
+}
+
+// lease is how long a claimed job stays locked; a worker that has not
+// finished by then is presumed dead.
+func (q *JobQueue) lease() time.Duration {
+	return 2*q.config.Timeout + q.config.WebhookTimeout
+}
+
+// claim locks the next due job, skipping rows other workers hold, and marks
+// it running when it still needs solving.
+func (q *JobQueue) claim(ctx context.Context) (*models.SolveJob, error) {
+	var job models.SolveJob
+	now := q.now()
+	err := q.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("(status IN ? OR webhook_status = ?) AND run_at <= ? AND (locked_at IS NULL OR locked_at < ?)",
+				[]string{models.JobQueued, models.JobRunning}, models.WebhookPending, now, now.Add(-q.lease())).
+			Order("run_at, id").
+			First(&job).Error
+		if err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{"locked_at": now}
+		if !jobFinished(&job) {
+			job.Attempts++
+			job.Status = models.JobRunning
+			updates["status"] = job.Status
+			updates["attempts"] = job.Attempts
+			if job.StartedAt == nil {
+				job.StartedAt = &now
+				updates["started_at"] = now
+			}
+		}
+		job.LockedAt = &now
+		return tx.Model(&job).Updates(updates).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func jobFinished(job *models.SolveJob) bool {
+	return job.Status == models.JobSucceeded || job.Status == models.JobFailed
+}
+
+// run solves job if it still needs it, then delivers its webhook, saving the
+// outcome of each phase so a restart resumes where this one stopped.
+func (q *JobQueue) run(ctx context.Context, job *models.SolveJob) {
+	if !jobFinished(job) {
+		if !q.solve(ctx, job) {
+			return
+		}
+	}
+	if job.WebhookStatus == models.WebhookPending {
+		q.deliver(ctx, job)
+	}
+}
+
+// solve runs one attempt and reports whether the job reached a final state.
+func (q *JobQueue) solve(ctx context.Context, job *models.SolveJob) bool {
+	response, err := q.attempt(ctx, job)
+
+	now := q.now()
+	updates := map[string]interface{}{"locked_at": nil}
+	switch {
+	case ctx.Err() != nil:
+		// Shutting down: give the attempt back rather than spend it
+		updates["status"] = models.JobQueued
+		updates["attempts"] = job.Attempts - 1
+		q.save(job, updates)
+		return false
+	case err == nil:
+		responseJSON, _ := json.Marshal(response)
+		job.Status = models.JobSucceeded
+		job.ResponseJSON = string(responseJSON)
+		job.Error = ""
+	case errors.Is(err, ErrJobRetryable) && job.Attempts < q.config.MaxAttempts:
+		log.Printf("[warn] Job %d attempt %d failed, retrying: %v", job.ID, job.Attempts, err)
+		updates["status"] = models.JobQueued
+		updates["error"] = err.Error()
+		updates["run_at"] = now.Add(jobBackoff(job.Attempts))
+		q.save(job, updates)
+		return false
+	default:
+		job.Status = models.JobFailed
+		job.Error = err.Error()
+		q.releaseUsage(job)
+	}
+
+	job.FinishedAt = &now
+	updates["status"] = job.Status
+	updates["response_json"] = job.ResponseJSON
+	updates["error"] = job.Error
+	updates["finished_at"] = now
+	if job.WebhookStatus == models.WebhookPending {
+		// Keep the lock while the webhook goes out straight away
+		delete(updates, "locked_at")
+	}
+	q.save(job, updates)
+	return true
+}
+
+// releaseUsage refunds the solve reserved when a failed job was submitted.
+func (q *JobQueue) releaseUsage(job *models.SolveJob) {
+	if job.UsageDate == "" || q.usage == nil {
+		return
+	}
+	reservation := &UsageReservation{UserID: job.UserID, Date: job.UsageDate, Granted: 1}
+	if err := q.usage.ReleaseUsage(reservation, 1); err != nil {
+		log.Printf("[warn] Failed to refund usage of job %d: %v", job.ID, err)
+	}
+}
+
+// attempt runs the processor under the per-attempt timeout, turning a panic
+// into a failed job instead of a dead worker.
+func (q *JobQueue) attempt(ctx context.Context, job *models.SolveJob) (response *models.SolveMathResponse, err error) {
+	ctx, cancel := context.WithTimeout(ctx, q.config.Timeout)
+	defer cancel()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return q.process(ctx, job)
+}
+
+// deliver POSTs the job to its webhook, rescheduling failed deliveries with
+// backoff until the attempts run out.
+func (q *JobQueue) deliver(ctx context.Context, job *models.SolveJob) {
+	body, err := json.Marshal(JobResponse(job))
+	if err != nil {
+		return
+	}
+	job.WebhookAttempts++
+	err = q.webhook.Send(ctx, job.WebhookURL, body)
+
+	updates := map[string]interface{}{"locked_at": nil, "webhook_attempts": job.WebhookAttempts}
+	switch {
+	case err == nil:
+		updates["webhook_status"] = models.WebhookDelivered
+	case ctx.Err() != nil:
+		updates["webhook_attempts"] = job.WebhookAttempts - 1
+	case job.WebhookAttempts < q.config.WebhookAttempts:
+		log.Printf("[warn] Webhook of job %d failed, retrying: %v", job.ID, err)
+		updates["run_at"] = q.now().Add(jobBackoff(job.WebhookAttempts))
+	default:
+		log.Printf("[warn] Webhook of job %d failed, giving up: %v", job.ID, err)
+		updates["webhook_status"] = models.WebhookFailed
+	}
+	q.save(job, updates)
+}
+
+// save writes updates to job's row even while shutting down.
+func (q *JobQueue) save(job *models.SolveJob, updates map[string]interface{}) {
+	if err := q.db.Model(job).Updates(updates).Error; err != nil {
+		log.Printf("[warn] Failed to update job %d: %v", job.ID, err)
+	}
+}
+
+// jobBackoff is 5s, 10s, 20s, ... capped at 10 minutes.
+func jobBackoff(attempt int) time.Duration {
+	delay := 5 * time.Second
+	for i := 1; i < attempt && delay < 10*time.Minute; i++ {
+		delay *= 2
+	}
+	if delay > 10*time.Minute {
+		delay = 10 * time.Minute
+	}
+	return delay
+}
+
+// JobResponse is the API view of job, also sent to its webhook.
+func JobResponse(job *models.SolveJob) models.SolveJobResponse {
+	response := models.SolveJobResponse{
+		ID:            job.ID,
+		Status:        job.Status,
+		Expression:    job.Expression,
+		Solver:        job.Solver,
+		Attempts:      job.Attempts,
+		Error:         job.Error,
+		WebhookStatus: job.WebhookStatus,
+		CreatedAt:     job.CreatedAt,
+		StartedAt:     job.StartedAt,
+		FinishedAt:    job.FinishedAt,
+	}
+	if job.Status == models.JobSucceeded && job.ResponseJSON != "" {
+		var result models.SolveMathResponse
+		if err := json.Unmarshal([]byte(job.ResponseJSON), &result); err == nil {
+			response.Result = &result
+		}
+	}
+	return response
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"log"
+
+	"maths-solution-backend/mathengine"
+	"maths-solution-backend/models"
+)
+
+// LocalSolver solves problems in-process with the built-in symbolic engine.
+type LocalSolver struct {
+	engine *mathengine.Engine
+}
+
+func NewLocalSolver() *LocalSolver {
+	return &LocalSolver{engine: mathengine.NewEngine()}
+}
+
+func (s *LocalSolver) Name() string {
+	return "local"
+}
+
+func (s *LocalSolver) Capabilities() []Capability {
+	return []Capability{CapabilityArithmetic, CapabilityAlgebra, CapabilityEquations, CapabilityCalculus}
+}
+
+// Solve runs the engine, which stops with ctx.Err() once ctx is done.
+func (s *LocalSolver) Solve(ctx context.Context, req SolveRequest) (*SolveResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	result, err := s.engine.SolveContext(ctx, req.Expression, mathengine.Options{Precision: req.Precision})
+	if err != nil {
+		return nil, err
+	}
+
+	return &SolveResult{
+		Steps:  result.Steps,
+		Final:  result.Final,
+		Solver: s.Name(),
+	}, nil
+}
+
+// FallbackSolver tries the primary solver and falls back to a secondary one
+// when the primary fails, e.g. because the AI service is down or timed out.
+type FallbackSolver struct {
+	primary  Solver
+	fallback Solver
+}
+
+func NewFallbackSolver(primary, fallback Solver) *FallbackSolver {
+	return &FallbackSolver{primary: primary, fallback: fallback}
+}
+
+func (s *FallbackSolver) Name() string {
+	return s.primary.Name()
+}
+
+func (s *FallbackSolver) Capabilities() []Capability {
+	return s.primary.Capabilities()
+}
+
+// Solve does not fall back once ctx is cancelled: nobody is waiting for the answer.
+func (s *FallbackSolver) Solve(ctx context.Context, req SolveRequest) (*SolveResult, error) {
+	result, err := s.primary.Solve(ctx, req)
+	if err == nil || ctx.Err() != nil {
+		return result, err
+	}
+
+	log.Printf("[warn] Solver %s failed, falling back to %s: %v", s.primary.Name(), s.fallback.Name(), err)
+	fallbackResult, fallbackErr := s.fallback.Solve(ctx, req)
+	if fallbackErr != nil {
+		// Report the primary failure; it is the more meaningful one for the caller
+		return nil, err
+	}
+	return fallbackResult, nil
+}
+
+// SolveStream falls back only while nothing has been streamed: steps already
+// sent for the primary cannot be taken back.
+func (s *FallbackSolver) SolveStream(ctx context.Context, req SolveRequest, onStep StepFunc) (*SolveResult, error) {
+	streamed := false
+	result, err := SolveStream(ctx, s.primary, req, func(step models.SolutionStep) {
+		streamed = true
+		onStep(step)
+	})
+	if err == nil || streamed || ctx.Err() != nil {
+		return result, err
+	}
+
+	log.Printf("[warn] Solver %s failed, falling back to %s: %v", s.primary.Name(), s.fallback.Name(), err)
+	fallbackResult, fallbackErr := SolveStream(ctx, s.fallback, req, onStep)
+	if fallbackErr != nil {
+		return nil, err
+	}
+	return fallbackResult, nil
+}
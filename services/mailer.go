@@ -0,0 +1,145 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"maths-solution-backend/config"
+)
+
+// Message is a plain-text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers email. Implementations exist for SMTP, for writing .eml
+// files to a directory and for logging, so that email flows run locally
+// without a mail server.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// NewMailer returns the transport selected by MAIL_TRANSPORT, logging when
+// it is empty or unknown.
+func NewMailer(cfg config.MailConfig) Mailer {
+	switch strings.ToLower(strings.TrimSpace(cfg.Transport)) {
+	case "smtp":
+		return &SMTPMailer{
+			Addr: net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(cfg.SMTPPort)),
+			Host: cfg.SMTPHost,
+			User: cfg.SMTPUsername,
+			Pass: cfg.SMTPPassword,
+			From: cfg.From,
+		}
+	case "file":
+		return &FileMailer{Dir: cfg.Dir, From: cfg.From}
+	case "log", "":
+		log.Printf("[warn] MAIL_TRANSPORT=log redacts the tokens of verification and password reset links; set MAIL_TRANSPORT=file to write email to %s instead", cfg.Dir)
+	default:
+		log.Printf("[warn] Unknown MAIL_TRANSPORT %q, logging email instead", cfg.Transport)
+	}
+	return &LogMailer{From: cfg.From}
+}
+
+// SMTPMailer sends through an SMTP server, authenticating with PLAIN when a
+// user is set. The connection is upgraded with STARTTLS when offered.
+type SMTPMailer struct {
+	Addr string // host:port
+	Host string
+	User string
+	Pass string
+	From string
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return fmt.Errorf("invalid sender %q: %w", m.From, err)
+	}
+	var auth smtp.Auth
+	if m.User != "" {
+		auth = smtp.PlainAuth("", m.User, m.Pass, m.Host)
+	}
+
+	// net/smtp has no context support; give up waiting once ctx is done
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(m.Addr, auth, from.Address, []string{msg.To}, formatMessage(m.From, msg))
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// FileMailer writes each message as an .eml file in Dir, which most mail
+// clients can open.
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+	suffix, err := randomToken(4)
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%x.eml", time.Now().UTC().Format("20060102T150405.000000000Z"), suffix)
+	return os.WriteFile(filepath.Join(m.Dir, name), formatMessage(m.From, msg), 0o600)
+}
+
+// LogMailer writes messages to the log instead of delivering them. The
+// tokens of links are redacted, since logs are read far more widely than
+// mailboxes; use the file transport to follow links locally.
+type LogMailer struct {
+	From string
+}
+
+var linkToken = regexp.MustCompile(`([?&]token=)[^&\s]+`)
+
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	log.Printf("[info] Email to %s: %s\n%s", msg.To, msg.Subject, redactTokens(msg.Body))
+	return nil
+}
+
+// redactTokens replaces the token parameters of links in body.
+func redactTokens(body string) string {
+	return linkToken.ReplaceAllString(body, "${1}[redacted]")
+}
+
+// formatMessage renders msg as an RFC 5322 message with CRLF line endings.
+func formatMessage(from string, msg Message) []byte {
+	var b bytes.Buffer
+	header := func(name, value string) {
+		b.WriteString(name + ": " + value + "\r\n")
+	}
+	header("From", from)
+	header("To", msg.To)
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=utf-8")
+	header("Content-Transfer-Encoding", "8bit")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+	return b.Bytes()
+}
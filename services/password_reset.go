@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"maths-solution-backend/config"
+	"maths-solution-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrResetTokenInvalid is returned for unknown, used or expired password
+// reset tokens.
+var ErrResetTokenInvalid = errors.New("invalid or expired reset token")
+
+// resetsPerClient bounds the reset emails one client may ask for per resend
+// interval, whichever addresses they are for.
+const resetsPerClient = 5
+
+// PasswordResetService emails single-use password reset tokens, stored only
+// as SHA-256 hashes, and redeems them.
+type PasswordResetService struct {
+	db             *gorm.DB
+	mailer         Mailer
+	ttl            time.Duration
+	resendInterval time.Duration
+	appURL         string
+	now            func() time.Time
+
+	mu      sync.Mutex
+	clients map[string][]time.Time // Times of each client IP's recent requests
+	swept   time.Time
+}
+
+func NewPasswordResetService(db *gorm.DB, mailer Mailer, cfg config.MailConfig) *PasswordResetService {
+	return &PasswordResetService{
+		db:             db,
+		mailer:         mailer,
+		ttl:            cfg.PasswordResetTTL,
+		resendInterval: cfg.ResendInterval,
+		appURL:         strings.TrimRight(cfg.AppURL, "/"),
+		now:            time.Now,
+		clients:        make(map[string][]time.Time),
+	}
+}
+
+// Request emails a reset link to the user registered with email, replacing
+// any earlier link. Unknown addresses, and addresses sent a link less than
+// the resend interval ago, are ignored so that callers cannot tell which
+// addresses are registered; the email is sent in the background for the
+// same reason. A client asking for more links than resetsPerClient within
+// the interval gets a *ResendThrottledError instead.
+func (s *PasswordResetService) Request(email, clientIP string) error {
+	if wait := s.throttleClient(clientIP); wait > 0 {
+		return &ResendThrottledError{RetryAfter: wait}
+	}
+
+	var user models.User
+	res := s.db.Where("lower(email) = lower(?)", strings.TrimSpace(email)).Limit(1).Find(&user)
+	if res.Error != nil || res.RowsAffected == 0 {
+		return res.Error
+	}
+
+	var last models.PasswordResetToken
+	res = s.db.Where("user_id = ?", user.ID).Order("created_at DESC").Limit(1).Find(&last)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected > 0 && s.now().Before(last.CreatedAt.Add(s.resendInterval)) {
+		return nil
+	}
+
+	raw, err := randomToken(32)
+	if err != nil {
+		return err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		now := s.now()
+		if err := tx.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", user.ID).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.PasswordResetToken{
+			UserID:    user.ID,
+			TokenHash: hashToken(token),
+			ExpiresAt: now.Add(s.ttl),
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	link := s.appURL + "/reset-password?token=" + url.QueryEscape(token)
+	msg := Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hello %s,\n\nSomeone asked to reset the password of your account. To choose a new password, open\n\n%s\n\nThe link works once and expires in %s. If you did not ask for it, ignore this email; your password stays the same.\n",
+			user.FullName, link, s.ttl.Round(time.Minute)),
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if err := s.mailer.Send(ctx, msg); err != nil {
+			log.Printf("[warn] Failed to send password reset email to user %d: %v", user.ID, err)
+		}
+	}()
+	return nil
+}
+
+// throttleClient records a request from clientIP and returns how long the
+// client must wait when it already made resetsPerClient requests within
+// the resend interval.
+func (s *PasswordResetService) throttleClient(clientIP string) time.Duration {
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Forget idle clients, at most once per interval
+	if now.Sub(s.swept) >= s.resendInterval {
+		for client, times := range s.clients {
+			if !now.Before(times[len(times)-1].Add(s.resendInterval)) {
+				delete(s.clients, client)
+			}
+		}
+		s.swept = now
+	}
+
+	var recent []time.Time
+	for _, t := range s.clients[clientIP] {
+		if now.Before(t.Add(s.resendInterval)) {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= resetsPerClient {
+		s.clients[clientIP] = recent
+		return recent[0].Add(s.resendInterval).Sub(now)
+	}
+	s.clients[clientIP] = append(recent, now)
+	return 0
+}
+
+// Reset redeems token, setting the password of its user to passwordHash,
+// and returns the user's ID. The token cannot be used again.
+func (s *PasswordResetService) Reset(token, passwordHash string) (uint, error) {
+	var userID uint
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var reset models.PasswordResetToken
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", hashToken(token)).
+			First(&reset).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrResetTokenInvalid
+		}
+		if err != nil {
+			return err
+		}
+		now := s.now()
+		if reset.UsedAt != nil || !now.Before(reset.ExpiresAt) {
+			return ErrResetTokenInvalid
+		}
+
+		if err := tx.Model(&reset).Update("used_at", now).Error; err != nil {
+			return err
+		}
+		res := tx.Model(&models.User{}).Where("id = ?", reset.UserID).Update("password", passwordHash)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			// The user was deleted after asking for the reset
+			return ErrResetTokenInvalid
+		}
+		userID = reset.UserID
+		return nil
+	})
+	return userID, err
+}
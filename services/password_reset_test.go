@@ -0,0 +1,52 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPasswordResetThrottleClient(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	s := &PasswordResetService{
+		resendInterval: time.Minute,
+		now:            func() time.Time { return now },
+		clients:        make(map[string][]time.Time),
+	}
+
+	for i := 0; i < resetsPerClient; i++ {
+		if wait := s.throttleClient("203.0.113.1"); wait != 0 {
+			t.Fatalf("request %d throttled for %s, want allowed", i+1, wait)
+		}
+		now = now.Add(time.Second)
+	}
+	if wait := s.throttleClient("203.0.113.1"); wait != time.Minute-resetsPerClient*time.Second {
+		t.Errorf("request %d throttled for %s, want %s", resetsPerClient+1, wait, time.Minute-resetsPerClient*time.Second)
+	}
+	if wait := s.throttleClient("203.0.113.2"); wait != 0 {
+		t.Errorf("other client throttled for %s, want allowed", wait)
+	}
+
+	now = now.Add(time.Minute)
+	if wait := s.throttleClient("203.0.113.1"); wait != 0 {
+		t.Errorf("request after the interval throttled for %s, want allowed", wait)
+	}
+	if _, ok := s.clients["203.0.113.2"]; ok {
+		t.Error("idle client not forgotten")
+	}
+}
+
+func TestRedactTokens(t *testing.T) {
+	tests := []struct {
+		body, want string
+	}{
+		{"open\n\nhttp://localhost:3000/reset-password?token=abc-DEF_123\n\nThe link", "open\n\nhttp://localhost:3000/reset-password?token=[redacted]\n\nThe link"},
+		{"http://api/auth/verify?token=abc&next=%2F", "http://api/auth/verify?token=[redacted]&next=%2F"},
+		{"http://api/x?a=1&token=abc", "http://api/x?a=1&token=[redacted]"},
+		{"no links here", "no links here"},
+	}
+	for _, tt := range tests {
+		if got := redactTokens(tt.body); got != tt.want {
+			t.Errorf("redactTokens(%q) = %q, want %q", tt.body, got, tt.want)
+		}
+	}
+}
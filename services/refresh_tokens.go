@@ -0,0 +1,147 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"maths-solution-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrRefreshTokenInvalid is returned for unknown, expired or revoked
+	// refresh tokens.
+	ErrRefreshTokenInvalid = errors.New("invalid refresh token")
+	// ErrRefreshTokenReused is returned when a refresh token that was already
+	// rotated is presented again. Its whole family has been revoked, since
+	// either the client or an attacker holds a stolen copy.
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+)
+
+// RefreshTokenService issues and rotates refresh tokens, which are stored
+// only as SHA-256 hashes.
+type RefreshTokenService struct {
+	db  *gorm.DB
+	ttl time.Duration
+	now func() time.Time
+}
+
+func NewRefreshTokenService(db *gorm.DB, ttl time.Duration) *RefreshTokenService {
+	return &RefreshTokenService{db: db, ttl: ttl, now: time.Now}
+}
+
+// Issue starts a new token family for a fresh login and returns its first
+// token.
+func (s *RefreshTokenService) Issue(userID uint) (string, error) {
+	family, err := randomToken(16)
+	if err != nil {
+		return "", err
+	}
+	return s.issue(s.db, userID, hex.EncodeToString(family))
+}
+
+// Rotate exchanges token for a new one in the same family and returns the
+// user it belongs to. The presented token cannot be used again; presenting
+// it again revokes the family and returns ErrRefreshTokenReused.
+func (s *RefreshTokenService) Rotate(token string) (uint, string, error) {
+	var userID uint
+	var next string
+	reused := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var current models.RefreshToken
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", hashToken(token)).
+			First(&current).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrRefreshTokenInvalid
+		}
+		if err != nil {
+			return err
+		}
+
+		now := s.now()
+		switch {
+		case current.RevokedAt != nil:
+			return ErrRefreshTokenInvalid
+		case current.UsedAt != nil:
+			// Commit the revocation; the error is reported after the transaction
+			reused = true
+			return tx.Model(&models.RefreshToken{}).
+				Where("family_id = ? AND revoked_at IS NULL", current.FamilyID).
+				Update("revoked_at", now).Error
+		case !now.Before(current.ExpiresAt):
+			return ErrRefreshTokenInvalid
+		}
+
+		if err := tx.Model(&current).Update("used_at", now).Error; err != nil {
+			return err
+		}
+		userID = current.UserID
+		next, err = s.issue(tx, current.UserID, current.FamilyID)
+		return err
+	})
+	if err != nil {
+		return 0, "", err
+	}
+	if reused {
+		return 0, "", ErrRefreshTokenReused
+	}
+	return userID, next, nil
+}
+
+// Revoke revokes the family of token when it belongs to userID. Unknown
+// tokens are ignored.
+func (s *RefreshTokenService) Revoke(userID uint, token string) error {
+	var current models.RefreshToken
+	res := s.db.Where("token_hash = ? AND user_id = ?", hashToken(token), userID).Limit(1).Find(&current)
+	if res.Error != nil || res.RowsAffected == 0 {
+		return res.Error
+	}
+	return s.db.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", current.FamilyID).
+		Update("revoked_at", s.now()).Error
+}
+
+// RevokeUser revokes every refresh token of userID.
+func (s *RefreshTokenService) RevokeUser(userID uint) error {
+	return s.db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", s.now()).Error
+}
+
+func (s *RefreshTokenService) issue(db *gorm.DB, userID uint, family string) (string, error) {
+	raw, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+	record := models.RefreshToken{
+		UserID:    userID,
+		TokenHash: hashToken(token),
+		FamilyID:  family,
+		ExpiresAt: s.now().Add(s.ttl),
+	}
+	if err := db.Create(&record).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func randomToken(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
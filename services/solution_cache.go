@@ -0,0 +1,230 @@
+package services
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"maths-solution-backend/mathengine"
+	"maths-solution-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SolutionCache keeps solver results keyed by canonical expression and solver
+// options in an in-memory LRU, optionally backed by a Postgres table shared
+// between instances. Entries expire after ttl in both tiers.
+type SolutionCache struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	entries  map[string]*list.Element
+	order    *list.List // Most recently used at the front
+	db       *gorm.DB   // Shared tier; nil keeps the cache in memory only
+	now      func() time.Time
+}
+
+type cacheEntry struct {
+	key        string
+	expression string
+	result     SolveResult
+	expiresAt  time.Time
+}
+
+func NewSolutionCache(capacity int, ttl time.Duration, db *gorm.DB) *SolutionCache {
+	return &SolutionCache{
+		capacity: capacity,
+		ttl:      ttl,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+		db:       db,
+		now:      time.Now,
+	}
+}
+
+// CacheKey identifies the result of solver for req. Precisions that round
+// the same way share a key.
+func CacheKey(solver string, req SolveRequest) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d\x00%s", solver, mathengine.ClampPrecision(req.Precision), req.Expression)))
+	return hex.EncodeToString(sum[:])
+}
+
+// Get returns a copy of the cached result for key, looking in memory first
+// and then in the shared tier.
+func (c *SolutionCache) Get(ctx context.Context, key string) (*SolveResult, bool) {
+	if result, ok := c.getMemory(key); ok {
+		return result, true
+	}
+	if c.db == nil {
+		return nil, false
+	}
+
+	var row models.CachedSolution
+	if err := c.db.WithContext(ctx).Where("key = ?", key).First(&row).Error; err != nil {
+		return nil, false
+	}
+	if !c.now().Before(row.ExpiresAt) {
+		_ = c.db.WithContext(ctx).Delete(&models.CachedSolution{}, "key = ?", key).Error
+		return nil, false
+	}
+	result := SolveResult{Final: row.Final, Solver: row.Solver}
+	if err := json.Unmarshal([]byte(row.StepsJSON), &result.Steps); err != nil {
+		return nil, false
+	}
+	c.setMemory(key, row.Expression, result, row.ExpiresAt)
+	return &result, true
+}
+
+// Set stores result for key in every tier. Failures of the shared tier are
+// logged and otherwise ignored.
+func (c *SolutionCache) Set(ctx context.Context, key, expression string, result *SolveResult) {
+	expiresAt := c.now().Add(c.ttl)
+	c.setMemory(key, expression, *result, expiresAt)
+	if c.db == nil {
+		return
+	}
+
+	stepsJSON, err := json.Marshal(result.Steps)
+	if err != nil {
+		return
+	}
+	row := models.CachedSolution{
+		Key:        key,
+		Expression: expression,
+		Solver:     result.Solver,
+		StepsJSON:  string(stepsJSON),
+		Final:      result.Final,
+		ExpiresAt:  expiresAt,
+	}
+	if err := c.db.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(&row).Error; err != nil {
+		log.Printf("[warn] Failed to store cached solution: %v", err)
+	}
+}
+
+// Invalidate drops the entry for key from every tier.
+func (c *SolutionCache) Invalidate(ctx context.Context, key string) {
+	c.mu.Lock()
+	if el, ok := c.entries[key]; ok {
+		c.removeLocked(el)
+	}
+	c.mu.Unlock()
+	if c.db != nil {
+		_ = c.db.WithContext(ctx).Delete(&models.CachedSolution{}, "key = ?", key).Error
+	}
+}
+
+// InvalidateExpression drops the entries of expression for every solver and
+// option, returning how many were removed from the authoritative tier.
+func (c *SolutionCache) InvalidateExpression(ctx context.Context, expression string) int64 {
+	var removed int64
+	c.mu.Lock()
+	for _, el := range c.entries {
+		if el.Value.(*cacheEntry).expression == expression {
+			c.removeLocked(el)
+			removed++
+		}
+	}
+	c.mu.Unlock()
+	if c.db != nil {
+		result := c.db.WithContext(ctx).Delete(&models.CachedSolution{}, "expression = ?", expression)
+		removed = result.RowsAffected
+	}
+	return removed
+}
+
+func (c *SolutionCache) getMemory(key string) (*SolveResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*cacheEntry)
+	if !c.now().Before(entry.expiresAt) {
+		c.removeLocked(el)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	result := entry.result
+	return &result, true
+}
+
+func (c *SolutionCache) setMemory(key, expression string, result SolveResult, expiresAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	result.Cached = false
+	entry := &cacheEntry{key: key, expression: expression, result: result, expiresAt: expiresAt}
+	if el, ok := c.entries[key]; ok {
+		el.Value = entry
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.capacity {
+		c.removeLocked(c.order.Back())
+	}
+}
+
+func (c *SolutionCache) removeLocked(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*cacheEntry).key)
+}
+
+// CachingSolver answers from a SolutionCache and caches what its solver
+// produces. Results of a fallback solver are not cached under the primary's
+// name, so they are not served once the primary recovers.
+type CachingSolver struct {
+	solver Solver
+	cache  *SolutionCache
+}
+
+func NewCachingSolver(solver Solver, cache *SolutionCache) *CachingSolver {
+	return &CachingSolver{solver: solver, cache: cache}
+}
+
+func (s *CachingSolver) Name() string {
+	return s.solver.Name()
+}
+
+func (s *CachingSolver) Capabilities() []Capability {
+	return s.solver.Capabilities()
+}
+
+func (s *CachingSolver) Solve(ctx context.Context, req SolveRequest) (*SolveResult, error) {
+	key := CacheKey(s.Name(), req)
+	if result, ok := s.cache.Get(ctx, key); ok {
+		result.Cached = true
+		return result, nil
+	}
+
+	result, err := s.solver.Solve(ctx, req)
+	if err == nil && result.Solver == s.Name() {
+		s.cache.Set(ctx, key, req.Expression, result)
+	}
+	return result, err
+}
+
+// SolveStream replays the steps of a cached result at once.
+func (s *CachingSolver) SolveStream(ctx context.Context, req SolveRequest, onStep StepFunc) (*SolveResult, error) {
+	key := CacheKey(s.Name(), req)
+	if result, ok := s.cache.Get(ctx, key); ok {
+		for _, step := range result.Steps {
+			onStep(step)
+		}
+		result.Cached = true
+		return result, nil
+	}
+
+	result, err := SolveStream(ctx, s.solver, req, onStep)
+	if err == nil && result.Solver == s.Name() {
+		s.cache.Set(ctx, key, req.Expression, result)
+	}
+	return result, err
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"maths-solution-backend/mathengine"
+)
+
+func TestCacheKey(t *testing.T) {
+	base := CacheKey("local", SolveRequest{Expression: "x + 1"})
+	tests := []struct {
+		name   string
+		solver string
+		req    SolveRequest
+		same   bool
+	}{
+		{"default precision spelled out", "local", SolveRequest{Expression: "x + 1", Precision: mathengine.DefaultPrecision}, true},
+		{"negative precision", "local", SolveRequest{Expression: "x + 1", Precision: -3}, true},
+		{"other precision", "local", SolveRequest{Expression: "x + 1", Precision: mathengine.DefaultPrecision + 1}, false},
+		{"other solver", "remote", SolveRequest{Expression: "x + 1"}, false},
+		{"other expression", "local", SolveRequest{Expression: "x + 2"}, false},
+	}
+	for _, tt := range tests {
+		if same := CacheKey(tt.solver, tt.req) == base; same != tt.same {
+			t.Errorf("%s: same key = %v, want %v", tt.name, same, tt.same)
+		}
+	}
+	if CacheKey("local", SolveRequest{Expression: "x + 1", Precision: mathengine.MaxPrecision + 5}) !=
+		CacheKey("local", SolveRequest{Expression: "x + 1", Precision: mathengine.MaxPrecision}) {
+		t.Errorf("precisions above the maximum get their own key")
+	}
+}
+
+func TestSolutionCacheMemory(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		run  func(c *SolutionCache)
+		want map[string]bool // Key to whether Get finds it afterwards
+	}{
+		{"hit", func(c *SolutionCache) {
+			c.Set(ctx, "a", "1+1", &SolveResult{Final: "2"})
+		}, map[string]bool{"a": true, "b": false}},
+		{"least recently used is evicted", func(c *SolutionCache) {
+			c.Set(ctx, "a", "1+1", &SolveResult{Final: "2"})
+			c.Set(ctx, "b", "2+2", &SolveResult{Final: "4"})
+			c.Get(ctx, "a")
+			c.Set(ctx, "c", "3+3", &SolveResult{Final: "6"})
+		}, map[string]bool{"a": true, "b": false, "c": true}},
+		{"expired", func(c *SolutionCache) {
+			c.Set(ctx, "a", "1+1", &SolveResult{Final: "2"})
+			now = now.Add(time.Hour)
+		}, map[string]bool{"a": false}},
+		{"invalidate key", func(c *SolutionCache) {
+			c.Set(ctx, "a", "1+1", &SolveResult{Final: "2"})
+			c.Set(ctx, "b", "2+2", &SolveResult{Final: "4"})
+			c.Invalidate(ctx, "a")
+		}, map[string]bool{"a": false, "b": true}},
+		{"invalidate expression", func(c *SolutionCache) {
+			c.Set(ctx, "a", "1+1", &SolveResult{Final: "2"})
+			c.Set(ctx, "b", "1+1", &SolveResult{Final: "2", Solver: "remote"})
+			if removed := c.InvalidateExpression(ctx, "1+1"); removed != 2 {
+				t.Errorf("InvalidateExpression removed %d, want 2", removed)
+			}
+		}, map[string]bool{"a": false, "b": false}},
+	}
+	for _, tt := range tests {
+		c := NewSolutionCache(2, time.Minute, nil)
+		c.now = func() time.Time { return now }
+		tt.run(c)
+		for key, want := range tt.want {
+			if _, ok := c.Get(ctx, key); ok != want {
+				t.Errorf("%s: Get(%q) found = %v, want %v", tt.name, key, ok, want)
+			}
+		}
+	}
+}
+
+func TestCachingSolver(t *testing.T) {
+	tests := []struct {
+		name       string
+		solver     *fakeSolver
+		calls      int32 // Calls reaching the solver for two identical solves
+		wantCached bool  // The second result is flagged as cached
+	}{
+		{"cached", &fakeSolver{name: "local", final: "2"}, 1, true},
+		{"errors are not cached", &fakeSolver{name: "local", err: errors.New("down")}, 2, false},
+		{"fallback results are not cached", &fakeSolver{name: "other", final: "2"}, 2, false},
+	}
+	for _, tt := range tests {
+		// Name the wrapper after "local" whatever solver actually answers
+		solver := NewCachingSolver(renamedSolver{tt.solver, "local"}, NewSolutionCache(10, time.Minute, nil))
+		var result *SolveResult
+		for i := 0; i < 2; i++ {
+			result, _ = solver.Solve(context.Background(), SolveRequest{Expression: "1 + 1"})
+		}
+		if calls := tt.solver.calls.Load(); calls != tt.calls {
+			t.Errorf("%s: solver called %d times, want %d", tt.name, calls, tt.calls)
+		}
+		if cached := result != nil && result.Cached; cached != tt.wantCached {
+			t.Errorf("%s: cached = %v, want %v", tt.name, cached, tt.wantCached)
+		}
+	}
+}
+
+// renamedSolver reports name while its results keep the inner solver's name,
+// as a FallbackSolver does when it falls back.
+type renamedSolver struct {
+	*fakeSolver
+	name string
+}
+
+func (s renamedSolver) Name() string { return s.name }
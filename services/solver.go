@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"maths-solution-backend/config"
+	"maths-solution-backend/models"
+)
+
+// Capability describes a class of problems a solver can handle.
+type Capability string
+
+const (
+	CapabilityArithmetic Capability = "arithmetic"
+	CapabilityAlgebra    Capability = "algebra"
+	CapabilityEquations  Capability = "equations"
+	CapabilityCalculus   Capability = "calculus"
+	CapabilityGeneral    Capability = "general"
+)
+
+// SolveRequest is the input handed to a Solver.
+type SolveRequest struct {
+	Expression string
+	Precision  int // Significant digits of decimal results; zero means the solver's default
+}
+
+// SolveResult is what every Solver returns, regardless of backend.
+type SolveResult struct {
+	Steps  []models.SolutionStep
+	Final  string
+	Solver string
+	Cached bool // Served from the SolutionCache
+}
+
+// Solver is implemented by every backend able to produce step-by-step
+// solutions. Solve stops early with ctx.Err() once ctx is cancelled.
+type Solver interface {
+	Name() string
+	Capabilities() []Capability
+	Solve(ctx context.Context, req SolveRequest) (*SolveResult, error)
+}
+
+// StepFunc receives each step as soon as a solver produces it.
+type StepFunc func(step models.SolutionStep)
+
+// StreamingSolver is implemented by solvers able to report steps while they
+// are still solving.
+type StreamingSolver interface {
+	Solver
+	SolveStream(ctx context.Context, req SolveRequest, onStep StepFunc) (*SolveResult, error)
+}
+
+// SolveStream solves req, passing each step to onStep as soon as it is
+// available. Solvers without streaming support report their steps at the end.
+func SolveStream(ctx context.Context, solver Solver, req SolveRequest, onStep StepFunc) (*SolveResult, error) {
+	if streaming, ok := solver.(StreamingSolver); ok {
+		return streaming.SolveStream(ctx, req, onStep)
+	}
+	result, err := solver.Solve(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	for _, step := range result.Steps {
+		onStep(step)
+	}
+	return result, nil
+}
+
+// SolverRegistry keeps the available solvers and picks one by name.
+type SolverRegistry struct {
+	mu           sync.RWMutex
+	solvers      map[string]Solver
+	defaultName  string
+	fallbackName string
+	cache        *SolutionCache
+	coalescer    *Coalescer // Shared by every solver Get returns
+}
+
+func NewSolverRegistry(defaultName string) *SolverRegistry {
+	return &SolverRegistry{
+		solvers:     make(map[string]Solver),
+		defaultName: defaultName,
+		coalescer:   NewCoalescer(),
+	}
+}
+
+// NewDefaultSolverRegistry registers the built-in solvers and selects the
+// default from configuration.
+func NewDefaultSolverRegistry(cfg *config.Config) *SolverRegistry {
+	registry := NewSolverRegistry(cfg.Solver.Default)
+	registry.Register(NewAIService(cfg))
+	registry.Register(NewLocalSolver())
+	registry.SetFallback(cfg.Solver.Fallback)
+	return registry
+}
+
+func (r *SolverRegistry) Register(solver Solver) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.solvers[solver.Name()] = solver
+}
+
+// SetFallback names the solver used when the selected one fails. A name that
+// is not registered, such as "none", disables fallback.
+func (r *SolverRegistry) SetFallback(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fallbackName = strings.ToLower(strings.TrimSpace(name))
+}
+
+// SetCache puts cache in front of every solver returned by Get; nil removes it.
+func (r *SolverRegistry) SetCache(cache *SolutionCache) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cache = cache
+}
+
+// Cache returns the solution cache, or nil when caching is disabled.
+func (r *SolverRegistry) Cache() *SolutionCache {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cache
+}
+
+// Get returns the solver with the given name, or the default one when name is
+// empty, wrapped with the fallback solver when one is configured, coalescing
+// concurrent identical solves, and behind the solution cache when one is set.
+func (r *SolverRegistry) Get(name string) (Solver, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		name = r.defaultName
+	}
+
+	solver, ok := r.solvers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown solver %q (available: %s)", name, strings.Join(r.namesLocked(), ", "))
+	}
+
+	if fallback, ok := r.solvers[r.fallbackName]; ok && r.fallbackName != name {
+		solver = NewFallbackSolver(solver, fallback)
+	}
+	solver = NewCoalescingSolver(solver, r.coalescer)
+	if r.cache != nil {
+		solver = NewCachingSolver(solver, r.cache)
+	}
+	return solver, nil
+}
+
+// BreakerStatuses reports the circuit breaker of every solver that has one,
+// by solver name.
+func (r *SolverRegistry) BreakerStatuses() map[string]BreakerStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	statuses := make(map[string]BreakerStatus)
+	for name, solver := range r.solvers {
+		if guarded, ok := solver.(interface{ BreakerStatus() BreakerStatus }); ok {
+			statuses[name] = guarded.BreakerStatus()
+		}
+	}
+	return statuses
+}
+
+func (r *SolverRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.namesLocked()
+}
+
+func (r *SolverRegistry) namesLocked() []string {
+	names := make([]string, 0, len(r.solvers))
+	for name := range r.solvers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
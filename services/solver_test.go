@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"maths-solution-backend/models"
+)
+
+// fakeSolver answers every request with final, or fails with err.
+type fakeSolver struct {
+	name  string
+	final string
+	err   error
+	calls atomic.Int32
+}
+
+func (s *fakeSolver) Name() string               { return s.name }
+func (s *fakeSolver) Capabilities() []Capability { return []Capability{CapabilityGeneral} }
+
+func (s *fakeSolver) Solve(ctx context.Context, req SolveRequest) (*SolveResult, error) {
+	s.calls.Add(1)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &SolveResult{
+		Steps:  []models.SolutionStep{{Index: 1, Latex: req.Expression}},
+		Final:  s.final,
+		Solver: s.name,
+	}, nil
+}
+
+func TestSolverRegistryGet(t *testing.T) {
+	registry := NewSolverRegistry("remote")
+	registry.Register(&fakeSolver{name: "remote", err: errors.New("down")})
+	registry.Register(&fakeSolver{name: "local", final: "4"})
+	registry.SetFallback(" Local ")
+
+	tests := []struct {
+		name    string
+		solver  string // Name of the solver that answers
+		wantErr bool
+	}{
+		{"", "local", false}, // The default fails and falls back
+		{"remote", "local", false},
+		{"LOCAL", "local", false},
+		{"wolfram", "", true},
+	}
+	for _, tt := range tests {
+		solver, err := registry.Get(tt.name)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Get(%q) succeeded, want an error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Get(%q): %v", tt.name, err)
+			continue
+		}
+		result, err := solver.Solve(context.Background(), SolveRequest{Expression: "2 + 2"})
+		if err != nil {
+			t.Errorf("Get(%q).Solve: %v", tt.name, err)
+			continue
+		}
+		if result.Solver != tt.solver {
+			t.Errorf("Get(%q) answered by %s, want %s", tt.name, result.Solver, tt.solver)
+		}
+	}
+	if names := registry.Names(); len(names) != 2 || names[0] != "local" || names[1] != "remote" {
+		t.Errorf("Names() = %v, want [local remote]", names)
+	}
+}
+
+func TestFallbackSolver(t *testing.T) {
+	primaryErr := errors.New("primary down")
+	tests := []struct {
+		name      string
+		primary   *fakeSolver
+		fallback  *fakeSolver
+		cancelled bool
+		final     string
+		wantErr   error
+	}{
+		{"primary answers", &fakeSolver{name: "a", final: "1"}, &fakeSolver{name: "b", final: "2"}, false, "1", nil},
+		{"fallback answers", &fakeSolver{name: "a", err: primaryErr}, &fakeSolver{name: "b", final: "2"}, false, "2", nil},
+		{"both fail", &fakeSolver{name: "a", err: primaryErr}, &fakeSolver{name: "b", err: errors.New("fallback down")}, false, "", primaryErr},
+		{"cancelled", &fakeSolver{name: "a", final: "1"}, &fakeSolver{name: "b", final: "2"}, true, "", context.Canceled},
+	}
+	for _, tt := range tests {
+		ctx, cancel := context.WithCancel(context.Background())
+		if tt.cancelled {
+			cancel()
+		}
+		result, err := NewFallbackSolver(tt.primary, tt.fallback).Solve(ctx, SolveRequest{Expression: "x"})
+		cancel()
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("%s: error = %v, want %v", tt.name, err, tt.wantErr)
+			}
+			if tt.cancelled && tt.fallback.calls.Load() != 0 {
+				t.Errorf("%s: fell back after cancellation", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if result.Final != tt.final {
+			t.Errorf("%s: final = %s, want %s", tt.name, result.Final, tt.final)
+		}
+	}
+}
+
+func TestSolveStreamWithoutStreamingSupport(t *testing.T) {
+	var steps []models.SolutionStep
+	result, err := SolveStream(context.Background(), &fakeSolver{name: "a", final: "1"}, SolveRequest{Expression: "x"}, func(step models.SolutionStep) {
+		steps = append(steps, step)
+	})
+	if err != nil {
+		t.Fatalf("SolveStream: %v", err)
+	}
+	if len(steps) != len(result.Steps) || steps[0].Latex != "x" {
+		t.Errorf("streamed steps = %v, want %v", steps, result.Steps)
+	}
+}
+
+// streamingSolver streams steps before failing with err, if set.
+type streamingSolver struct {
+	fakeSolver
+	steps int
+}
+
+func (s *streamingSolver) SolveStream(ctx context.Context, req SolveRequest, onStep StepFunc) (*SolveResult, error) {
+	s.calls.Add(1)
+	result := &SolveResult{Final: s.final, Solver: s.name}
+	for i := 1; i <= s.steps; i++ {
+		step := models.SolutionStep{Index: i, Latex: req.Expression}
+		result.Steps = append(result.Steps, step)
+		onStep(step)
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+	return result, nil
+}
+
+func TestFallbackSolverStream(t *testing.T) {
+	primaryErr := errors.New("primary down")
+	tests := []struct {
+		name     string
+		primary  *streamingSolver
+		final    string
+		steps    int // Steps streamed in total
+		wantErr  error
+		fellBack bool
+	}{
+		{"primary answers", &streamingSolver{fakeSolver{name: "a", final: "1"}, 2}, "1", 2, nil, false},
+		{"fails before streaming", &streamingSolver{fakeSolver{name: "a", err: primaryErr}, 0}, "2", 1, nil, true},
+		{"fails after streaming", &streamingSolver{fakeSolver{name: "a", err: primaryErr}, 1}, "", 1, primaryErr, false},
+	}
+	for _, tt := range tests {
+		fallback := &fakeSolver{name: "b", final: "2"}
+		steps := 0
+		result, err := NewFallbackSolver(tt.primary, fallback).SolveStream(context.Background(), SolveRequest{Expression: "x"}, func(models.SolutionStep) {
+			steps++
+		})
+		if fellBack := fallback.calls.Load() > 0; fellBack != tt.fellBack {
+			t.Errorf("%s: fell back = %v, want %v", tt.name, fellBack, tt.fellBack)
+		}
+		if steps != tt.steps {
+			t.Errorf("%s: streamed %d steps, want %d", tt.name, steps, tt.steps)
+		}
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("%s: error = %v, want %v", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if result.Final != tt.final {
+			t.Errorf("%s: final = %s, want %s", tt.name, result.Final, tt.final)
+		}
+	}
+}
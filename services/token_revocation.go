@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"maths-solution-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrTokenRevoked is returned for access tokens revoked by logout or
+	// logout-all.
+	ErrTokenRevoked = errors.New("token revoked")
+	// ErrUserInactive is returned for access tokens of deleted users.
+	ErrUserInactive = errors.New("user deleted")
+)
+
+// AccessToken identifies an access token for revocation.
+type AccessToken struct {
+	ID        string // jti claim
+	UserID    uint
+	Version   int // User.TokenVersion when the token was issued
+	ExpiresAt time.Time
+}
+
+// TokenRevocations decides whether access tokens are still usable. Revoked
+// jtis live in Postgres, so logout applies to every instance; each instance
+// caches what it learns. A revocation is permanent and cached until the
+// token expires, while a token or user found valid is trusted for ttl only.
+// Without a database only revocations made by this instance are known.
+type TokenRevocations struct {
+	db  *gorm.DB
+	ttl time.Duration
+	now func() time.Time
+
+	mu        sync.Mutex
+	revoked   map[string]time.Time // jti to token expiry
+	unrevoked map[string]time.Time // jti to when it was found unrevoked
+	users     map[uint]userTokenState
+	swept     time.Time
+}
+
+type userTokenState struct {
+	active  bool
+	version int
+	checked time.Time
+}
+
+func NewTokenRevocations(db *gorm.DB, ttl time.Duration) *TokenRevocations {
+	return &TokenRevocations{
+		db:        db,
+		ttl:       ttl,
+		now:       time.Now,
+		revoked:   make(map[string]time.Time),
+		unrevoked: make(map[string]time.Time),
+		users:     make(map[uint]userTokenState),
+	}
+}
+
+// Check returns ErrTokenRevoked or ErrUserInactive when token must be
+// rejected, and nil when it may be used.
+func (r *TokenRevocations) Check(ctx context.Context, token AccessToken) error {
+	now := r.now()
+	r.mu.Lock()
+	r.sweepLocked(now)
+	_, revoked := r.revoked[token.ID]
+	user, userCached := r.users[token.UserID]
+	checked, jtiCached := r.unrevoked[token.ID]
+	r.mu.Unlock()
+
+	if revoked {
+		return ErrTokenRevoked
+	}
+	if r.db == nil {
+		return nil
+	}
+
+	if !userCached || now.Sub(user.checked) >= r.ttl {
+		var row models.User
+		res := r.db.WithContext(ctx).Unscoped().Select("id", "token_version", "deleted_at").
+			Where("id = ?", token.UserID).Limit(1).Find(&row)
+		if res.Error != nil {
+			return res.Error
+		}
+		user = userTokenState{active: res.RowsAffected > 0 && !row.DeletedAt.Valid, version: row.TokenVersion, checked: now}
+		r.mu.Lock()
+		r.users[token.UserID] = user
+		r.mu.Unlock()
+	}
+	if !user.active {
+		return ErrUserInactive
+	}
+	if token.Version != user.version {
+		return ErrTokenRevoked
+	}
+
+	if !jtiCached || now.Sub(checked) >= r.ttl {
+		var count int64
+		if err := r.db.WithContext(ctx).Model(&models.RevokedToken{}).Where("jti = ?", token.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		r.mu.Lock()
+		if count > 0 {
+			r.revoked[token.ID] = token.ExpiresAt
+		} else {
+			r.unrevoked[token.ID] = now
+		}
+		r.mu.Unlock()
+		if count > 0 {
+			return ErrTokenRevoked
+		}
+	}
+	return nil
+}
+
+// Revoke revokes a single access token until it expires.
+func (r *TokenRevocations) Revoke(ctx context.Context, token AccessToken) error {
+	if r.db != nil {
+		record := models.RevokedToken{JTI: token.ID, UserID: token.UserID, ExpiresAt: token.ExpiresAt}
+		if err := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&record).Error; err != nil {
+			return err
+		}
+		// Rows of expired tokens serve no purpose; dropping them is best-effort
+		_ = r.db.WithContext(ctx).Where("expires_at < ?", r.now()).Delete(&models.RevokedToken{}).Error
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.revoked[token.ID] = token.ExpiresAt
+	delete(r.unrevoked, token.ID)
+	return nil
+}
+
+// RevokeUser revokes every access token issued to userID so far by moving
+// the user to a new token version.
+func (r *TokenRevocations) RevokeUser(ctx context.Context, userID uint) error {
+	if r.db == nil {
+		return errors.New("revoking all tokens requires the database")
+	}
+	err := r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", userID).
+		Update("token_version", gorm.Expr("token_version + 1")).Error
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.users, userID)
+	return nil
+}
+
+// sweepLocked forgets expired revocations and stale lookups, at most once
+// per ttl.
+func (r *TokenRevocations) sweepLocked(now time.Time) {
+	if now.Sub(r.swept) < r.ttl {
+		return
+	}
+	r.swept = now
+	for jti, expires := range r.revoked {
+		if now.After(expires) {
+			delete(r.revoked, jti)
+		}
+	}
+	for jti, checked := range r.unrevoked {
+		if now.Sub(checked) >= r.ttl {
+			delete(r.unrevoked, jti)
+		}
+	}
+	for id, user := range r.users {
+		if now.Sub(user.checked) >= r.ttl {
+			delete(r.users, id)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTokenRevocationsInMemory(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := NewTokenRevocations(nil, time.Minute)
+	r.now = func() time.Time { return now }
+
+	token := AccessToken{ID: "a", UserID: 1, ExpiresAt: now.Add(15 * time.Minute)}
+	if err := r.Revoke(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		after time.Duration // Time passed since the revocation
+		id    string
+		want  error
+	}{
+		{"revoked", 0, "a", ErrTokenRevoked},
+		{"other token", 0, "b", nil},
+		{"still revoked", 10 * time.Minute, "a", ErrTokenRevoked},
+		{"forgotten once expired", 20 * time.Minute, "a", nil},
+	}
+	start := now
+	for _, tt := range tests {
+		now = start.Add(tt.after)
+		if err := r.Check(ctx, AccessToken{ID: tt.id, UserID: 1}); !errors.Is(err, tt.want) {
+			t.Errorf("%s: Check = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+	if err := r.RevokeUser(ctx, 1); err == nil {
+		t.Errorf("RevokeUser succeeded without a database")
+	}
+}
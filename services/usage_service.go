@@ -3,22 +3,49 @@ package services
 import (
 	"time"
 
+	"maths-solution-backend/config"
 	"maths-solution-backend/models"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 const DAILY_LIMIT = 10
 
 type UsageService struct {
-	db *gorm.DB
+	db              *gorm.DB
+	unverifiedLimit int // Daily limit of users whose email is unverified; negative for DAILY_LIMIT
 }
 
-func NewUsageService(db *gorm.DB) *UsageService {
-	return &UsageService{db: db}
+func NewUsageService(db *gorm.DB, cfg config.UsageConfig) *UsageService {
+	return &UsageService{db: db, unverifiedLimit: cfg.UnverifiedDailyLimit}
+}
+
+// dailyLimit is the number of solves a user gets per day.
+type dailyLimit struct {
+	value      int
+	unverified bool // Reduced until the user verifies their email
+}
+
+func (s *UsageService) limitFor(userID uint) (dailyLimit, error) {
+	if s.unverifiedLimit < 0 || s.unverifiedLimit >= DAILY_LIMIT {
+		return dailyLimit{value: DAILY_LIMIT}, nil
+	}
+	var user models.User
+	if err := s.db.Select("id", "email_verified_at").Where("id = ?", userID).Limit(1).Find(&user).Error; err != nil {
+		return dailyLimit{}, err
+	}
+	if user.EmailVerifiedAt == nil {
+		return dailyLimit{value: s.unverifiedLimit, unverified: true}, nil
+	}
+	return dailyLimit{value: DAILY_LIMIT}, nil
 }
 
 func (s *UsageService) CheckUsageLimit(userID uint) (*models.UsageLimitResponse, error) {
+	limit, err := s.limitFor(userID)
+	if err != nil {
+		return nil, err
+	}
 	today := time.Now().Format("2006-01-02")
 
 	var usage models.UsageLimit
@@ -43,14 +70,19 @@ func (s *UsageService) CheckUsageLimit(userID uint) (*models.UsageLimitResponse,
 	nextReset := time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), 0, 0, 0, 0, tomorrow.Location())
 
 	return &models.UsageLimitResponse{
-		Count:     usage.Count,
-		Limit:     DAILY_LIMIT,
-		Exceeded:  usage.Count >= DAILY_LIMIT,
-		ResetTime: nextReset.Format(time.RFC3339),
+		Count:      usage.Count,
+		Limit:      limit.value,
+		Exceeded:   usage.Count >= limit.value,
+		ResetTime:  nextReset.Format(time.RFC3339),
+		Unverified: limit.unverified,
 	}, nil
 }
 
 func (s *UsageService) IncrementUsage(userID uint) (*models.UsageLimitResponse, error) {
+	limit, err := s.limitFor(userID)
+	if err != nil {
+		return nil, err
+	}
 	today := time.Now().Format("2006-01-02")
 
 	var usage models.UsageLimit
@@ -83,13 +115,87 @@ func (s *UsageService) IncrementUsage(userID uint) (*models.UsageLimitResponse,
 	nextReset := time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), 0, 0, 0, 0, tomorrow.Location())
 
 	return &models.UsageLimitResponse{
-		Count:     usage.Count,
-		Limit:     DAILY_LIMIT,
-		Exceeded:  usage.Count >= DAILY_LIMIT,
-		ResetTime: nextReset.Format(time.RFC3339),
+		Count:      usage.Count,
+		Limit:      limit.value,
+		Exceeded:   usage.Count >= limit.value,
+		ResetTime:  nextReset.Format(time.RFC3339),
+		Unverified: limit.unverified,
 	}, nil
 }
 
+// UsageReservation is quota taken up front for several solves at once.
+type UsageReservation struct {
+	UserID  uint
+	Date    string // Day the quota was taken from, so releases after midnight refund the right day
+	Granted int
+}
+
+// ReserveUsage atomically takes up to n solves from today's quota. With
+// partial it takes whatever is left; otherwise it takes all n or nothing.
+// The usage row is locked for the duration, so concurrent batches cannot
+// both spend the same remaining quota.
+func (s *UsageService) ReserveUsage(userID uint, n int, partial bool) (*UsageReservation, *models.UsageLimitResponse, error) {
+	limit, err := s.limitFor(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	today := time.Now().Format("2006-01-02")
+	reservation := &UsageReservation{UserID: userID, Date: today}
+
+	var usage models.UsageLimit
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.UsageLimit{UserID: userID, Date: today}).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ? AND date = ?", userID, today).First(&usage).Error; err != nil {
+			return err
+		}
+
+		remaining := limit.value - usage.Count
+		switch {
+		case remaining <= 0:
+			return nil
+		case n <= remaining:
+			reservation.Granted = n
+		case partial:
+			reservation.Granted = remaining
+		default:
+			return nil
+		}
+		usage.Count += reservation.Granted
+		return tx.Model(&usage).Update("count", usage.Count).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return reservation, usageResponse(usage.Count, limit), nil
+}
+
+// ReleaseUsage gives n reserved solves back, for items that failed or were
+// not charged after all.
+func (s *UsageService) ReleaseUsage(reservation *UsageReservation, n int) error {
+	if n <= 0 {
+		return nil
+	}
+	return s.db.Model(&models.UsageLimit{}).
+		Where("user_id = ? AND date = ?", reservation.UserID, reservation.Date).
+		Update("count", gorm.Expr("GREATEST(count - ?, 0)", n)).Error
+}
+
+func usageResponse(count int, limit dailyLimit) *models.UsageLimitResponse {
+	tomorrow := time.Now().AddDate(0, 0, 1)
+	nextReset := time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), 0, 0, 0, 0, tomorrow.Location())
+
+	return &models.UsageLimitResponse{
+		Count:      count,
+		Limit:      limit.value,
+		Exceeded:   count >= limit.value,
+		ResetTime:  nextReset.Format(time.RFC3339),
+		Unverified: limit.unverified,
+	}
+}
+
 func (s *UsageService) GetUsageStats(userID uint) (*models.UsageLimitResponse, error) {
 	return s.CheckUsageLimit(userID)
 }
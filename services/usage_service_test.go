@@ -0,0 +1,42 @@
+package services
+
+import (
+	"testing"
+
+	"maths-solution-backend/config"
+)
+
+// TestUsageLimitWithoutReduction covers settings under which every user gets
+// DAILY_LIMIT without their verification status being looked up.
+func TestUsageLimitWithoutReduction(t *testing.T) {
+	for _, unverified := range []int{-1, DAILY_LIMIT, DAILY_LIMIT + 5} {
+		limit, err := NewUsageService(nil, config.UsageConfig{UnverifiedDailyLimit: unverified}).limitFor(1)
+		if err != nil {
+			t.Errorf("limitFor with UnverifiedDailyLimit %d: %v", unverified, err)
+			continue
+		}
+		if limit.value != DAILY_LIMIT || limit.unverified {
+			t.Errorf("limitFor with UnverifiedDailyLimit %d = %+v, want DAILY_LIMIT", unverified, limit)
+		}
+	}
+}
+
+func TestUsageResponse(t *testing.T) {
+	tests := []struct {
+		count    int
+		limit    dailyLimit
+		exceeded bool
+	}{
+		{2, dailyLimit{value: 3, unverified: true}, false},
+		{3, dailyLimit{value: 3, unverified: true}, true},
+		{3, dailyLimit{value: DAILY_LIMIT}, false},
+		{DAILY_LIMIT, dailyLimit{value: DAILY_LIMIT}, true},
+	}
+	for _, tt := range tests {
+		got := usageResponse(tt.count, tt.limit)
+		if got.Limit != tt.limit.value || got.Exceeded != tt.exceeded || got.Unverified != tt.limit.unverified {
+			t.Errorf("usageResponse(%d, %+v) = %+v, want limit %d, exceeded %v, unverified %v",
+				tt.count, tt.limit, got, tt.limit.value, tt.exceeded, tt.limit.unverified)
+		}
+	}
+}
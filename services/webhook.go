@@ -0,0 +1,168 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// WebhookSignatureHeader carries "t=<unix seconds>,v1=<hex HMAC-SHA256>" of
+// "<t>.<body>". Receivers recompute it with the shared secret and reject
+// stale timestamps to defeat replays.
+const WebhookSignatureHeader = "X-Webhook-Signature"
+
+// ErrWebhookAddress is returned for webhooks to addresses inside the
+// network: loopback, private, link-local (which includes cloud metadata
+// services), multicast or unspecified ones.
+var ErrWebhookAddress = errors.New("webhook address not allowed")
+
+// internalNets are the internal ranges net.IP has no predicate for: "this
+// network", reached as the local host, and carrier-grade NAT, where some
+// clouds serve metadata.
+var internalNets = []*net.IPNet{
+	mustParseCIDR("0.0.0.0/8"),
+	mustParseCIDR("100.64.0.0/10"),
+}
+
+func mustParseCIDR(s string) *net.IPNet {
+	_, ipNet, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return ipNet
+}
+
+// WebhookSender POSTs signed JSON payloads to public addresses, or to the
+// allowed hosts wherever they are. Redirects are not followed.
+type WebhookSender struct {
+	secret  []byte
+	allowed map[string]bool
+	client  *http.Client
+	now     func() time.Time
+}
+
+// NewWebhookSender returns a sender trusting the comma-separated
+// allowedHosts to be reached on internal addresses.
+func NewWebhookSender(secret string, timeout time.Duration, allowedHosts string) *WebhookSender {
+	w := &WebhookSender{
+		secret:  []byte(secret),
+		allowed: map[string]bool{},
+		now:     time.Now,
+	}
+	for _, host := range strings.Split(allowedHosts, ",") {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			w.allowed[host] = true
+		}
+	}
+
+	// The check runs on the resolved address of every connection, so a
+	// hostname cannot pass validation and then resolve to an internal one
+	guarded := &net.Dialer{Timeout: timeout, Control: checkDialAddress}
+	trusted := &net.Dialer{Timeout: timeout}
+	w.client = &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			// No proxy: it would connect to the receiver unchecked
+			Proxy: nil,
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				if host, _, err := net.SplitHostPort(addr); err == nil && w.allowed[strings.ToLower(host)] {
+					return trusted.DialContext(ctx, network, addr)
+				}
+				return guarded.DialContext(ctx, network, addr)
+			},
+			TLSHandshakeTimeout: timeout,
+			ForceAttemptHTTP2:   true,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return w
+}
+
+// checkDialAddress is the dialer's Control hook, refusing internal addresses.
+func checkDialAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || internalIP(ip) {
+		return fmt.Errorf("%w: %s", ErrWebhookAddress, host)
+	}
+	return nil
+}
+
+func internalIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return true
+	}
+	for _, ipNet := range internalNets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckURL rejects webhook URLs that are not http or https, or that name an
+// internal address outright. Hostnames are checked again when dialled.
+func (w *WebhookSender) CheckURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errors.New("webhook_url must be an http or https URL")
+	}
+	host := strings.ToLower(u.Hostname())
+	if w.allowed[host] {
+		return nil
+	}
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("%w: %s", ErrWebhookAddress, host)
+	}
+	if ip := net.ParseIP(host); ip != nil && internalIP(ip) {
+		return fmt.Errorf("%w: %s", ErrWebhookAddress, host)
+	}
+	return nil
+}
+
+// SignWebhook returns the signature header value of body sent at timestamp.
+func SignWebhook(secret []byte, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	ts := strconv.FormatInt(timestamp, 10)
+	mac.Write([]byte(ts + "."))
+	mac.Write(body)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Send delivers body to url; any status other than 2xx, redirects included,
+// is an error.
+func (w *WebhookSender) Send(ctx context.Context, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(w.secret, w.now().Unix(), body))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
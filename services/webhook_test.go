@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestInternalIP(t *testing.T) {
+	tests := []struct {
+		ip       string
+		internal bool
+	}{
+		{"127.0.0.1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"100.100.100.200", true},
+		{"0.0.0.0", true},
+		{"0.1.2.3", true},
+		{"224.0.0.1", true},
+		{"::1", true},
+		{"::", true},
+		{"fe80::1", true},
+		{"fd00:ec2::254", true},
+		{"::ffff:127.0.0.1", true},
+		{"93.184.216.34", false},
+		{"8.8.8.8", false},
+		{"2606:4700:4700::1111", false},
+	}
+	for _, tt := range tests {
+		if got := internalIP(net.ParseIP(tt.ip)); got != tt.internal {
+			t.Errorf("internalIP(%s) = %v, want %v", tt.ip, got, tt.internal)
+		}
+	}
+}
+
+func TestWebhookCheckURL(t *testing.T) {
+	w := NewWebhookSender("secret", time.Second, "hooks.internal, 10.0.0.5")
+	tests := []struct {
+		url string
+		ok  bool
+	}{
+		{"https://example.com/hook", true},
+		{"http://93.184.216.34:8080/hook", true},
+		{"http://hooks.internal/hook", true},
+		{"http://10.0.0.5/hook", true},
+		{"ftp://example.com/hook", false},
+		{"example.com/hook", false},
+		{"http:///hook", false},
+		{"http://localhost:8000/hook", false},
+		{"http://127.0.0.1/hook", false},
+		{"http://[::1]/hook", false},
+		{"http://169.254.169.254/latest/meta-data/", false},
+		{"http://10.0.0.6/hook", false},
+	}
+	for _, tt := range tests {
+		if err := w.CheckURL(tt.url); (err == nil) != tt.ok {
+			t.Errorf("CheckURL(%q) = %v, want ok %v", tt.url, err, tt.ok)
+		}
+	}
+}
+
+func TestWebhookSendRefusesInternalAddresses(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	err := NewWebhookSender("secret", time.Second, "").Send(context.Background(), server.URL, []byte(`{}`))
+	if !errors.Is(err, ErrWebhookAddress) {
+		t.Errorf("Send to %s error = %v, want ErrWebhookAddress", server.URL, err)
+	}
+	if called {
+		t.Error("Send reached the internal receiver")
+	}
+}
+
+func TestWebhookSendAllowedHost(t *testing.T) {
+	secret := []byte("secret")
+	var signature string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature = r.Header.Get(WebhookSignatureHeader)
+	}))
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+
+	w := NewWebhookSender(string(secret), time.Second, u.Hostname())
+	w.now = func() time.Time { return time.Unix(1700000000, 0) }
+	if err := w.Send(context.Background(), server.URL, []byte(`{}`)); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if want := SignWebhook(secret, 1700000000, []byte(`{}`)); signature != want {
+		t.Errorf("signature = %q, want %q", signature, want)
+	}
+}
+
+func TestWebhookSendDoesNotFollowRedirects(t *testing.T) {
+	redirected := false
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hook", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/elsewhere", http.StatusTemporaryRedirect)
+	})
+	mux.HandleFunc("/elsewhere", func(w http.ResponseWriter, r *http.Request) {
+		redirected = true
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+
+	err := NewWebhookSender("secret", time.Second, u.Hostname()).Send(context.Background(), server.URL+"/hook", []byte(`{}`))
+	if err == nil {
+		t.Error("Send succeeded on a redirect, want an error")
+	}
+	if redirected {
+		t.Error("Send followed the redirect")
+	}
+}